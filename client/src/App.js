@@ -2,14 +2,107 @@ import React, { Component } from "react";
 import { hot } from "react-hot-loader";
 import "./App.css";
 
+const apiUrl = 'http://localhost:3001';
+const maxEvents = 20;
+
 class App extends Component {
     constructor() {
         super();
         this.state = {
             result: [],
-            error: ''
+            error: '',
+            events: [],
+            eventsError: ''
         };
         this.handleSubmit = this.handleSubmit.bind(this);
+        this.lastEventId = '';
+    }
+
+    componentWillUnmount() {
+        this.stopEvents();
+    }
+
+    // watchEvents streams message events with fetch rather than EventSource, which cannot send the API key.
+    // The stream resumes from the last event after a disconnect.
+    watchEvents(apiKey) {
+        if (this.eventsKey === apiKey) {
+            return;
+        }
+        this.stopEvents();
+        this.eventsKey = apiKey;
+        const controller = new AbortController();
+        this.eventsController = controller;
+
+        const headers = { 'Authorization': 'Bearer ' + apiKey };
+        if (this.lastEventId) {
+            headers['Last-Event-ID'] = this.lastEventId;
+        }
+        fetch(apiUrl + '/api/v1/events?type=message.queued,message.sent,message.delivered,message.failed,message.inbound', { headers, signal: controller.signal })
+            .then(res => {
+                if (!res.ok) {
+                    return res.json().then(problem => {
+                        this.eventsKey = null;
+                        this.setState({ eventsError: 'Live status unavailable: ' + problem.message });
+                    });
+                }
+                this.setState({ eventsError: '' });
+                return this.readEvents(res.body.getReader());
+            })
+            .catch(error => {
+                if (controller.signal.aborted) {
+                    return;
+                }
+                console.error('Error:', error);
+            })
+            .then(() => {
+                if (!controller.signal.aborted && this.eventsKey === apiKey) {
+                    this.eventsKey = null;
+                    this.retry = setTimeout(() => this.watchEvents(apiKey), 3000);
+                }
+            });
+    }
+
+    stopEvents() {
+        clearTimeout(this.retry);
+        if (this.eventsController) {
+            this.eventsController.abort();
+        }
+        this.eventsKey = null;
+    }
+
+    readEvents(reader) {
+        const decoder = new TextDecoder();
+        let buffer = '';
+        const read = () => reader.read().then(({ done, value }) => {
+            if (done) {
+                return;
+            }
+            buffer += decoder.decode(value, { stream: true });
+            const blocks = buffer.split('\n\n');
+            buffer = blocks.pop();
+            blocks.forEach(block => this.handleEvent(block));
+            return read();
+        });
+        return read();
+    }
+
+    handleEvent(block) {
+        const fields = {};
+        block.split('\n').forEach(line => {
+            const i = line.indexOf(': ');
+            if (i > 0) {
+                fields[line.slice(0, i)] = line.slice(i + 2);
+            }
+        });
+        if (fields.id) {
+            this.lastEventId = fields.id;
+        }
+        if (fields.event === 'reset') {
+            this.setState({ events: [] });
+        } else if (fields.event && fields.data) {
+            const event = JSON.parse(fields.data);
+            this.setState(state => ({ events: [event].concat(state.events).slice(0, maxEvents) }));
+        }
     }
 
     handleSubmit(event) {
@@ -27,7 +120,8 @@ class App extends Component {
             return;
         }
 
-        const url = 'http://localhost:3001/api/v1/sms/send';
+        this.watchEvents(data.get('apikey'));
+        const url = apiUrl + '/api/v1/sms/send';
         const payload = {
             phone_number: data.get('number'),
             texts: [data.get('text1'), data.get('text2'), data.get('text3')]
@@ -37,7 +131,8 @@ class App extends Component {
             method: 'POST',
             body: JSON.stringify(payload),
             headers: {
-                'Content-Type': 'application/json'
+                'Content-Type': 'application/json',
+                'Authorization': 'Bearer ' + data.get('apikey')
             }
         }).then(res => res.json())
             .then(data => {
@@ -54,7 +149,7 @@ class App extends Component {
     }
 
     render() {
-        const { error, result } = this.state;
+        const { error, result, events, eventsError } = this.state;
         return (
             <form onSubmit={this.handleSubmit}>
                 <h1> SMS Sender </h1>
@@ -65,6 +160,8 @@ class App extends Component {
                         return <li key={index}>Text {index} status: {value}</li>
                     })}
                 </ul>
+                <label htmlFor="apikey">API key</label>
+                <input id="apikey" name="apikey" type="password" />
                 <label htmlFor="number">Phone number</label>
                 <input id="number" name="number" type="text" />
                 <label htmlFor="text1">Text 1</label>
@@ -74,6 +171,13 @@ class App extends Component {
                 <label htmlFor="text3">Text 3</label>
                 <input id="text3" name="text3" type="text" />
                 <button>Send</button>
+                <h2> Live status </h2>
+                <p style={{ color: 'red' }}>{eventsError}</p>
+                <ul>
+                    {events.map(event => {
+                        return <li key={event.id}>{event.time} {event.message_id}: {event.type.replace('message.', '')} {event.error_code}</li>
+                    })}
+                </ul>
             </form>
         );
     }
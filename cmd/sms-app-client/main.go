@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 )
@@ -26,6 +27,7 @@ func sendSMS(ctx context.Context, client *http.Client) {
 	if err != nil {
 		log.Fatalf("request creation failed")
 	}
+	r.Header.Set("Authorization", "Bearer "+os.Getenv("SMS_API_KEY"))
 	res, err := client.Do(r.WithContext(ctx))
 	if err != nil {
 		fmt.Println(err)
@@ -4,10 +4,18 @@ import (
 	"github.com/nikhil-github/sms-app/pkg/wiring"
 )
 
+// Version and gitCommit are set by the Makefile at build time.
+var (
+	Version   = "dev"
+	gitCommit = "unknown"
+)
+
 func main() {
 	var cfg *wiring.Config
 	a := wiring.App{
-		Config: cfg,
+		Config:    cfg,
+		Version:   Version,
+		GitCommit: gitCommit,
 	}
 	a.Run()
 }
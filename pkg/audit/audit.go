@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionSend      = "sms.send"
+	ActionKeyIssue  = "key.issue"
+	ActionKeyRotate = "key.rotate"
+	ActionKeyRevoke = "key.revoke"
+	// ActionConfigReload records applied and rejected config reloads.
+	ActionConfigReload = "config.reload"
+	ActionLogLevel     = "log.level"
+	// ActionSubjectErase records the erasure of the messages sent to a phone number.
+	ActionSubjectErase = "subject.erase"
+	// ActionPurge records the removal of the entries past the retention period. Its through_seq and
+	// through_hash details anchor the chain of the entries kept.
+	ActionPurge = "audit.purge"
+)
+
+// ErrTampered returned when the hash chain does not match the entries.
+var ErrTampered = errors.New("audit log tampered")
+
+// Entry represent an audited action. Each entry carries the hash of the previous
+// one so changing, removing or reordering entries breaks the chain. Hashes are keyed,
+// so the chain cannot be recomputed after a change without the key. Phone numbers and
+// texts are only recorded as pseudonyms.
+type Entry struct {
+	Seq       int64             `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	Actor     string            `json:"actor,omitempty"`
+	TenantID  string            `json:"tenant_id,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// Filter selects entries, zero values match everything.
+type Filter struct {
+	Action   string
+	Actor    string
+	TenantID string
+	Since    time.Time
+	Until    time.Time
+	// After skips entries up to and including this sequence number.
+	After int64
+	// Limit caps the number of entries returned, 0 for no limit.
+	Limit int
+}
+
+// Match reports whether the entry is selected by the filter.
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case e.Seq <= f.After:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.TenantID != "" && e.TenantID != f.TenantID:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log appends hash-chained entries to a store.
+type Log struct {
+	mu         sync.Mutex
+	store      Store
+	now        func() time.Time
+	chainKey   []byte
+	subjectKey []byte
+}
+
+// NewLog creates a Log backed by store. The key is the secret of the hash chain and of pseudonyms,
+// the chain of a store only verifies with the key it was written with.
+func NewLog(store Store, key []byte) *Log {
+	return &Log{store: store, now: time.Now, chainKey: mac(key, "audit chain"), subjectKey: mac(key, "audit subject")}
+}
+
+// Record assigns the sequence number, time and hashes of e and appends it.
+// The store is synced after the chain is released so concurrent records share a sync.
+func (l *Log) Record(ctx context.Context, e Entry) (Entry, error) {
+	e, err := l.append(ctx, e)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := l.store.Sync(ctx); err != nil {
+		return Entry{}, errors.Wrap(err, "failed to sync audit entry")
+	}
+	return e, nil
+}
+
+func (l *Log) append(ctx context.Context, e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last, ok, err := l.store.Last(ctx)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to read last audit entry")
+	}
+	e.Seq = 1
+	e.PrevHash = ""
+	if ok {
+		e.Seq = last.Seq + 1
+		e.PrevHash = last.Hash
+	}
+	e.Time = l.now().UTC()
+	e.Hash = l.Hash(e)
+	if err := l.store.Append(ctx, e); err != nil {
+		return Entry{}, errors.Wrap(err, "failed to append audit entry")
+	}
+	return e, nil
+}
+
+// Pseudonym returns a keyed digest of a phone number or text, the same value always gives
+// the same pseudonym so entries about it can still be found.
+func (l *Log) Pseudonym(value string) string {
+	return hex.EncodeToString(mac(l.subjectKey, value)[:16])
+}
+
+// Query returns the entries matching the filter in sequence order.
+func (l *Log) Query(ctx context.Context, f Filter) ([]Entry, error) {
+	entries := []Entry{}
+	err := l.store.Scan(ctx, func(e Entry) (bool, error) {
+		if f.Match(e) {
+			entries = append(entries, e)
+		}
+		return f.Limit == 0 || len(entries) < f.Limit, nil
+	})
+	return entries, err
+}
+
+// Export writes the entries matching the filter as JSON lines.
+func (l *Log) Export(ctx context.Context, f Filter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	n := 0
+	return l.store.Scan(ctx, func(e Entry) (bool, error) {
+		if !f.Match(e) {
+			return true, nil
+		}
+		if err := enc.Encode(&e); err != nil {
+			return false, err
+		}
+		n++
+		return f.Limit == 0 || n < f.Limit, nil
+	})
+}
+
+// Purge removes the entries recorded before the time, returning how many there were. The
+// purge is recorded first so the chain of the entries kept stays anchored.
+func (l *Log) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var through Entry
+	var n int64
+	err := l.store.Scan(ctx, func(e Entry) (bool, error) {
+		if !e.Time.Before(before) {
+			return false, nil
+		}
+		through = e
+		n++
+		return true, nil
+	})
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	_, err = l.Record(ctx, Entry{Action: ActionPurge, Details: map[string]string{
+		"through_seq":  strconv.FormatInt(through.Seq, 10),
+		"through_hash": through.Hash,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	if err := l.store.Truncate(ctx, through.Seq); err != nil {
+		return 0, errors.Wrap(err, "failed to purge audit entries")
+	}
+	return n, nil
+}
+
+// Verify walks the whole chain and returns the number of entries checked. A chain not starting
+// at the first entry must be anchored by the purge which removed the entries before.
+// ErrTampered is returned with the sequence number of the first broken entry.
+func (l *Log) Verify(ctx context.Context) (int64, error) {
+	var checked int64
+	var first Entry
+	prev := ""
+	anchored := false
+	err := l.store.Scan(ctx, func(e Entry) (bool, error) {
+		if checked == 0 {
+			first = e
+			prev = e.PrevHash
+			anchored = e.Seq == 1 && e.PrevHash == ""
+		}
+		if e.Seq != first.Seq+checked || e.PrevHash != prev || l.Hash(e) != e.Hash {
+			return false, errors.Wrapf(ErrTampered, "entry %d", first.Seq+checked)
+		}
+		if e.Action == ActionPurge && e.Details["through_seq"] == strconv.FormatInt(first.Seq-1, 10) && e.Details["through_hash"] == first.PrevHash {
+			anchored = true
+		}
+		checked++
+		prev = e.Hash
+		return true, nil
+	})
+	if err == nil && checked > 0 && !anchored {
+		return 0, errors.Wrapf(ErrTampered, "entries before %d removed without a purge", first.Seq)
+	}
+	return checked, err
+}
+
+// Hash returns the HMAC-SHA256 of the entry without its own hash.
+func (l *Log) Hash(e Entry) string {
+	e.Hash = ""
+	b, _ := json.Marshal(&e)
+	return hex.EncodeToString(mac(l.chainKey, string(b)))
+}
+
+func mac(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
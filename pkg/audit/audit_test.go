@@ -0,0 +1,261 @@
+package audit_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+)
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	log := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+	for _, action := range []string{audit.ActionKeyIssue, audit.ActionSend, audit.ActionSend} {
+		_, err := log.Record(ctx, audit.Entry{Action: action, Actor: "admin"})
+		require.NoError(t, err, "record")
+	}
+
+	entries, err := log.Query(ctx, audit.Filter{})
+	require.NoError(t, err, "query")
+	require.Len(t, entries, 3, "entries")
+	assert.Equal(t, "", entries[0].PrevHash, "first entry")
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash, "chained")
+	assert.Equal(t, int64(3), entries[2].Seq, "sequence")
+
+	sends, err := log.Query(ctx, audit.Filter{Action: audit.ActionSend, After: 2})
+	require.NoError(t, err, "query sends")
+	require.Len(t, sends, 1, "sends after 2")
+	assert.Equal(t, int64(3), sends[0].Seq, "sequence")
+
+	var buf bytes.Buffer
+	require.NoError(t, log.Export(ctx, audit.Filter{Limit: 2}, &buf), "export")
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"), "exported lines")
+
+	n, err := log.Verify(ctx)
+	require.NoError(t, err, "verify")
+	assert.Equal(t, int64(3), n, "verified")
+
+	assert.Equal(t, log.Pseudonym("61400000001"), log.Pseudonym("61400000001"), "stable pseudonym")
+	assert.NotEqual(t, log.Pseudonym("61400000001"), audit.NewLog(audit.NewMemoryStore(), []byte("other")).Pseudonym("61400000001"), "keyed pseudonym")
+}
+
+func TestFileStore(t *testing.T) {
+	type args struct {
+		Tamper func(lines []audit.Entry) []audit.Entry
+	}
+	type want struct {
+		Verified int64
+		Tampered bool
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success - untouched file",
+			Args: args{Tamper: func(lines []audit.Entry) []audit.Entry { return lines }},
+			Want: want{Verified: 3},
+		},
+		{
+			Name: "Failure - entry changed",
+			Args: args{Tamper: func(lines []audit.Entry) []audit.Entry {
+				lines[1].Target = "61499999999"
+				return lines
+			}},
+			Want: want{Verified: 1, Tampered: true},
+		},
+		{
+			Name: "Failure - chain recomputed without the key",
+			Args: args{Tamper: func(lines []audit.Entry) []audit.Entry {
+				forged := audit.NewLog(audit.NewMemoryStore(), []byte("guessed"))
+				prev := ""
+				for i := range lines {
+					lines[i].Target = "61499999999"
+					lines[i].PrevHash = prev
+					lines[i].Hash = forged.Hash(lines[i])
+					prev = lines[i].Hash
+				}
+				return lines
+			}},
+			Want: want{Verified: 0, Tampered: true},
+		},
+		{
+			Name: "Failure - entry removed",
+			Args: args{Tamper: func(lines []audit.Entry) []audit.Entry {
+				return append(lines[:1], lines[2:]...)
+			}},
+			Want: want{Verified: 1, Tampered: true},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			store, err := audit.OpenFile(path)
+			require.NoError(t, err, "open")
+			log := audit.NewLog(store, []byte("audit-key"))
+			for _, target := range []string{"61400000001", "61400000002", "61400000003"} {
+				_, err := log.Record(ctx, audit.Entry{Action: audit.ActionSend, Target: target})
+				require.NoError(t, err, "record")
+			}
+			require.NoError(t, store.Close(), "close")
+
+			rewrite(t, path, tt.Args.Tamper)
+			store, err = audit.OpenFile(path)
+			require.NoError(t, err, "reopen")
+			defer store.Close()
+			n, err := audit.NewLog(store, []byte("audit-key")).Verify(ctx)
+			assert.Equal(t, tt.Want.Verified, n, "verified entries")
+			if tt.Want.Tampered {
+				assert.Equal(t, audit.ErrTampered, errors.Cause(err), "tampered")
+				return
+			}
+			require.NoError(t, err, "verify")
+
+			e, err := audit.NewLog(store, []byte("audit-key")).Record(ctx, audit.Entry{Action: audit.ActionKeyRevoke})
+			require.NoError(t, err, "record after reopen")
+			assert.Equal(t, int64(4), e.Seq, "sequence continues")
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	type want struct {
+		Purged   int64
+		Seqs     []int64
+		Tampered bool
+	}
+	testTable := []struct {
+		Name   string
+		Tamper func(lines []audit.Entry) []audit.Entry
+		Want   want
+	}{
+		{
+			Name:   "Success : old entries removed, chain anchored by the purge",
+			Tamper: func(lines []audit.Entry) []audit.Entry { return lines },
+			Want:   want{Purged: 2, Seqs: []int64{3, 4}},
+		},
+		{
+			Name:   "Failure - more entries removed than purged",
+			Tamper: func(lines []audit.Entry) []audit.Entry { return lines[1:] },
+			Want:   want{Purged: 2, Tampered: true},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			store, err := audit.OpenFile(path)
+			require.NoError(t, err, "open")
+			log := audit.NewLog(store, []byte("audit-key"))
+			for i := 0; i < 3; i++ {
+				_, err := log.Record(ctx, audit.Entry{Action: audit.ActionKeyIssue})
+				require.NoError(t, err, "record")
+				time.Sleep(time.Millisecond)
+			}
+			entries, err := log.Query(ctx, audit.Filter{})
+			require.NoError(t, err, "query")
+
+			n, err := log.Purge(ctx, entries[2].Time)
+			require.NoError(t, err, "purge")
+			assert.Equal(t, tt.Want.Purged, n, "purged")
+			n, err = log.Purge(ctx, entries[0].Time)
+			require.NoError(t, err, "purge again")
+			assert.Zero(t, n, "nothing older left")
+			require.NoError(t, store.Close(), "close")
+
+			rewrite(t, path, tt.Tamper)
+			store, err = audit.OpenFile(path)
+			require.NoError(t, err, "reopen")
+			defer store.Close()
+			log = audit.NewLog(store, []byte("audit-key"))
+			_, err = log.Verify(ctx)
+			if tt.Want.Tampered {
+				assert.Equal(t, audit.ErrTampered, errors.Cause(err), "tampered")
+				return
+			}
+			require.NoError(t, err, "verify")
+			entries, err = log.Query(ctx, audit.Filter{})
+			require.NoError(t, err, "query kept")
+			seqs := []int64{}
+			for _, e := range entries {
+				seqs = append(seqs, e.Seq)
+			}
+			assert.Equal(t, tt.Want.Seqs, seqs, "entries kept")
+			assert.Equal(t, audit.ActionPurge, entries[1].Action, "purge recorded")
+			assert.Equal(t, "2", entries[1].Details["through_seq"], "anchor")
+		})
+	}
+}
+
+func TestFileStorePing(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	store, err := audit.OpenFile(path)
+	require.NoError(t, err, "open")
+	require.NoError(t, store.Ping(ctx), "open file")
+
+	require.NoError(t, os.Rename(path, path+".1"), "rotate")
+	assert.EqualError(t, store.Ping(ctx), "audit file is missing: stat "+path+": no such file or directory", "missing file")
+	require.NoError(t, os.WriteFile(path, nil, 0600), "replace")
+	assert.EqualError(t, store.Ping(ctx), "audit file was replaced, entries are written to the old one", "replaced file")
+
+	require.NoError(t, store.Close(), "close")
+	assert.Error(t, store.Ping(ctx), "closed file")
+}
+
+func TestFileStoreConcurrentRecords(t *testing.T) {
+	ctx := context.Background()
+	store, err := audit.OpenFile(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err, "open")
+	defer store.Close()
+	log := audit.NewLog(store, []byte("audit-key"))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				_, err := log.Record(ctx, audit.Entry{Action: audit.ActionSend})
+				assert.NoError(t, err, "record")
+			}
+		}()
+	}
+	wg.Wait()
+	n, err := log.Verify(ctx)
+	require.NoError(t, err, "verify")
+	assert.Equal(t, int64(200), n, "entries")
+}
+
+func rewrite(t *testing.T, path string, tamper func([]audit.Entry) []audit.Entry) {
+	f, err := os.Open(path)
+	require.NoError(t, err, "open file")
+	var entries []audit.Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e audit.Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e), "decode")
+		entries = append(entries, e)
+	}
+	f.Close()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range tamper(entries) {
+		require.NoError(t, enc.Encode(&e), "encode")
+	}
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600), "write file")
+}
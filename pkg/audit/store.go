@@ -0,0 +1,270 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store persists audit entries in append order.
+type Store interface {
+	Append(ctx context.Context, e Entry) error
+	Last(ctx context.Context) (Entry, bool, error)
+	// Sync makes the appended entries durable.
+	Sync(ctx context.Context) error
+	// Scan calls fn for each entry in order until fn returns false or an error.
+	Scan(ctx context.Context, fn func(Entry) (bool, error)) error
+	// Truncate removes the entries up to and including sequence number through.
+	Truncate(ctx context.Context, through int64) error
+}
+
+// MemoryStore keeps entries in memory.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append adds an entry.
+func (m *MemoryStore) Append(ctx context.Context, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+// Last returns the latest entry.
+func (m *MemoryStore) Last(ctx context.Context) (Entry, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.entries) == 0 {
+		return Entry{}, false, nil
+	}
+	return m.entries[len(m.entries)-1], true, nil
+}
+
+// Sync does nothing, entries in memory are lost on restart anyway.
+func (m *MemoryStore) Sync(ctx context.Context) error {
+	return nil
+}
+
+// Scan iterates over a snapshot of the entries.
+func (m *MemoryStore) Scan(ctx context.Context, fn func(Entry) (bool, error)) error {
+	m.mu.RLock()
+	entries := m.entries[:len(m.entries):len(m.entries)]
+	m.mu.RUnlock()
+	for _, e := range entries {
+		more, err := fn(e)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Truncate removes the entries up to through, scans in progress keep their snapshot.
+func (m *MemoryStore) Truncate(ctx context.Context, through int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := 0
+	for i < len(m.entries) && m.entries[i].Seq <= through {
+		i++
+	}
+	m.entries = append([]Entry(nil), m.entries[i:]...)
+	return nil
+}
+
+// FileStore appends entries as JSON lines to a file opened in append only mode.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	last    *Entry
+	written int64
+
+	// syncMu serialises syncs, synced is the number of entries written when the last one started.
+	syncMu sync.Mutex
+	synced int64
+}
+
+// OpenFile opens or creates the audit file at path.
+func OpenFile(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit file")
+	}
+	s := &FileStore{path: path, file: f}
+	err = s.Scan(context.Background(), func(e Entry) (bool, error) {
+		s.last = &e
+		return true, nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append writes the entry, it is durable once Sync returns.
+func (s *FileStore) Append(ctx context.Context, e Entry) error {
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode audit entry")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write audit entry")
+	}
+	s.last = &e
+	s.written++
+	return nil
+}
+
+// Sync syncs the file unless a sync started after the entries written so far. Entries
+// appended while a sync runs are covered by the next one, shared by every caller waiting.
+func (s *FileStore) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	written := s.written
+	s.mu.Unlock()
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if s.synced >= written {
+		return nil
+	}
+	s.mu.Lock()
+	written = s.written
+	s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync audit file")
+	}
+	s.synced = written
+	return nil
+}
+
+// Last returns the latest entry.
+func (s *FileStore) Last(ctx context.Context) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		return Entry{}, false, nil
+	}
+	return *s.last, true, nil
+}
+
+// Scan reads the entries from the file.
+func (s *FileStore) Scan(ctx context.Context, fn func(Entry) (bool, error)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open audit file")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return errors.Wrapf(err, "invalid audit entry on line %d", line)
+		}
+		more, err := fn(e)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return errors.Wrap(scanner.Err(), "failed to read audit file")
+}
+
+// Truncate writes the entries after through to a new file which then replaces the audit file.
+// It is the only change made to written entries, used to purge them after the retention period.
+func (s *FileStore) Truncate(ctx context.Context, through int64) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.OpenFile(s.path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create audit file")
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	err = s.Scan(ctx, func(e Entry) (bool, error) {
+		if e.Seq <= through {
+			return true, nil
+		}
+		b, err := json.Marshal(&e)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to encode audit entry")
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err == nil, errors.Wrap(err, "failed to write audit entry")
+	})
+	if err == nil {
+		err = errors.Wrap(w.Flush(), "failed to write audit file")
+	}
+	if err == nil {
+		err = errors.Wrap(tmp.Sync(), "failed to sync audit file")
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = errors.Wrap(cerr, "failed to close audit file")
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return errors.Wrap(err, "failed to replace audit file")
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open audit file")
+	}
+	s.file.Close()
+	s.file = f
+	s.synced = s.written
+	return nil
+}
+
+// Ping checks the file is still open and at its path, and that files can be created next to it
+// as truncations do.
+func (s *FileStore) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	open, err := s.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "audit file is not open")
+	}
+	current, err := os.Stat(s.path)
+	if err != nil {
+		return errors.Wrap(err, "audit file is missing")
+	}
+	if !os.SameFile(open, current) {
+		return errors.New("audit file was replaced, entries are written to the old one")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".ping-*")
+	if err != nil {
+		return errors.Wrap(err, "audit directory is not writable")
+	}
+	tmp.Close()
+	return errors.Wrap(os.Remove(tmp.Name()), "failed to remove audit ping file")
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
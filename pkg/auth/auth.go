@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Scope represent a permission granted to an API key.
+type Scope string
+
+// Scopes supported by the API.
+const (
+	ScopeSend  Scope = "send"
+	ScopeRead  Scope = "read"
+	ScopeAdmin Scope = "admin"
+)
+
+const tokenPrefix = "sms_"
+
+var (
+	// ErrUnauthenticated returned when the token is missing, unknown or revoked.
+	ErrUnauthenticated = errors.New("invalid api key")
+	// ErrNotFound returned when the key does not exist.
+	ErrNotFound = errors.New("api key not found")
+	// ErrInvalidScope returned when an unknown scope is requested.
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Key represent an API key. Only the hash of the token is kept.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	TenantID  string     `json:"tenant_id,omitempty"`
+	Hash      string     `json:"-"`
+	Scopes    []Scope    `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key is granted the scope.
+// Admin keys are granted every scope.
+func (k Key) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked reports whether the key has been revoked.
+func (k Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Keys issue, rotate, revoke and authenticate API keys.
+type Keys struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewKeys creates a new Keys backed by the given store.
+func NewKeys(store Store) *Keys {
+	return &Keys{store: store, now: time.Now}
+}
+
+// Issue creates a new key for the tenant with the given scopes and returns the plain token.
+// The token is only available at creation time.
+func (k *Keys) Issue(ctx context.Context, name string, tenantID string, scopes []Scope) (Key, string, error) {
+	if err := validateScopes(scopes); err != nil {
+		return Key{}, "", err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return Key{}, "", err
+	}
+	token, err := newToken()
+	if err != nil {
+		return Key{}, "", err
+	}
+	key := Key{
+		ID:        id,
+		Name:      name,
+		TenantID:  tenantID,
+		Hash:      Hash(token),
+		Scopes:    scopes,
+		CreatedAt: k.now().UTC(),
+	}
+	if err := k.store.Create(ctx, key); err != nil {
+		return Key{}, "", errors.Wrap(err, "failed to store key")
+	}
+	return key, token, nil
+}
+
+// Import stores a key for an existing token, used to bootstrap the first admin key.
+func (k *Keys) Import(ctx context.Context, id string, name string, token string, scopes []Scope) (Key, error) {
+	if err := validateScopes(scopes); err != nil {
+		return Key{}, err
+	}
+	key := Key{
+		ID:        id,
+		Name:      name,
+		Hash:      Hash(token),
+		Scopes:    scopes,
+		CreatedAt: k.now().UTC(),
+	}
+	if err := k.store.Create(ctx, key); err != nil {
+		return Key{}, errors.Wrap(err, "failed to store key")
+	}
+	return key, nil
+}
+
+// Rotate replaces the token of the key and returns the new plain token.
+// The previous token stops working immediately.
+func (k *Keys) Rotate(ctx context.Context, id string) (Key, string, error) {
+	key, err := k.store.Get(ctx, id)
+	if err != nil {
+		return Key{}, "", err
+	}
+	if key.Revoked() {
+		return Key{}, "", ErrNotFound
+	}
+	token, err := newToken()
+	if err != nil {
+		return Key{}, "", err
+	}
+	now := k.now().UTC()
+	key.Hash = Hash(token)
+	key.RotatedAt = &now
+	if err := k.store.Update(ctx, key); err != nil {
+		return Key{}, "", errors.Wrap(err, "failed to store key")
+	}
+	return key, token, nil
+}
+
+// Revoke disables the key.
+func (k *Keys) Revoke(ctx context.Context, id string) (Key, error) {
+	key, err := k.store.Get(ctx, id)
+	if err != nil {
+		return Key{}, err
+	}
+	if key.Revoked() {
+		return key, nil
+	}
+	now := k.now().UTC()
+	key.RevokedAt = &now
+	if err := k.store.Update(ctx, key); err != nil {
+		return Key{}, errors.Wrap(err, "failed to store key")
+	}
+	return key, nil
+}
+
+// List returns all keys including revoked ones.
+func (k *Keys) List(ctx context.Context) ([]Key, error) {
+	return k.store.List(ctx)
+}
+
+// Authenticate finds the active key matching the plain token.
+func (k *Keys) Authenticate(ctx context.Context, token string) (Key, error) {
+	if token == "" {
+		return Key{}, ErrUnauthenticated
+	}
+	key, err := k.store.FindByHash(ctx, Hash(token))
+	if err == ErrNotFound {
+		return Key{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if key.Revoked() {
+		return Key{}, ErrUnauthenticated
+	}
+	return key, nil
+}
+
+// Hash returns the hex encoded SHA-256 of the token.
+// Tokens are random 256 bit values so a slow hash is not required.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseScopes converts names to scopes rejecting unknown values.
+func ParseScopes(names []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(names))
+	for _, n := range names {
+		scopes = append(scopes, Scope(n))
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+func validateScopes(scopes []Scope) error {
+	if len(scopes) == 0 {
+		return errors.Wrap(ErrInvalidScope, "at least one scope is required")
+	}
+	for _, s := range scopes {
+		switch s {
+		case ScopeSend, ScopeRead, ScopeAdmin:
+		default:
+			return errors.Wrapf(ErrInvalidScope, "unknown scope %q", s)
+		}
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate token")
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate id")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,179 @@
+package auth_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+)
+
+func TestAuthenticate(t *testing.T) {
+	type args struct {
+		Token func(issued, rotated string) string
+	}
+	type fields struct {
+		Operations func(k *auth.Keys, id string) (rotated string)
+	}
+	type want struct {
+		Err   error
+		Scope auth.Scope
+	}
+	testTable := []struct {
+		Name   string
+		Args   args
+		Fields fields
+		Want   want
+	}{
+		{
+			Name:   "Success : issued key",
+			Args:   args{Token: func(issued, rotated string) string { return issued }},
+			Fields: fields{Operations: func(k *auth.Keys, id string) string { return "" }},
+			Want:   want{Scope: auth.ScopeSend},
+		},
+		{
+			Name:   "Failure : unknown key",
+			Args:   args{Token: func(issued, rotated string) string { return "sms_unknown" }},
+			Fields: fields{Operations: func(k *auth.Keys, id string) string { return "" }},
+			Want:   want{Err: auth.ErrUnauthenticated},
+		},
+		{
+			Name: "Failure : revoked key",
+			Args: args{Token: func(issued, rotated string) string { return issued }},
+			Fields: fields{Operations: func(k *auth.Keys, id string) string {
+				_, err := k.Revoke(context.Background(), id)
+				require.NoError(t, err, "revoke")
+				return ""
+			}},
+			Want: want{Err: auth.ErrUnauthenticated},
+		},
+		{
+			Name: "Failure : token replaced by rotation",
+			Args: args{Token: func(issued, rotated string) string { return issued }},
+			Fields: fields{Operations: func(k *auth.Keys, id string) string {
+				_, token, err := k.Rotate(context.Background(), id)
+				require.NoError(t, err, "rotate")
+				return token
+			}},
+			Want: want{Err: auth.ErrUnauthenticated},
+		},
+		{
+			Name: "Success : rotated token",
+			Args: args{Token: func(issued, rotated string) string { return rotated }},
+			Fields: fields{Operations: func(k *auth.Keys, id string) string {
+				_, token, err := k.Rotate(context.Background(), id)
+				require.NoError(t, err, "rotate")
+				return token
+			}},
+			Want: want{Scope: auth.ScopeSend},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			store := auth.NewMemoryStore()
+			keys := auth.NewKeys(store)
+			issued, token, err := keys.Issue(context.Background(), "test", "", []auth.Scope{auth.ScopeSend})
+			require.NoError(t, err, "issue")
+			stored, err := store.Get(context.Background(), issued.ID)
+			require.NoError(t, err, "get")
+			assert.NotContains(t, stored.Hash, token, "token stored in plain")
+
+			rotated := tt.Fields.Operations(keys, issued.ID)
+			key, err := keys.Authenticate(context.Background(), tt.Args.Token(token, rotated))
+			if tt.Want.Err != nil {
+				assert.Equal(t, tt.Want.Err, err, "error")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, issued.ID, key.ID, "key id")
+			assert.True(t, key.HasScope(tt.Want.Scope), "scope")
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := auth.OpenFile(path)
+	require.NoError(t, err, "open missing file")
+	keys := auth.NewKeys(store)
+	issued, _, err := keys.Issue(ctx, "web", "acme", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue")
+	rotated, token, err := keys.Rotate(ctx, issued.ID)
+	require.NoError(t, err, "rotate")
+	revoked, revokedToken, err := keys.Issue(ctx, "old", "", []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err, "issue revoked")
+	_, err = keys.Revoke(ctx, revoked.ID)
+	require.NoError(t, err, "revoke")
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "read file")
+	assert.NotContains(t, string(b), token, "token not written")
+	info, err := os.Stat(path)
+	require.NoError(t, err, "stat")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "file mode")
+
+	store, err = auth.OpenFile(path)
+	require.NoError(t, err, "reopen")
+	keys = auth.NewKeys(store)
+	key, err := keys.Authenticate(ctx, token)
+	require.NoError(t, err, "rotated token after restart")
+	assert.Equal(t, rotated.ID, key.ID, "key")
+	assert.Equal(t, "acme", key.TenantID, "tenant")
+	assert.NotNil(t, key.RotatedAt, "rotated at")
+	_, err = keys.Authenticate(ctx, revokedToken)
+	assert.Error(t, err, "revoked token after restart")
+	list, err := keys.List(ctx)
+	require.NoError(t, err, "list")
+	assert.Len(t, list, 2, "keys")
+
+	// Changes are undone when the file cannot be written.
+	require.NoError(t, os.Mkdir(path+".tmp", 0700), "block writes")
+	_, _, err = keys.Issue(ctx, "unsaved", "", []auth.Scope{auth.ScopeSend})
+	assert.Error(t, err, "issue without write")
+	_, err = keys.Revoke(ctx, rotated.ID)
+	assert.Error(t, err, "revoke without write")
+	list, err = keys.List(ctx)
+	require.NoError(t, err, "list after failed writes")
+	assert.Len(t, list, 2, "key not added")
+	_, err = keys.Authenticate(ctx, token)
+	assert.NoError(t, err, "key not revoked")
+	require.NoError(t, os.Remove(path+".tmp"), "unblock writes")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not json"), 0600), "corrupt file")
+	_, err = auth.OpenFile(path)
+	assert.Error(t, err, "invalid file")
+}
+
+func TestHasScope(t *testing.T) {
+	testTable := []struct {
+		Name   string
+		Scopes []auth.Scope
+		Check  auth.Scope
+		Want   bool
+	}{
+		{Name: "granted", Scopes: []auth.Scope{auth.ScopeSend}, Check: auth.ScopeSend, Want: true},
+		{Name: "not granted", Scopes: []auth.Scope{auth.ScopeSend}, Check: auth.ScopeRead, Want: false},
+		{Name: "admin grants all", Scopes: []auth.Scope{auth.ScopeAdmin}, Check: auth.ScopeSend, Want: true},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Want, auth.Key{Scopes: tt.Scopes}.HasScope(tt.Check))
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	_, err := auth.ParseScopes([]string{"send", "delete"})
+	assert.EqualError(t, err, `unknown scope "delete": invalid scope`)
+	_, err = auth.ParseScopes(nil)
+	assert.EqualError(t, err, "at least one scope is required: invalid scope")
+	scopes, err := auth.ParseScopes([]string{"send", "read"})
+	require.NoError(t, err)
+	assert.Equal(t, []auth.Scope{auth.ScopeSend, auth.ScopeRead}, scopes)
+}
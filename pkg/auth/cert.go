@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// CertIdentity maps a client certificate subject to an API identity.
+// Subject is either the full distinguished name, such as CN=billing,O=Acme, or CN=<common name>.
+type CertIdentity struct {
+	Subject  string   `json:"subject"`
+	Name     string   `json:"name"`
+	TenantID string   `json:"tenant_id,omitempty"`
+	Scopes   []string `json:"scopes"`
+}
+
+// CertIdentities authenticates callers by their verified client certificate.
+type CertIdentities struct {
+	mu        sync.RWMutex
+	bySubject map[string]Key
+}
+
+// LoadCertIdentities reads identities from a JSON file holding an array of identities.
+func LoadCertIdentities(path string) ([]CertIdentity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open identities file")
+	}
+	defer f.Close()
+	var identities []CertIdentity
+	if err := json.NewDecoder(f).Decode(&identities); err != nil {
+		return nil, errors.Wrap(err, "failed to decode identities file")
+	}
+	return identities, nil
+}
+
+// NewCertIdentities creates CertIdentities for the identities.
+func NewCertIdentities(identities []CertIdentity) (*CertIdentities, error) {
+	c := &CertIdentities{}
+	if err := c.Replace(identities); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Replace swaps all identities at once, the current ones are kept when identities are invalid.
+func (c *CertIdentities) Replace(identities []CertIdentity) error {
+	bySubject := make(map[string]Key, len(identities))
+	for _, id := range identities {
+		if id.Subject == "" || id.Name == "" {
+			return errors.New("identity subject and name are required")
+		}
+		if _, ok := bySubject[id.Subject]; ok {
+			return errors.Errorf("identity %s defined more than once", id.Subject)
+		}
+		scopes, err := ParseScopes(id.Scopes)
+		if err != nil {
+			return errors.Wrapf(err, "identity %s", id.Subject)
+		}
+		bySubject[id.Subject] = Key{ID: "cert:" + id.Name, Name: id.Name, TenantID: id.TenantID, Scopes: scopes}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bySubject = bySubject
+	return nil
+}
+
+// AuthenticateCert returns the identity of the verified client certificate.
+func (c *CertIdentities) AuthenticateCert(ctx context.Context, cert *x509.Certificate) (Key, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.bySubject[cert.Subject.String()]; ok {
+		return key, nil
+	}
+	if key, ok := c.bySubject["CN="+cert.Subject.CommonName]; ok && cert.Subject.CommonName != "" {
+		return key, nil
+	}
+	return Key{}, ErrUnauthenticated
+}
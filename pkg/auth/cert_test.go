@@ -0,0 +1,83 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+)
+
+func TestAuthenticateCert(t *testing.T) {
+	identities := []auth.CertIdentity{
+		{Subject: "CN=billing,O=Acme", Name: "billing", TenantID: "acme", Scopes: []string{"send"}},
+		{Subject: "CN=ops", Name: "ops", Scopes: []string{"admin"}},
+	}
+	type args struct {
+		Subject pkix.Name
+	}
+	type want struct {
+		Err   error
+		Name  string
+		Scope auth.Scope
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : full subject",
+			Args: args{Subject: pkix.Name{CommonName: "billing", Organization: []string{"Acme"}}},
+			Want: want{Name: "billing", Scope: auth.ScopeSend},
+		},
+		{
+			Name: "Success : common name",
+			Args: args{Subject: pkix.Name{CommonName: "ops", Organization: []string{"Acme"}}},
+			Want: want{Name: "ops", Scope: auth.ScopeAdmin},
+		},
+		{
+			Name: "Failure : other organisation",
+			Args: args{Subject: pkix.Name{CommonName: "billing", Organization: []string{"Other"}}},
+			Want: want{Err: auth.ErrUnauthenticated},
+		},
+		{
+			Name: "Failure : unknown subject",
+			Args: args{Subject: pkix.Name{CommonName: "unknown"}},
+			Want: want{Err: auth.ErrUnauthenticated},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			certs, err := auth.NewCertIdentities(identities)
+			require.NoError(t, err, "identities")
+			key, err := certs.AuthenticateCert(context.Background(), &x509.Certificate{Subject: tt.Args.Subject})
+			if tt.Want.Err != nil {
+				assert.Equal(t, tt.Want.Err, err, "error")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Name, key.Name, "name")
+			assert.Equal(t, "cert:"+tt.Want.Name, key.ID, "id")
+			assert.True(t, key.HasScope(tt.Want.Scope), "scope")
+		})
+	}
+}
+
+func TestCertIdentitiesReplace(t *testing.T) {
+	certs, err := auth.NewCertIdentities([]auth.CertIdentity{{Subject: "CN=ops", Name: "ops", Scopes: []string{"admin"}}})
+	require.NoError(t, err, "identities")
+
+	err = certs.Replace([]auth.CertIdentity{{Subject: "CN=ops", Name: "ops", Scopes: []string{"unknown"}}})
+	assert.Error(t, err, "invalid scope")
+	_, err = certs.AuthenticateCert(context.Background(), &x509.Certificate{Subject: pkix.Name{CommonName: "ops"}})
+	assert.NoError(t, err, "identities kept after invalid replace")
+
+	require.NoError(t, certs.Replace(nil), "replace")
+	_, err = certs.AuthenticateCert(context.Background(), &x509.Certificate{Subject: pkix.Name{CommonName: "ops"}})
+	assert.Equal(t, auth.ErrUnauthenticated, err, "identity removed")
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store persists API keys.
+type Store interface {
+	Create(ctx context.Context, key Key) error
+	Update(ctx context.Context, key Key) error
+	Get(ctx context.Context, id string) (Key, error)
+	FindByHash(ctx context.Context, hash string) (Key, error)
+	List(ctx context.Context) ([]Key, error)
+}
+
+// MemoryStore keeps keys in memory.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	keys   map[string]Key
+	byHash map[string]string
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]Key), byHash: make(map[string]string)}
+}
+
+// Create adds a new key.
+func (m *MemoryStore) Create(ctx context.Context, key Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[key.ID]; ok {
+		return errors.Errorf("key %s already exists", key.ID)
+	}
+	if _, ok := m.byHash[key.Hash]; ok {
+		return errors.New("key hash already exists")
+	}
+	m.keys[key.ID] = copyKey(key)
+	m.byHash[key.Hash] = key.ID
+	return nil
+}
+
+// Update replaces an existing key.
+func (m *MemoryStore) Update(ctx context.Context, key Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.keys[key.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.byHash, old.Hash)
+	m.keys[key.ID] = copyKey(key)
+	m.byHash[key.Hash] = key.ID
+	return nil
+}
+
+// Get returns the key by id.
+func (m *MemoryStore) Get(ctx context.Context, id string) (Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	return copyKey(key), nil
+}
+
+// FindByHash returns the key by token hash.
+func (m *MemoryStore) FindByHash(ctx context.Context, hash string) (Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	id, ok := m.byHash[hash]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	return copyKey(m.keys[id]), nil
+}
+
+// List returns all keys ordered by creation time.
+func (m *MemoryStore) List(ctx context.Context) ([]Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]Key, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, copyKey(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// delete removes the key, undoing a Create.
+func (m *MemoryStore) delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.byHash, k.Hash)
+	delete(m.keys, id)
+	return nil
+}
+
+// storedKey is a key as written to the keys file, with the hash the API does not show.
+type storedKey struct {
+	Key
+	Hash string `json:"hash"`
+}
+
+// FileStore keeps keys in memory and writes them all to a JSON file after every change,
+// so issued, rotated and revoked keys survive a restart. Only token hashes are written.
+type FileStore struct {
+	*MemoryStore
+	mu   sync.Mutex
+	path string
+}
+
+// OpenFile loads the keys file at path, an absent file has no keys yet.
+func OpenFile(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read keys file")
+	}
+	var keys []storedKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, errors.Wrap(err, "invalid keys file")
+	}
+	for _, sk := range keys {
+		k := sk.Key
+		k.Hash = sk.Hash
+		if err := s.MemoryStore.Create(context.Background(), k); err != nil {
+			return nil, errors.Wrap(err, "invalid keys file")
+		}
+	}
+	return s, nil
+}
+
+// Create adds a new key and writes the file, the key is not added when the file cannot be written.
+// A failure to remove it again is reported with the write error.
+func (s *FileStore) Create(ctx context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.MemoryStore.Create(ctx, key); err != nil {
+		return err
+	}
+	if err := s.save(ctx); err != nil {
+		if rerr := s.MemoryStore.delete(key.ID); rerr != nil {
+			return errors.Wrapf(err, "failed to remove key %s after failed write: %v", key.ID, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Update replaces an existing key and writes the file, the key is kept as it was when the file
+// cannot be written. A failure to restore it is reported with the write error.
+func (s *FileStore) Update(ctx context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, err := s.MemoryStore.Get(ctx, key.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.MemoryStore.Update(ctx, key); err != nil {
+		return err
+	}
+	if err := s.save(ctx); err != nil {
+		if rerr := s.MemoryStore.Update(ctx, old); rerr != nil {
+			return errors.Wrapf(err, "failed to restore key %s after failed write: %v", key.ID, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes every key to a new file which then replaces the keys file.
+func (s *FileStore) save(ctx context.Context) error {
+	keys, err := s.MemoryStore.List(ctx)
+	if err != nil {
+		return err
+	}
+	stored := make([]storedKey, len(keys))
+	for i, k := range keys {
+		stored[i] = storedKey{Key: k, Hash: k.Hash}
+	}
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode keys")
+	}
+	tmp, err := os.OpenFile(s.path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create keys file")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write keys file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), s.path), "failed to replace keys file")
+}
+
+func copyKey(k Key) Key {
+	k.Scopes = append([]Scope(nil), k.Scopes...)
+	return k
+}
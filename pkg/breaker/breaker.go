@@ -0,0 +1,131 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrOpen returned when calls are rejected because the breaker is open.
+var ErrOpen = errors.New("circuit breaker open")
+
+// State represent the state of a breaker.
+type State int
+
+// States of a breaker.
+const (
+	// Closed lets calls through and counts consecutive failures.
+	Closed State = iota
+	// Open rejects calls until the cooldown has elapsed.
+	Open
+	// HalfOpen lets a single probe through to decide whether to close.
+	HalfOpen
+)
+
+// String returns the state name.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker opens after consecutive failures and fails fast until a probe succeeds.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New creates a closed breaker opening after threshold consecutive failures
+// and probing again once cooldown has elapsed.
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Name returns the name of the protected dependency.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, an open breaker past its cooldown reports half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Success, Failure or Cancel.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return errors.Wrap(ErrOpen, b.name)
+		}
+		b.state = HalfOpen
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return errors.Wrap(ErrOpen, b.name)
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Success records a successful call and closes the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = Closed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed call, opening the breaker on threshold or a failed probe.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = b.now()
+	}
+	b.probing = false
+}
+
+// Cancel records a call whose outcome says nothing about the dependency.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// IsOpen reports whether err was caused by an open breaker.
+func IsOpen(err error) bool {
+	return errors.Cause(err) == ErrOpen
+}
@@ -0,0 +1,41 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	b := New("transmit", 2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.Allow(), "closed")
+	b.Failure()
+	assert.Equal(t, Closed, b.State(), "below threshold")
+	require.NoError(t, b.Allow(), "closed")
+	b.Failure()
+	assert.Equal(t, Open, b.State(), "threshold reached")
+
+	err := b.Allow()
+	assert.EqualError(t, err, "transmit: circuit breaker open", "fail fast")
+	assert.True(t, IsOpen(err), "is open")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, HalfOpen, b.State(), "cooldown elapsed")
+	require.NoError(t, b.Allow(), "probe")
+	assert.True(t, IsOpen(b.Allow()), "single probe")
+	b.Failure()
+	assert.Equal(t, Open, b.State(), "failed probe")
+
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow(), "probe")
+	b.Cancel()
+	require.NoError(t, b.Allow(), "probe after cancel")
+	b.Success()
+	assert.Equal(t, Closed, b.State(), "successful probe")
+	require.NoError(t, b.Allow(), "closed")
+}
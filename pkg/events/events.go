@@ -0,0 +1,203 @@
+// Package events keeps a bounded history of message lifecycle events and fans them out to subscribers.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type is the kind of a message lifecycle event.
+type Type string
+
+// Types of message events.
+const (
+	// TypeQueued is published when a message is accepted, before it is sent.
+	TypeQueued Type = "message.queued"
+	// TypeSent is published once the provider accepted a message.
+	TypeSent Type = "message.sent"
+	// TypeDelivered is published when the provider reports a message delivered to the handset.
+	TypeDelivered Type = "message.delivered"
+	// TypeFailed is published when a message could not be sent or the provider reports it undelivered.
+	TypeFailed Type = "message.failed"
+	// TypeInbound is published when a reply to a message is received from a phone.
+	TypeInbound Type = "message.inbound"
+)
+
+// Types lists every type of event.
+var Types = []Type{TypeQueued, TypeSent, TypeDelivered, TypeFailed, TypeInbound}
+
+// subscriberBuffer is the number of events a subscriber may fall behind before it is dropped.
+const subscriberBuffer = 64
+
+// ParseType returns the type named s.
+func ParseType(s string) (Type, bool) {
+	for _, t := range Types {
+		if string(t) == s {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// Event represent a change of a message, IDs increase in publish order.
+type Event struct {
+	ID                uint64    `json:"id"`
+	Type              Type      `json:"type"`
+	Time              time.Time `json:"time"`
+	TenantID          string    `json:"tenant_id"`
+	MessageID         string    `json:"message_id"`
+	RequestID         string    `json:"request_id,omitempty"`
+	Segments          int       `json:"segments,omitempty"`
+	Provider          string    `json:"provider,omitempty"`
+	ProviderMessageID string    `json:"provider_message_id,omitempty"`
+	ErrorCode         string    `json:"error_code,omitempty"`
+	ProviderCode      string    `json:"provider_code,omitempty"`
+}
+
+// Filter selects the events of a tenant, of any type and message when Types and MessageIDs are empty.
+type Filter struct {
+	TenantID   string
+	Types      []Type
+	MessageIDs []string
+}
+
+// Match reports whether the filter selects e.
+func (f Filter) Match(e Event) bool {
+	if e.TenantID != f.TenantID {
+		return false
+	}
+	if len(f.Types) > 0 && !containsType(f.Types, e.Type) {
+		return false
+	}
+	if len(f.MessageIDs) > 0 && !containsString(f.MessageIDs, e.MessageID) {
+		return false
+	}
+	return true
+}
+
+// Broker keeps the latest events up to capacity so subscribers can resume after a disconnect.
+type Broker struct {
+	mu       sync.Mutex
+	capacity int
+	buffer   []Event
+	nextID   uint64
+	subs     map[*Subscription]bool
+	closed   bool
+}
+
+// NewBroker creates a broker replaying up to capacity events.
+func NewBroker(capacity int) *Broker {
+	return &Broker{capacity: capacity, nextID: 1, subs: map[*Subscription]bool{}}
+}
+
+// Publish numbers the event, stamps it when its time is not set and sends it to matching subscribers.
+// Subscribers too slow to keep up are dropped and have to resume from their last event.
+func (b *Broker) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.ID = b.nextID
+	b.nextID++
+	b.buffer = append(b.buffer, e)
+	if len(b.buffer) > b.capacity {
+		b.buffer = b.buffer[len(b.buffer)-b.capacity:]
+	}
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events matching f. When lastID is set, the buffered
+// events published after it are replayed first. Missed is set when some were already dropped
+// from the buffer, or when lastID is unknown such as after a restart.
+func (b *Broker) Subscribe(f Filter, lastID uint64) *Subscription {
+	s := &Subscription{broker: b, filter: f, events: make(chan Event, subscriberBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	if lastID > 0 {
+		oldest := b.nextID
+		if len(b.buffer) > 0 {
+			oldest = b.buffer[0].ID
+		}
+		s.Missed = lastID+1 < oldest || lastID >= b.nextID
+		for _, e := range b.buffer {
+			if (s.Missed || e.ID > lastID) && f.Match(e) {
+				s.Replay = append(s.Replay, e)
+			}
+		}
+	}
+	b.subs[s] = true
+	return s
+}
+
+// Close ends every subscription, their event channels are closed.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+func (b *Broker) drop(s *Subscription) {
+	delete(b.subs, s)
+	close(s.events)
+}
+
+// Subscription receives the events matching its filter.
+type Subscription struct {
+	// Replay holds the buffered events published after the subscriber's last event.
+	Replay []Event
+	// Missed reports that events after the subscriber's last event were lost.
+	Missed bool
+
+	broker *Broker
+	filter Filter
+	events chan Event
+}
+
+// Events returns the channel of new events, closed when the subscriber is dropped or the broker closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	if s.broker.subs[s] {
+		s.broker.drop(s)
+	}
+}
+
+func containsType(types []Type, t Type) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/events"
+)
+
+func TestSubscribe(t *testing.T) {
+	type args struct {
+		Filter events.Filter
+		LastID uint64
+	}
+	type want struct {
+		Replay []uint64
+		Missed bool
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : new subscriber gets no replay",
+			Args: args{Filter: events.Filter{TenantID: "a"}},
+		},
+		{
+			Name: "Success : events after the last id replayed",
+			Args: args{Filter: events.Filter{TenantID: "a"}, LastID: 4},
+			Want: want{Replay: []uint64{5, 6}},
+		},
+		{
+			Name: "Success : replay filtered by type and message",
+			Args: args{Filter: events.Filter{TenantID: "a", Types: []events.Type{events.TypeSent}, MessageIDs: []string{"m2"}}, LastID: 3},
+			Want: want{Replay: []uint64{6}},
+		},
+		{
+			Name: "Success : events of other tenants not replayed",
+			Args: args{Filter: events.Filter{TenantID: "b"}, LastID: 3},
+			Want: want{Replay: []uint64{4}},
+		},
+		{
+			Name: "Failure : events dropped from the buffer",
+			Args: args{Filter: events.Filter{TenantID: "a"}, LastID: 1},
+			Want: want{Replay: []uint64{3, 5, 6}, Missed: true},
+		},
+		{
+			Name: "Failure : last id unknown after a restart",
+			Args: args{Filter: events.Filter{TenantID: "a"}, LastID: 99},
+			Want: want{Replay: []uint64{3, 5, 6}, Missed: true},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			b := events.NewBroker(4)
+			for _, e := range []events.Event{
+				{TenantID: "a", MessageID: "m1", Type: events.TypeQueued},
+				{TenantID: "a", MessageID: "m1", Type: events.TypeSent},
+				{TenantID: "a", MessageID: "m2", Type: events.TypeQueued},
+				{TenantID: "b", MessageID: "m3", Type: events.TypeSent},
+				{TenantID: "a", MessageID: "m1", Type: events.TypeDelivered},
+				{TenantID: "a", MessageID: "m2", Type: events.TypeSent},
+			} {
+				b.Publish(e)
+			}
+			sub := b.Subscribe(tt.Args.Filter, tt.Args.LastID)
+			defer sub.Close()
+			var ids []uint64
+			for _, e := range sub.Replay {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tt.Want.Replay, ids, "replayed ids")
+			assert.Equal(t, tt.Want.Missed, sub.Missed, "missed")
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	b := events.NewBroker(10)
+	sub := b.Subscribe(events.Filter{TenantID: "a", Types: []events.Type{events.TypeFailed}}, 0)
+	b.Publish(events.Event{TenantID: "a", MessageID: "m1", Type: events.TypeSent})
+	b.Publish(events.Event{TenantID: "b", MessageID: "m2", Type: events.TypeFailed})
+	b.Publish(events.Event{TenantID: "a", MessageID: "m3", Type: events.TypeFailed})
+
+	e := <-sub.Events()
+	assert.Equal(t, uint64(3), e.ID, "id")
+	assert.Equal(t, "m3", e.MessageID, "only the matching event")
+	assert.False(t, e.Time.IsZero(), "time stamped")
+
+	sub.Close()
+	_, ok := <-sub.Events()
+	assert.False(t, ok, "channel closed")
+	sub.Close()
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := events.NewBroker(1000)
+	sub := b.Subscribe(events.Filter{TenantID: "a"}, 0)
+	for i := 0; i < 100; i++ {
+		b.Publish(events.Event{TenantID: "a", Type: events.TypeSent})
+	}
+	n := 0
+	for range sub.Events() {
+		n++
+	}
+	assert.Equal(t, 64, n, "buffered events received before the channel closed")
+
+	resumed := b.Subscribe(events.Filter{TenantID: "a"}, uint64(n))
+	require.Len(t, resumed.Replay, 100-n, "rest replayed on resume")
+	assert.False(t, resumed.Missed, "nothing lost")
+}
+
+func TestClose(t *testing.T) {
+	b := events.NewBroker(10)
+	sub := b.Subscribe(events.Filter{TenantID: "a"}, 0)
+	b.Close()
+	_, ok := <-sub.Events()
+	assert.False(t, ok, "open subscription ended")
+	_, ok = <-b.Subscribe(events.Filter{TenantID: "a"}, 0).Events()
+	assert.False(t, ok, "new subscription ended")
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+)
+
+const defaultAuditLimit = 100
+
+// Auditor provides methods to record audited actions.
+type Auditor interface {
+	Record(ctx context.Context, e audit.Entry) (audit.Entry, error)
+	// Pseudonym returns the keyed digest recorded in place of a phone number or text.
+	Pseudonym(value string) string
+}
+
+// AuditReader provides methods to read and verify the audit log.
+type AuditReader interface {
+	Query(ctx context.Context, f audit.Filter) ([]audit.Entry, error)
+	Export(ctx context.Context, f audit.Filter, w io.Writer) error
+	Verify(ctx context.Context) (int64, error)
+}
+
+// AuditPage represent a page of audit entries.
+type AuditPage struct {
+	Entries []audit.Entry `json:"entries"`
+	// Next is the after parameter of the next page, 0 when there are no more entries.
+	Next int64 `json:"next,omitempty"`
+}
+
+// AuditVerification represent the result of verifying the audit log.
+type AuditVerification struct {
+	Valid   bool   `json:"valid"`
+	Entries int64  `json:"entries"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditLog handles request to query the audit log.
+// Filters are action, actor, tenant_id, since and until (RFC 3339), after and limit.
+// GET /api/v1/admin/audit
+func AuditLog(logger *zap.Logger, reader AuditReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		f, err := auditFilter(r, defaultAuditLimit)
+		if err != nil {
+			responseBadRequest(w, r, CodeInvalidRequest, err.Error())
+			return
+		}
+		entries, err := reader.Query(r.Context(), f)
+		if err != nil {
+			logger.Error("Unable to query audit log", zap.Error(err))
+			serverError(w, r, "unable to query audit log")
+			return
+		}
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		page := AuditPage{Entries: entries}
+		if len(entries) == f.Limit {
+			page.Next = entries[len(entries)-1].Seq
+		}
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&page)
+	}
+}
+
+// ExportAudit handles request to download the audit log as JSON lines.
+// Accepts the filters of AuditLog, all matching entries are exported by default.
+// GET /api/v1/admin/audit/export
+func ExportAudit(logger *zap.Logger, reader AuditReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.From(r.Context(), logger)
+		f, err := auditFilter(r, 0)
+		if err != nil {
+			responseBadRequest(w, r, CodeInvalidRequest, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		if err := reader.Export(r.Context(), f, w); err != nil {
+			// The status is already sent, the truncated export fails verification.
+			logger.Error("Unable to export audit log", zap.Error(err))
+		}
+	}
+}
+
+// VerifyAudit handles request to check the hash chain of the audit log.
+// GET /api/v1/admin/audit/verify
+func VerifyAudit(logger *zap.Logger, reader AuditReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		n, err := reader.Verify(r.Context())
+		if errors.Cause(err) == audit.ErrTampered {
+			logger.Error("Audit log tampered", zap.Int64("verified_entries", n), zap.Error(err))
+			w.WriteHeader(http.StatusConflict)
+			enc.Encode(&AuditVerification{Entries: n, Error: err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Unable to verify audit log", zap.Error(err))
+			serverError(w, r, "unable to verify audit log")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&AuditVerification{Valid: true, Entries: n})
+	}
+}
+
+// record appends an entry for the caller of the request. Failures are logged
+// and do not fail the request as the action already happened.
+func record(ctx context.Context, logger *zap.Logger, auditor Auditor, e audit.Entry) {
+	e.Actor = callerID(ctx)
+	e.TenantID = tenantID(ctx)
+	e.RequestID = logging.RequestID(ctx)
+	if _, err := auditor.Record(ctx, e); err != nil {
+		logging.From(ctx, logger).Error("Unable to record audit entry", zap.String("action", e.Action), zap.Error(err))
+	}
+}
+
+func auditFilter(r *http.Request, limit int) (audit.Filter, error) {
+	q := r.URL.Query()
+	f := audit.Filter{
+		Action:   q.Get("action"),
+		Actor:    q.Get("actor"),
+		TenantID: q.Get("tenant_id"),
+		Limit:    limit,
+	}
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("invalid since")
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("invalid until")
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		if f.After, err = strconv.ParseInt(v, 10, 64); err != nil || f.After < 0 {
+			return f, errors.New("invalid after")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > 1000 {
+			return f, errors.New("limit must be between 1 and 1000")
+		}
+	}
+	return f, nil
+}
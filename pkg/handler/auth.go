@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+)
+
+// Authenticator provides method to resolve an API key from a token.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (auth.Key, error)
+}
+
+// CertAuthenticator provides method to resolve an API identity from a verified client certificate.
+type CertAuthenticator interface {
+	AuthenticateCert(ctx context.Context, cert *x509.Certificate) (auth.Key, error)
+}
+
+// Authenticate rejects requests without a valid API key or missing the scope.
+// The key is read from the Authorization bearer token or the X-API-Key header.
+// Without a token, a verified client certificate is mapped to its identity when certs is set.
+// The key ID is added to the request logger.
+func Authenticate(logger *zap.Logger, authenticator Authenticator, certs CertAuthenticator, scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.From(r.Context(), logger)
+			token := apiToken(r)
+			cert := clientCert(r)
+			var key auth.Key
+			var err error
+			switch {
+			case token != "":
+				key, err = authenticator.Authenticate(r.Context(), token)
+			case cert != nil && certs != nil:
+				key, err = certs.AuthenticateCert(r.Context(), cert)
+				if err == auth.ErrUnauthenticated {
+					logger.Warn("Client certificate not mapped to an identity", zap.String("subject", cert.Subject.String()))
+					unauthorized(w, r, "client certificate not allowed")
+					return
+				}
+			default:
+				unauthorized(w, r, "api key missing")
+				return
+			}
+			if err == auth.ErrUnauthenticated {
+				unauthorized(w, r, err.Error())
+				return
+			}
+			if err != nil {
+				logger.Error("Unable to authenticate api key", zap.Error(err))
+				serverError(w, r, "unable to authenticate")
+				return
+			}
+			if !key.HasScope(scope) {
+				logger.Warn("Api key missing scope", zap.String("key_id", key.ID), zap.String("scope", string(scope)))
+				forbidden(w, r, CodeForbidden, "api key not allowed to "+string(scope))
+				return
+			}
+			ctx := logging.With(auth.WithKey(r.Context(), key), logger, zap.String("key_id", key.ID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// clientCert returns the verified client certificate of the connection.
+func clientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+func apiToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		parts := strings.SplitN(h, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return strings.TrimSpace(parts[1])
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+)
+
+func TestAuthenticateCert(t *testing.T) {
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	_, token, err := keys.Issue(context.Background(), "partner", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue key")
+	certs, err := auth.NewCertIdentities([]auth.CertIdentity{
+		{Subject: "CN=billing", Name: "billing", Scopes: []string{"send"}},
+		{Subject: "CN=reports", Name: "reports", Scopes: []string{"read"}},
+	})
+	require.NoError(t, err, "identities")
+
+	type args struct {
+		Token string
+		CN    string
+		Certs handler.CertAuthenticator
+	}
+	type want struct {
+		Status int
+		Caller string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : client certificate",
+			Args: args{CN: "billing", Certs: certs},
+			Want: want{Status: http.StatusOK, Caller: "billing"},
+		},
+		{
+			Name: "Success : api key wins over client certificate",
+			Args: args{Token: token, CN: "billing", Certs: certs},
+			Want: want{Status: http.StatusOK, Caller: "partner"},
+		},
+		{
+			Name: "Failure : unmapped client certificate",
+			Args: args{CN: "unknown", Certs: certs},
+			Want: want{Status: http.StatusUnauthorized},
+		},
+		{
+			Name: "Failure : client certificate without send scope",
+			Args: args{CN: "reports", Certs: certs},
+			Want: want{Status: http.StatusForbidden},
+		},
+		{
+			Name: "Failure : client certificates not accepted",
+			Args: args{CN: "billing"},
+			Want: want{Status: http.StatusUnauthorized},
+		},
+		{
+			Name: "Failure : no credentials",
+			Args: args{Certs: certs},
+			Want: want{Status: http.StatusUnauthorized},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var caller string
+			h := handler.Authenticate(zap.NewNop(), keys, tt.Args.Certs, auth.ScopeSend)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key, _ := auth.KeyFromContext(r.Context())
+				caller = key.Name
+			}))
+			req := httptest.NewRequest("POST", "/api/v1/sms", nil)
+			if tt.Args.Token != "" {
+				req.Header.Set("X-API-Key", tt.Args.Token)
+			}
+			if tt.Args.CN != "" {
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.Args.CN}}
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, tt.Want.Status, rec.Code, "status")
+			assert.Equal(t, tt.Want.Caller, caller, "caller")
+		})
+	}
+}
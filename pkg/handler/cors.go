@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CORSPolicy represent the browser origins allowed to call a group of routes.
+// Without origins, browsers on other origins cannot read responses or send preflighted requests.
+type CORSPolicy struct {
+	// Origins are scheme://host[:port] values, * allows any origin.
+	Origins []string
+	Methods []string
+	// Headers are the request headers browsers may send.
+	Headers []string
+	// Expose are the response headers readable by scripts.
+	Expose []string
+	// Credentials lets browsers send cookies and client certificates.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// Validate checks the policy is usable.
+func (p CORSPolicy) Validate() error {
+	for _, o := range p.Origins {
+		if o == "*" && p.Credentials {
+			return errors.New("any origin cannot be allowed with credentials")
+		}
+		if o != "*" && !strings.HasPrefix(o, "http://") && !strings.HasPrefix(o, "https://") {
+			return errors.Errorf("origin %q must be * or start with http:// or https://", o)
+		}
+		if strings.HasSuffix(o, "/") {
+			return errors.Errorf("origin %q must not end with /", o)
+		}
+	}
+	return nil
+}
+
+// CORS applies the policy to the routes it wraps. Preflight requests are answered
+// without calling next, so they are not authenticated, and refused with 403 when
+// the origin, method or headers are outside the policy. Other requests from origins
+// outside the policy are served without CORS headers, so browsers hide the response.
+func CORS(policy CORSPolicy) func(http.Handler) http.Handler {
+	methods := strings.Join(upper(policy.Methods), ", ")
+	headers := strings.Join(policy.Headers, ", ")
+	expose := strings.Join(policy.Expose, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+			if !policy.allowsOrigin(origin) {
+				if preflight {
+					forbidden(w, r, CodeCORSNotAllowed, "origin not allowed")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			h.Set("Access-Control-Allow-Origin", policy.allowOrigin(origin))
+			if policy.Credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if expose != "" {
+					h.Set("Access-Control-Expose-Headers", expose)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if !contains(policy.Methods, r.Header.Get("Access-Control-Request-Method")) {
+				forbidden(w, r, CodeCORSNotAllowed, "method not allowed")
+				return
+			}
+			for _, name := range requestedHeaders(r) {
+				if !contains(policy.Headers, name) {
+					forbidden(w, r, CodeCORSNotAllowed, "header "+name+" not allowed")
+					return
+				}
+			}
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			if policy.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (p CORSPolicy) allowsOrigin(origin string) bool {
+	for _, o := range p.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin returns * when any origin is allowed, the origin itself otherwise
+// as browsers reject * on requests with credentials.
+func (p CORSPolicy) allowOrigin(origin string) string {
+	if !p.Credentials && contains(p.Origins, "*") {
+		return "*"
+	}
+	return origin
+}
+
+func requestedHeaders(r *http.Request) []string {
+	var names []string
+	for _, v := range r.Header["Access-Control-Request-Headers"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// contains compares case insensitively as header names and methods are matched by browsers.
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func upper(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(v)
+	}
+	return out
+}
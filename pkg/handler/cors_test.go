@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestCORS(t *testing.T) {
+	policy := handler.CORSPolicy{
+		Origins: []string{"https://app.example.com"},
+		Methods: []string{"POST"},
+		Headers: []string{"Content-Type", "X-API-Key"},
+		Expose:  []string{"X-Request-ID"},
+		MaxAge:  10 * time.Minute,
+	}
+	router := wiring.NewRouter(&wiring.Params{
+		Logger:        zap.NewNop(),
+		Authenticator: auth.NewKeys(auth.NewMemoryStore()),
+		CORS:          policy,
+	})
+
+	type args struct {
+		Method  string
+		Path    string
+		Headers map[string]string
+	}
+	type want struct {
+		Status  int
+		Headers map[string]string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : preflight answered without authentication",
+			Args: args{Method: "OPTIONS", Path: "/api/v1/sms/send", Headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "content-type, x-api-key",
+			}},
+			Want: want{Status: http.StatusNoContent, Headers: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "POST",
+				"Access-Control-Allow-Headers": "Content-Type, X-API-Key",
+				"Access-Control-Max-Age":       "600",
+			}},
+		},
+		{
+			Name: "Success : actual request exposes headers",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Headers: map[string]string{"Origin": "https://app.example.com"}},
+			Want: want{Status: http.StatusUnauthorized, Headers: map[string]string{
+				"Access-Control-Allow-Origin":   "https://app.example.com",
+				"Access-Control-Expose-Headers": "X-Request-ID",
+			}},
+		},
+		{
+			Name: "Success : request without origin",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send"},
+			Want: want{Status: http.StatusUnauthorized, Headers: map[string]string{"Access-Control-Allow-Origin": ""}},
+		},
+		{
+			Name: "Success : request from other origin without cors headers",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Headers: map[string]string{"Origin": "https://evil.example.com"}},
+			Want: want{Status: http.StatusUnauthorized, Headers: map[string]string{
+				"Access-Control-Allow-Origin":   "",
+				"Access-Control-Expose-Headers": "",
+			}},
+		},
+		{
+			Name: "Failure : preflight from other origin",
+			Args: args{Method: "OPTIONS", Path: "/api/v1/sms/send", Headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "POST",
+			}},
+			Want: want{Status: http.StatusForbidden, Headers: map[string]string{"Access-Control-Allow-Origin": ""}},
+		},
+		{
+			Name: "Failure : preflight for unsupported method",
+			Args: args{Method: "OPTIONS", Path: "/api/v1/sms/send", Headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			}},
+			Want: want{Status: http.StatusForbidden, Headers: map[string]string{"Access-Control-Allow-Methods": ""}},
+		},
+		{
+			Name: "Failure : preflight for unsupported header",
+			Args: args{Method: "OPTIONS", Path: "/api/v1/sms/send", Headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "X-Debug",
+			}},
+			Want: want{Status: http.StatusForbidden, Headers: map[string]string{"Access-Control-Allow-Headers": ""}},
+		},
+		{
+			Name: "Failure : admin api refuses browsers",
+			Args: args{Method: "OPTIONS", Path: "/api/v1/admin/keys", Headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "GET",
+			}},
+			Want: want{Status: http.StatusForbidden, Headers: map[string]string{"Access-Control-Allow-Origin": ""}},
+		},
+		{
+			Name: "Failure : admin request from browser without cors headers",
+			Args: args{Method: "GET", Path: "/api/v1/admin/keys", Headers: map[string]string{"Origin": "https://app.example.com"}},
+			Want: want{Status: http.StatusUnauthorized, Headers: map[string]string{"Access-Control-Allow-Origin": ""}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.Args.Method, tt.Args.Path, nil)
+			for k, v := range tt.Args.Headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.Want.Status, rec.Code, "status")
+			for k, v := range tt.Want.Headers {
+				assert.Equal(t, v, rec.Header().Get(k), k)
+			}
+			if tt.Args.Headers["Origin"] != "" {
+				assert.Contains(t, rec.Header()["Vary"], "Origin", "vary")
+			}
+		})
+	}
+}
+
+func TestCORSPolicyValidate(t *testing.T) {
+	testTable := []struct {
+		Name   string
+		Policy handler.CORSPolicy
+		Err    string
+	}{
+		{
+			Name:   "Success : listed origins with credentials",
+			Policy: handler.CORSPolicy{Origins: []string{"https://app.example.com", "http://localhost:3000"}, Credentials: true},
+		},
+		{
+			Name:   "Success : any origin",
+			Policy: handler.CORSPolicy{Origins: []string{"*"}},
+		},
+		{
+			Name:   "Failure : any origin with credentials",
+			Policy: handler.CORSPolicy{Origins: []string{"*"}, Credentials: true},
+			Err:    "any origin cannot be allowed with credentials",
+		},
+		{
+			Name:   "Failure : origin without scheme",
+			Policy: handler.CORSPolicy{Origins: []string{"app.example.com"}},
+			Err:    `origin "app.example.com" must be * or start with http:// or https://`,
+		},
+		{
+			Name:   "Failure : origin with path",
+			Policy: handler.CORSPolicy{Origins: []string{"https://app.example.com/"}},
+			Err:    `origin "https://app.example.com/" must not end with /`,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Policy.Validate()
+			if tt.Err != "" {
+				assert.EqualError(t, err, tt.Err, "error")
+				return
+			}
+			assert.NoError(t, err, "error")
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+)
+
+// heartbeatInterval keeps idle event streams open through proxies.
+const heartbeatInterval = 15 * time.Second
+
+// Publisher provides method to publish message events.
+type Publisher interface {
+	Publish(e events.Event)
+}
+
+// EventSource provides method to subscribe to message events.
+type EventSource interface {
+	Subscribe(f events.Filter, lastID uint64) *events.Subscription
+}
+
+// Publish publishes an event of the message of tr for the tenant of ctx, publisher may be nil.
+func Publish(ctx context.Context, publisher Publisher, t events.Type, tr TextResult) {
+	if publisher == nil {
+		return
+	}
+	publisher.Publish(events.Event{
+		Type:              t,
+		TenantID:          tenantID(ctx),
+		MessageID:         tr.MessageID,
+		RequestID:         logging.RequestID(ctx),
+		Segments:          tr.Segments,
+		Provider:          tr.Provider,
+		ProviderMessageID: tr.ProviderMessageID,
+		ErrorCode:         tr.ErrorCode,
+		ProviderCode:      tr.ProviderCode,
+	})
+}
+
+// QueueTexts gives each text of a request a message ID and publishes its queued event, before any
+// of them is sent. Empty texts are skipped and get no ID.
+func QueueTexts(ctx context.Context, publisher Publisher, texts []string) []string {
+	ids := make([]string, len(texts))
+	for i, text := range texts {
+		if len(text) == 0 {
+			continue
+		}
+		ids[i] = logging.NewID()
+		Publish(ctx, publisher, events.TypeQueued, TextResult{Index: i, MessageID: ids[i]})
+	}
+	return ids
+}
+
+// Events streams the message events of the caller's tenant as server-sent events.
+// type and message_id filter the events, separated by commas. Events after the Last-Event-ID
+// header, or the last_event_id parameter, are replayed from the buffer; a reset event is sent
+// first when some of them were lost. The stream ends when the subscriber falls too far behind.
+// GET /api/v1/events
+func Events(logger *zap.Logger, source EventSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.From(ctx, logger)
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			logger.Error("Response writer does not support streaming")
+			serverError(w, r, "streaming not supported")
+			return
+		}
+		f, lastID, errs := eventFilter(r)
+		if len(errs) > 0 {
+			p := NewProblem(http.StatusBadRequest, errs[0].Code, errs[0].Detail)
+			p.Errors = errs
+			writeProblem(w, r, p)
+			return
+		}
+		f.TenantID = tenantID(ctx)
+
+		sub := source.Subscribe(f, lastID)
+		defer sub.Close()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if sub.Missed {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, e := range sub.Replay {
+			writeEvent(w, e)
+		}
+		flusher.Flush()
+		logger.Info("Event stream opened", zap.Uint64("last_event_id", lastID), zap.Int("replayed", len(sub.Replay)))
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					logger.Info("Event stream closed by server")
+					return
+				}
+				writeEvent(w, e)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case <-ctx.Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+// eventFilter reads the filters and the last event ID of the request.
+func eventFilter(r *http.Request) (events.Filter, uint64, []FieldError) {
+	var f events.Filter
+	var errs []FieldError
+	q := r.URL.Query()
+	for _, name := range splitList(q.Get("type")) {
+		t, ok := events.ParseType(name)
+		if !ok {
+			errs = append(errs, FieldError{Field: "type", Code: CodeInvalidRequest, Detail: fmt.Sprintf("unknown event type %s", name)})
+			continue
+		}
+		f.Types = append(f.Types, t)
+	}
+	f.MessageIDs = splitList(q.Get("message_id"))
+
+	var lastID uint64
+	field, value := "Last-Event-ID", r.Header.Get("Last-Event-ID")
+	if value == "" {
+		field, value = "last_event_id", q.Get("last_event_id")
+	}
+	if value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			errs = append(errs, FieldError{Field: field, Code: CodeInvalidRequest, Detail: field + " must be an event id"})
+		}
+		lastID = id
+	}
+	return f, lastID, errs
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestEvents(t *testing.T) {
+	var m mockFormatter
+	var s mockSender
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	s.OnSend(int64(61400000000), "hello").Return(service.Receipt{Provider: service.Provider, MessageID: "2937421", Text: "hello"}, nil)
+	s.OnSend(int64(61400000000), "fails").Return(service.Receipt{Provider: service.Provider}, &service.ProviderError{StatusCode: 400, Code: "FIELD_INVALID", Description: "to is invalid"})
+
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	_, sendToken, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+	_, readToken, err := keys.Issue(context.Background(), "read", "", []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err, "issue read key")
+	broker := events.NewBroker(10)
+	params := &wiring.Params{
+		Logger:        zap.NewNop(),
+		Formatter:     &m,
+		Sender:        &s,
+		Authenticator: keys,
+		Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}, {ID: "other"}}),
+		Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+		RateLimits:    handler.RateLimits{},
+		Auditor:       audit.NewLog(audit.NewMemoryStore(), []byte("audit-key")),
+		Publisher:     broker,
+		Events:        broker,
+	}
+	ts := httptest.NewServer(wiring.NewRouter(params))
+	defer ts.Close()
+	defer broker.Close()
+
+	stream := openStream(t, ts.URL+"/api/v1/events?type=message.sent,message.failed", readToken, "")
+	queue := openStream(t, ts.URL+"/api/v1/events?type=message.queued", readToken, "")
+	broker.Publish(events.Event{TenantID: "other", MessageID: "hidden", Type: events.TypeSent})
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/v1/sms/send", strings.NewReader(`{"phone_number":"0400000000","texts":["hello","fails"]}`))
+	require.NoError(t, err, "Error creating request")
+	req.Header.Set("Authorization", "Bearer "+sendToken)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "Error executing request")
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode, "send status")
+
+	sent := stream.next(t)
+	assert.Equal(t, "message.sent", sent.Name, "event name")
+	assert.Equal(t, events.TypeSent, sent.Event.Type, "type")
+	assert.Equal(t, tenant.DefaultID, sent.Event.TenantID, "tenant")
+	assert.NotEmpty(t, sent.Event.MessageID, "message id")
+	assert.Equal(t, "2937421", sent.Event.ProviderMessageID, "provider message id")
+	failed := stream.next(t)
+	assert.Equal(t, events.TypeFailed, failed.Event.Type, "type")
+	assert.Equal(t, handler.CodeInvalidPhoneNumber, failed.Event.ErrorCode, "error code")
+	assert.Equal(t, "FIELD_INVALID", failed.Event.ProviderCode, "provider code")
+
+	queued := []events.Event{queue.next(t).Event, queue.next(t).Event}
+	assert.Equal(t, events.TypeQueued, queued[0].Type, "queued type")
+	assert.Equal(t, []string{sent.Event.MessageID, failed.Event.MessageID}, []string{queued[0].MessageID, queued[1].MessageID}, "texts queued")
+
+	resumed := openStream(t, ts.URL+"/api/v1/events", readToken, sent.ID)
+	assert.Equal(t, failed.ID, resumed.next(t).ID, "events after Last-Event-ID replayed")
+
+	lost := openStream(t, ts.URL+"/api/v1/events?message_id="+sent.Event.MessageID, readToken, "99")
+	assert.Equal(t, "reset", lost.next(t).Name, "reset when events were lost")
+	assert.Equal(t, "message.queued", lost.next(t).Name, "then the buffered events")
+	assert.Equal(t, sent.ID, lost.next(t).ID, "in order")
+}
+
+type sse struct {
+	ID    string
+	Name  string
+	Event events.Event
+}
+
+type eventStream struct {
+	scanner *bufio.Scanner
+}
+
+func openStream(t *testing.T, url string, token string, lastID string) *eventStream {
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err, "Error creating request")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "Error opening stream")
+	t.Cleanup(func() { res.Body.Close() })
+	require.Equal(t, http.StatusOK, res.StatusCode, "stream status")
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"), "content type")
+	return &eventStream{scanner: bufio.NewScanner(res.Body)}
+}
+
+// next returns the next event of the stream, skipping comments and retry fields.
+func (s *eventStream) next(t *testing.T) sse {
+	var e sse
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "" && e.Name != "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && e.Name != "reset":
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.Event), "event data")
+		}
+	}
+	require.NoError(t, s.scanner.Err(), "stream")
+	t.Fatal("stream ended")
+	return e
+}
@@ -3,12 +3,30 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/segment"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
 )
 
+const defaultMaxTexts = 3
+
+// MaxTextLength is the most bytes a text may have.
+const MaxTextLength = 160
+
+const tracerName = "github.com/nikhil-github/sms-app/pkg/handler"
+
 // Message represent input payload.
 type Message struct {
 	PhoneNumber string   `json:"phone_number" `
@@ -16,18 +34,44 @@ type Message struct {
 }
 
 // Result represent status of sms send request.
+// Errors explains failed texts, indexed by their position in the request.
 type Result struct {
-	Status []string `json:"status"`
+	Status []string     `json:"status"`
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
-// ErrorMsg represent error msg.
-type ErrorMsg struct {
-	Message string `json:"message"`
+// TextResult represent the outcome of one input text, Index is its position in the request.
+type TextResult struct {
+	Index int `json:"index"`
+	// MessageID is our ID of the message, used in logs and the audit log.
+	MessageID string `json:"message_id,omitempty"`
+	// Status is sent, failed or skipped for empty texts.
+	Status string `json:"status"`
+	// Segments and Encoding describe the text as sent, after links were shortened.
+	Segments          int            `json:"segments"`
+	Encoding          string         `json:"encoding,omitempty"`
+	Provider          string         `json:"provider,omitempty"`
+	ProviderMessageID string         `json:"provider_message_id,omitempty"`
+	Links             []service.Link `json:"links,omitempty"`
+	ErrorCode         string         `json:"error_code,omitempty"`
+	ProviderCode      string         `json:"provider_code,omitempty"`
+}
+
+// Statuses of a text result.
+const (
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// SendResponse represent the v2 response, one result per input text.
+type SendResponse struct {
+	Results []TextResult `json:"results"`
 }
 
 // Sender provides method to send sms.
 type Sender interface {
-	Send(ctx context.Context, phoneNumber int64, text string) error
+	Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error)
 }
 
 // Formatter provides method to format phone number.
@@ -36,99 +80,235 @@ type Formatter interface {
 }
 
 // Send handles incoming request to send sms.
+// Messages count against the recipient's and the tenant's rate limits.
+// Format, ShortURL and Send calls are traced as children of the request span.
+// Each text gets a message ID added to the log lines of its send, is recorded in the audit log
+// and the message history and published as a message event.
 // POST /api/v1/sms/send
-func Send(logger *zap.Logger, sender Sender, formatter Formatter) http.HandlerFunc {
+func Send(logger *zap.Logger, sender Sender, formatter Formatter, limiter Limiter, limits LimitSource, auditor Auditor, publisher Publisher, recorder HistoryRecorder) http.HandlerFunc {
+	return send("handler.Send", logger, sender, formatter, limiter, limits, auditor, publisher, recorder, func(w http.ResponseWriter, results []TextResult) {
+		res := Result{Status: []string{}}
+		for _, tr := range results {
+			switch tr.Status {
+			case StatusSent:
+				res.Status = append(res.Status, "success")
+			case StatusFailed:
+				res.Status = append(res.Status, "failed")
+				res.Errors = append(res.Errors, FieldError{Field: fmt.Sprintf("texts[%d]", tr.Index), Code: tr.ErrorCode, Detail: "unable to send sms", ProviderCode: tr.ProviderCode})
+			}
+		}
+		writeJSON(w, &res)
+	})
+}
+
+// SendV2 handles incoming request to send sms like Send, answering with one result per input text
+// so results line up with the request's texts.
+// POST /api/v2/sms/send
+func SendV2(logger *zap.Logger, sender Sender, formatter Formatter, limiter Limiter, limits LimitSource, auditor Auditor, publisher Publisher, recorder HistoryRecorder) http.HandlerFunc {
+	return send("handler.SendV2", logger, sender, formatter, limiter, limits, auditor, publisher, recorder, func(w http.ResponseWriter, results []TextResult) {
+		writeJSON(w, &SendResponse{Results: results})
+	})
+}
+
+// send validates the message, sends its texts and passes their results to respond.
+func send(name string, logger *zap.Logger, sender Sender, formatter Formatter, limiter Limiter, limits LimitSource, auditor Auditor, publisher Publisher, recorder HistoryRecorder, respond func(http.ResponseWriter, []TextResult)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		ctx := r.Context()
-		enc := json.NewEncoder(w)
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), name, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("key.id", callerID(r.Context())), attribute.String("tenant.id", tenantID(r.Context()))))
+		defer span.End()
+		logger := logging.From(ctx, logger)
+		r = r.WithContext(ctx)
 
 		var m Message
 		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 			logger.Warn("Unable to parse JSON from request body", zap.Error(err))
-			w.WriteHeader(http.StatusBadRequest)
+			responseBadRequest(w, r, CodeInvalidJSON, "request body is not valid JSON")
 			return
 		}
 
-		if err := validate(m); err != nil {
-			responseBadRequest(w, enc, err.Error())
+		if errs := ValidateMessage(m, MaxTexts(ctx)); len(errs) > 0 {
+			p := NewProblem(http.StatusBadRequest, errs[0].Code, errs[0].Detail)
+			p.Errors = errs
+			writeProblem(w, r, p)
 			return
 		}
 
 		number, valid, err := formatter.Format(ctx, m.PhoneNumber)
 		if err != nil {
-			logger.Error("Unable to validate number", zap.Error(err))
-			serverError(w, enc, "unable to validate number")
+			formatError(w, r, logger, err)
 			return
 		}
 		if !valid {
-			responseBadRequest(w, enc, "invalid phone number")
+			p := NewProblem(http.StatusBadRequest, CodeInvalidPhoneNumber, "invalid phone number")
+			p.Errors = []FieldError{{Field: "phone_number", Code: CodeInvalidPhoneNumber, Detail: "invalid phone number"}}
+			writeProblem(w, r, p)
+			return
+		}
+
+		messages := countTexts(m.Texts)
+		if _, ok := take(w, r, logger, limiter, messages, messageBuckets(ctx, limits, number)...); !ok {
 			return
 		}
 
-		var status []string
-		for _, text := range m.Texts {
+		ids := QueueTexts(ctx, publisher, m.Texts)
+		results := make([]TextResult, len(m.Texts))
+		for i, text := range m.Texts {
+			results[i] = TextResult{Index: i, Status: StatusSkipped}
 			if len(text) == 0 {
 				continue
 			}
-			err := sender.Send(ctx, number, text)
-			if err != nil {
-				status = append(status, "failed")
-				logger.Error("Unable to send sms", zap.String("text", text), zap.Error(err))
-			} else {
-				status = append(status, "success")
-			}
+			results[i] = SendText(ctx, logger, sender, auditor, publisher, recorder, number, i, ids[i], text)
 		}
-		responseOK(w, enc, status)
-		return
+		respond(w, results)
 	}
 }
 
-func validate(m Message) error {
-	if m.PhoneNumber == "" {
-		return errors.New("phone number missing")
+// SendText sends one text of a request, records it in the audit log and the message history and
+// publishes its sent or failed event.
+// The message ID is added to the log lines of the send, failures are recorded on the span of ctx.
+func SendText(ctx context.Context, logger *zap.Logger, sender Sender, auditor Auditor, publisher Publisher, recorder HistoryRecorder, number int64, index int, messageID string, text string) TextResult {
+	logger = logging.From(ctx, logger).With(zap.String("message_id", messageID))
+	ctx = withMessageID(logging.WithLogger(ctx, logger), messageID)
+	receipt, err := sender.Send(ctx, number, text)
+	record(ctx, logger, auditor, sendEntry(auditor, number, text, messageID, err))
+	if err != nil {
+		trace.SpanFromContext(ctx).RecordError(err)
+		logger.Error("Unable to send sms", zap.String("text", text), zap.Error(err))
+	} else {
+		logger.Info("Sms sent", zap.Int64("phone_number", number))
 	}
-	if len(m.Texts) == 0 {
-		return errors.New("texts missing")
+	tr := textResult(index, messageID, text, receipt, err)
+	if receipt.Text != "" {
+		text = receipt.Text
 	}
-	if len(m.Texts) > 3 {
-		return errors.New("max allowed text count is 3")
+	recordHistory(ctx, logger, recorder, number, text, tr)
+	if err != nil {
+		Publish(ctx, publisher, events.TypeFailed, tr)
+	} else {
+		Publish(ctx, publisher, events.TypeSent, tr)
 	}
-	for _, t := range m.Texts {
-		if len(t) > 160 {
-			return errors.New("max allowed text length is 160")
-		}
+	return tr
+}
+
+// textResult describes a sent text, counting segments of the text as sent when the provider got it.
+func textResult(i int, messageID string, text string, receipt service.Receipt, err error) TextResult {
+	if receipt.Text != "" {
+		text = receipt.Text
+	}
+	encoding, segments := segment.Count(text)
+	tr := TextResult{
+		Index:             i,
+		MessageID:         messageID,
+		Status:            StatusSent,
+		Segments:          segments,
+		Encoding:          encoding,
+		Provider:          receipt.Provider,
+		ProviderMessageID: receipt.MessageID,
+		Links:             receipt.Links,
+	}
+	if err != nil {
+		tr.Status = StatusFailed
+		tr.ErrorCode, tr.ProviderCode = ErrorCode(err)
 	}
-	return nil
+	return tr
 }
 
-func responseOK(w http.ResponseWriter, encoder *json.Encoder, response []string) {
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	encoder.Encode(NewStatus(response))
+	json.NewEncoder(w).Encode(v)
+}
+
+// formatError answers 400 when the provider refused the number, 503 while the provider
+// is unavailable, throttling us or out of credit, and 502 when it rejected our call.
+func formatError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	code, providerCode := ErrorCode(err)
+	var p *Problem
+	switch code {
+	case CodeInvalidPhoneNumber:
+		logger.Info("Sms provider refused phone number", zap.Error(err))
+		p = NewProblem(http.StatusBadRequest, code, "invalid phone number")
+		p.Errors = []FieldError{{Field: "phone_number", Code: code, Detail: "invalid phone number", ProviderCode: providerCode}}
+	case CodeProviderUnavailable, CodeProviderRateLimited:
+		logger.Warn("Sms provider unavailable", zap.Error(err))
+		p = NewProblem(http.StatusServiceUnavailable, code, "sms provider unavailable")
+	case CodeProviderBalance:
+		logger.Error("Sms provider account out of credit", zap.Error(err))
+		p = NewProblem(http.StatusServiceUnavailable, code, "sms provider account out of credit")
+	case CodeProviderAuth:
+		logger.Error("Sms provider refused our credentials", zap.Error(err))
+		p = NewProblem(http.StatusBadGateway, code, "sms provider refused our credentials")
+	case CodeProviderRejected:
+		logger.Error("Sms provider rejected number validation", zap.Error(err))
+		p = NewProblem(http.StatusBadGateway, code, "sms provider rejected number validation")
+	default:
+		logger.Error("Unable to validate number", zap.Error(err))
+		serverError(w, r, "unable to validate number")
+		return
+	}
+	if providerCode != "" {
+		p.ProviderCode = providerCode
+	}
+	writeProblem(w, r, p)
 }
 
-func responseBadRequest(w http.ResponseWriter, encoder *json.Encoder, response string) {
-	w.WriteHeader(http.StatusBadRequest)
-	encoder.Encode(NewErrorMsg(response))
+// sendEntry records pseudonyms of the recipient and the text, neither is kept in the audit log.
+func sendEntry(auditor Auditor, number int64, text string, messageID string, err error) audit.Entry {
+	e := audit.Entry{
+		Action: audit.ActionSend,
+		Target: auditor.Pseudonym(strconv.FormatInt(number, 10)),
+		Details: map[string]string{
+			"message_id":  messageID,
+			"status":      "sent",
+			"text_hmac":   auditor.Pseudonym(text),
+			"text_length": strconv.Itoa(len(text)),
+		},
+	}
+	if err != nil {
+		e.Details["status"] = "failed"
+	}
+	return e
 }
 
-func serverError(w http.ResponseWriter, encoder *json.Encoder, response string) {
-	code := http.StatusInternalServerError
-	w.WriteHeader(code)
-	encoder.Encode(NewErrorMsg(response))
+// ValidateMessage returns every problem of the message, the first one is reported as the response code.
+// It is shared by the handlers, the Validate middleware and the gRPC API so they hold messages to the
+// same rules. Text length is counted in bytes.
+func ValidateMessage(m Message, maxTexts int) []FieldError {
+	var errs []FieldError
+	if m.PhoneNumber == "" {
+		errs = append(errs, FieldError{Field: "phone_number", Code: CodeMissingPhoneNumber, Detail: "phone_number must not be empty"})
+	}
+	if len(m.Texts) == 0 {
+		errs = append(errs, FieldError{Field: "texts", Code: CodeMissingTexts, Detail: "texts must not be empty"})
+	}
+	if len(m.Texts) > maxTexts {
+		errs = append(errs, FieldError{Field: "texts", Code: CodeTooManyTexts, Detail: fmt.Sprintf("max allowed text count is %d", maxTexts)})
+	}
+	for i, t := range m.Texts {
+		if len(t) > MaxTextLength {
+			field := fmt.Sprintf("texts[%d]", i)
+			errs = append(errs, FieldError{Field: field, Code: CodeTextTooLong, Detail: fmt.Sprintf("%s must be at most %d bytes", field, MaxTextLength)})
+		}
+	}
+	return errs
 }
 
-// NewStatus new error message.
-func NewStatus(status []string) *Result {
-	return &Result{
-		Status: status,
+// countTexts returns the number of messages to send, empty texts are skipped.
+func countTexts(texts []string) int {
+	n := 0
+	for _, t := range texts {
+		if len(t) > 0 {
+			n++
+		}
 	}
+	return n
 }
 
-// NewErrorMsg new error message.
-func NewErrorMsg(message string) *ErrorMsg {
-	return &ErrorMsg{
-		Message: message,
+// MaxTexts returns the tenant's limit of texts per request, 3 by default.
+func MaxTexts(ctx context.Context) int {
+	if t, ok := tenant.FromContext(ctx); ok && t.Limits.MaxTextsPerRequest > 0 {
+		return t.Limits.MaxTextsPerRequest
 	}
+	return defaultMaxTexts
 }
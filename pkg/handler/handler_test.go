@@ -2,24 +2,36 @@ package handler_test
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
 	"github.com/nikhil-github/sms-app/pkg/wiring"
 )
 
 func TestSend(t *testing.T) {
 	type args struct {
-		Input io.Reader
+		Input  io.Reader
+		APIKey string
 	}
 	type fields struct {
 		MockExpectations func(m *mockFormatter, s *mockSender)
@@ -34,17 +46,60 @@ func TestSend(t *testing.T) {
 		Fields fields
 		Want   want
 	}{
+		{
+			Name:   "Failure - missing api key",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text"]}`), APIKey: "-"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusUnauthorized, Body: `{"code": "unauthenticated", "message": "api key missing"}`},
+		},
+		{
+			Name:   "Failure - unknown api key",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text"]}`), APIKey: "sms_unknown"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusUnauthorized, Body: `{"code": "unauthenticated", "message": "invalid api key"}`},
+		},
+		{
+			Name:   "Failure - api key without send scope",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text"]}`), APIKey: "read"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusForbidden, Body: `{"code": "forbidden", "message": "api key not allowed to send"}`},
+		},
+		{
+			Name:   "Failure - api key of unknown tenant",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text"]}`), APIKey: "orphan"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusForbidden, Body: `{"code": "tenant_not_assigned", "message": "api key not assigned to a tenant"}`},
+		},
+		{
+			Name:   "Failure - texts count greater than tenant limit",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text1","text2"]}`), APIKey: "small"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusBadRequest, Body: `{"code": "too_many_texts", "message": "max allowed text count is 1"}`},
+		},
 		{
 			Name:   "Failure - missing phone number",
 			Args:   args{Input: strings.NewReader(`{"texts":["text"]}`)},
 			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
-			Want:   want{Status: http.StatusBadRequest, Body: `{"message": "phone number missing"}`},
+			Want:   want{Status: http.StatusBadRequest, Body: `{"code": "missing_phone_number", "message": "phone_number must not be empty"}`},
+		},
+		{
+			Name:   "Failure - texts missing",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":[]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want: want{Status: http.StatusBadRequest, Body: `{"code": "missing_texts", "message": "texts must not be empty",
+				"errors": [{"field": "texts", "code": "missing_texts", "detail": "texts must not be empty"}]}`},
+		},
+		{
+			Name:   "Failure - phone number not a string",
+			Args:   args{Input: strings.NewReader(`{"phone_number":61400000000,"texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusBadRequest, Body: `{"code": "invalid_request", "message": "phone_number must be a string"}`},
 		},
 		{
 			Name:   "Failure - texts count great than 3",
 			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text1","text2","text3","text4"]}`)},
 			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
-			Want:   want{Status: http.StatusBadRequest, Body: `{"message": "max allowed text count is 3"}`},
+			Want:   want{Status: http.StatusBadRequest, Body: `{"code": "too_many_texts", "message": "max allowed text count is 3"}`},
 		},
 		{
 			Name: "Failure - Invalid phone number",
@@ -52,24 +107,136 @@ func TestSend(t *testing.T) {
 			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
 				m.OnFormat("wrong-number").Return(int64(0), false, nil)
 			}},
-			Want: want{Status: http.StatusBadRequest, Body: `{"message": "invalid phone number"}`},
+			Want: want{Status: http.StatusBadRequest, Body: `{"type": "urn:sms-app:problem:invalid_phone_number", "title": "Bad Request", "status": 400, "detail": "invalid phone number",
+				"instance": "/api/v1/sms/send", "code": "invalid_phone_number", "message": "invalid phone number",
+				"errors": [{"field": "phone_number", "code": "invalid_phone_number", "detail": "invalid phone number"}]}`},
+		},
+		{
+			Name: "Failure - recipient rate limit exceeded",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text1","text2","text3"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+			}},
+			Want: want{Status: http.StatusTooManyRequests, Body: `{"code": "rate_limited", "message": "rate limit exceeded for recipient"}`},
 		},
 		{
 			Name: "Partial Failure - send sms",
 			Args: args{Input: strings.NewReader(`{"phone_number":"wrong-number","texts":["text1","text2"]}`)},
 			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
 				m.OnFormat("wrong-number").Return(int64(88787878), true, nil)
-				s.OnSend(int64(88787878), "text1").Return(errors.New("error"))
-				s.OnSend(int64(88787878), "text2").Return(nil)
+				s.OnSend(int64(88787878), "text1").Return(service.Receipt{}, errors.New("error"))
+				s.OnSend(int64(88787878), "text2").Return(service.Receipt{}, nil)
+			}},
+			Want: want{Status: http.StatusOK, Body: `{"status": [ "failed","success"], "errors": [{"field": "texts[0]", "code": "send_failed", "detail": "unable to send sms"}]}`},
+		},
+		{
+			Name: "Partial Failure - provider rejected text",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["", "text2"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+				s.OnSend(int64(61400000000), "text2").Return(service.Receipt{}, &service.ProviderError{StatusCode: 400, Code: "FIELD_UNSAFE"})
+			}},
+			Want: want{Status: http.StatusOK, Body: `{"status": ["failed"], "errors": [{"field": "texts[1]", "code": "provider_rejected", "detail": "unable to send sms", "provider_code": "FIELD_UNSAFE"}]}`},
+		},
+		{
+			Name: "Partial Failure - provider out of credit",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text1"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+				s.OnSend(int64(61400000000), "text1").Return(service.Receipt{}, &service.ProviderError{StatusCode: 400, Code: "LEDGER_ERROR"})
+			}},
+			Want: want{Status: http.StatusOK, Body: `{"status": ["failed"], "errors": [{"field": "texts[0]", "code": "provider_insufficient_balance", "detail": "unable to send sms", "provider_code": "LEDGER_ERROR"}]}`},
+		},
+		{
+			Name:   "Failure - unparseable body",
+			Args:   args{Input: strings.NewReader(`{"phone_number":`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusBadRequest, Body: `{"code": "invalid_json", "message": "request body is not valid JSON"}`},
+		},
+		{
+			Name:   "Failure - body too large",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["text"]` + strings.Repeat(" ", 8*1024) + `}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Status: http.StatusRequestEntityTooLarge, Body: `{"code": "body_too_large", "message": "request body must be at most 6976 bytes"}`},
+		},
+		{
+			Name:   "Failure - every invalid field reported",
+			Args:   args{Input: strings.NewReader(`{"texts":["ok", "` + strings.Repeat("x", 161) + `"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want: want{Status: http.StatusBadRequest, Body: `{"code": "missing_phone_number", "errors": [
+				{"field": "phone_number", "code": "missing_phone_number", "detail": "phone_number must not be empty"},
+				{"field": "texts[1]", "code": "text_too_long", "detail": "texts[1] must be at most 160 bytes"}]}`},
+		},
+		{
+			Name:   "Failure - text too long in bytes",
+			Args:   args{Input: strings.NewReader(`{"phone_number":"10101010","texts":["` + strings.Repeat("é", 81) + `"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want: want{Status: http.StatusBadRequest, Body: `{"code": "text_too_long",
+				"errors": [{"field": "texts[0]", "code": "text_too_long", "detail": "texts[0] must be at most 160 bytes"}]}`},
+		},
+		{
+			Name: "Failure - number validation rejected by provider",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 400, Code: "FIELD_UNSAFE"})
+			}},
+			Want: want{Status: http.StatusBadGateway, Body: `{"code": "provider_rejected", "provider_code": "FIELD_UNSAFE"}`},
+		},
+		{
+			Name: "Failure - provider refused credentials",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 401, Code: "AUTH_FAILED"})
+			}},
+			Want: want{Status: http.StatusBadGateway, Body: `{"code": "provider_auth_failed", "provider_code": "AUTH_FAILED"}`},
+		},
+		{
+			Name: "Failure - provider refused number",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 400, Code: "FIELD_INVALID", Description: "msisdn is invalid"})
 			}},
-			Want: want{Status: http.StatusOK, Body: `{"status": [ "failed","success"]}`},
+			Want: want{Status: http.StatusBadRequest, Body: `{"code": "invalid_phone_number", "provider_code": "FIELD_INVALID",
+				"errors": [{"field": "phone_number", "code": "invalid_phone_number", "detail": "invalid phone number", "provider_code": "FIELD_INVALID"}]}`},
+		},
+		{
+			Name: "Failure - provider out of credit",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 400, Code: "LEDGER_ERROR"})
+			}},
+			Want: want{Status: http.StatusServiceUnavailable, Body: `{"code": "provider_insufficient_balance", "provider_code": "LEDGER_ERROR"}`},
+		},
+		{
+			Name: "Failure - provider throttled",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 429, Code: "OVER_LIMIT"})
+			}},
+			Want: want{Status: http.StatusServiceUnavailable, Body: `{"code": "provider_rate_limited", "provider_code": "OVER_LIMIT"}`},
+		},
+		{
+			Name: "Failure - provider error",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 500, Code: "INTERNAL_ERROR"})
+			}},
+			Want: want{Status: http.StatusServiceUnavailable, Body: `{"code": "provider_unavailable", "provider_code": "INTERNAL_ERROR"}`},
+		},
+		{
+			Name: "Failure - provider unavailable",
+			Args: args{Input: strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`)},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, errors.Wrap(breaker.ErrOpen, "transmit"))
+			}},
+			Want: want{Status: http.StatusServiceUnavailable, Body: `{"code": "provider_unavailable", "message": "sms provider unavailable"}`},
 		},
 		{
 			Name: "Success - send sms",
 			Args: args{Input: strings.NewReader(`{"phone_number":"wrong-number","texts":["text1"]}`)},
 			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
 				m.OnFormat("wrong-number").Return(int64(5555555), true, nil)
-				s.OnSend(int64(5555555), "text1").Return(nil)
+				s.OnSend(int64(5555555), "text1").Return(service.Receipt{}, nil)
 			}},
 			Want: want{Status: http.StatusOK, Body: `{"status": [ "success"]}`},
 		},
@@ -81,14 +248,49 @@ func TestSend(t *testing.T) {
 			var s mockSender
 
 			tt.Fields.MockExpectations(&m, &s)
+			keys := auth.NewKeys(auth.NewMemoryStore())
+			_, sendToken, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+			require.NoError(t, err, "issue send key")
+			_, readToken, err := keys.Issue(context.Background(), "read", "", []auth.Scope{auth.ScopeRead})
+			require.NoError(t, err, "issue read key")
+			_, smallToken, err := keys.Issue(context.Background(), "small", "small", []auth.Scope{auth.ScopeSend})
+			require.NoError(t, err, "issue small tenant key")
+			_, orphanToken, err := keys.Issue(context.Background(), "orphan", "deleted", []auth.Scope{auth.ScopeSend})
+			require.NoError(t, err, "issue orphan key")
+			tenants := tenant.NewStore([]tenant.Tenant{
+				{ID: tenant.DefaultID},
+				{ID: "small", Limits: tenant.Limits{MaxTextsPerRequest: 1}},
+			})
 			params := new(wiring.Params)
 			params.Formatter = &m
 			params.Sender = &s
 			params.Logger = logger
+			params.Authenticator = keys
+			params.Keys = keys
+			params.Tenants = tenants
+			params.Limiter = ratelimit.New(ratelimit.NewMemoryStore())
+			params.RateLimits = handler.RateLimits{PerRecipient: ratelimit.Limit{Count: 2, Period: time.Minute}}
+			params.Auditor = audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
 			mx := wiring.NewRouter(params)
 			ts := httptest.NewServer(mx)
 			defer ts.Close()
-			res, err := http.Post(ts.URL+"/api/v1/sms/send", "application/json", tt.Args.Input)
+			req, err := http.NewRequest("POST", ts.URL+"/api/v1/sms/send", tt.Args.Input)
+			require.NoError(t, err, "Error creating request")
+			req.Header.Set("Content-Type", "application/json")
+			switch tt.Args.APIKey {
+			case "":
+				req.Header.Set("Authorization", "Bearer "+sendToken)
+			case "read":
+				req.Header.Set("X-API-Key", readToken)
+			case "small":
+				req.Header.Set("X-API-Key", smallToken)
+			case "orphan":
+				req.Header.Set("X-API-Key", orphanToken)
+			case "-":
+			default:
+				req.Header.Set("Authorization", "Bearer "+tt.Args.APIKey)
+			}
+			res, err := http.DefaultClient.Do(req)
 			assert.NoError(t, err, "Error executing request")
 			defer res.Body.Close()
 			m.AssertExpectations(t)
@@ -96,12 +298,357 @@ func TestSend(t *testing.T) {
 			body, err := ioutil.ReadAll(res.Body)
 			assert.NoError(t, err, "Error reading response")
 			if tt.Want.Body != "" {
-				assert.JSONEq(t, tt.Want.Body, string(body), "response")
+				assertJSONSubset(t, tt.Want.Body, body)
+			}
+			if res.StatusCode >= http.StatusBadRequest {
+				assert.Equal(t, handler.ProblemContentType, res.Header.Get("Content-Type"), "content type")
+			}
+		})
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	type args struct {
+		Message handler.Message
+	}
+	type want struct {
+		Codes []string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : texts at the limit",
+			Args: args{Message: handler.Message{PhoneNumber: "0400000000", Texts: []string{strings.Repeat("x", 160), ""}}},
+			Want: want{Codes: []string{}},
+		},
+		{
+			Name: "Failure - empty number and texts",
+			Args: args{Message: handler.Message{}},
+			Want: want{Codes: []string{handler.CodeMissingPhoneNumber, handler.CodeMissingTexts}},
+		},
+		{
+			Name: "Failure - too many texts",
+			Args: args{Message: handler.Message{PhoneNumber: "0400000000", Texts: []string{"a", "b", "c"}}},
+			Want: want{Codes: []string{handler.CodeTooManyTexts}},
+		},
+		{
+			Name: "Failure - length counted in bytes",
+			Args: args{Message: handler.Message{PhoneNumber: "0400000000", Texts: []string{strings.Repeat("é", 81)}}},
+			Want: want{Codes: []string{handler.CodeTextTooLong}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			codes := []string{}
+			for _, e := range handler.ValidateMessage(tt.Args.Message, 2) {
+				codes = append(codes, e.Code)
+			}
+			assert.Equal(t, tt.Want.Codes, codes, "codes")
+		})
+	}
+}
+
+func TestSendWithoutMiddleware(t *testing.T) {
+	send := handler.Send(zap.NewNop(), &mockSender{}, &mockFormatter{}, nil, handler.RateLimits{}, nil, nil, nil)
+	res := httptest.NewRecorder()
+	send(res, httptest.NewRequest("POST", "/api/v1/sms/send", strings.NewReader(`{"phone_number":"","texts":[]}`)))
+	assert.Equal(t, http.StatusBadRequest, res.Code, "status")
+	assertJSONSubset(t, `{"code": "missing_phone_number", "errors": [
+		{"field": "phone_number", "code": "missing_phone_number", "detail": "phone_number must not be empty"},
+		{"field": "texts", "code": "missing_texts", "detail": "texts must not be empty"}]}`, res.Body.Bytes())
+}
+
+func TestSendRateLimit(t *testing.T) {
+	var m mockFormatter
+	var s mockSender
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	s.OnSend(int64(61400000000), "text").Return(service.Receipt{}, nil)
+
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	_, token, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+	_, other, err := keys.Issue(context.Background(), "other", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue other key")
+	params := &wiring.Params{
+		Logger:        zap.NewNop(),
+		Formatter:     &m,
+		Sender:        &s,
+		Authenticator: keys,
+		Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}}),
+		Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+		RateLimits: handler.RateLimits{
+			Global: ratelimit.Limit{Count: 3, Period: time.Minute},
+			PerKey: ratelimit.Limit{Count: 2, Period: time.Minute},
+		},
+		Auditor: audit.NewLog(audit.NewMemoryStore(), []byte("audit-key")),
+	}
+	ts := httptest.NewServer(wiring.NewRouter(params))
+	defer ts.Close()
+
+	send := func(token string) *http.Response {
+		req, err := http.NewRequest("POST", ts.URL+"/api/v1/sms/send", strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`))
+		require.NoError(t, err, "Error creating request")
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err, "Error executing request")
+		res.Body.Close()
+		return res
+	}
+
+	res := send(token)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "first request")
+	assert.Equal(t, "2", res.Header.Get("X-RateLimit-Limit"), "limit")
+	assert.Equal(t, "1", res.Header.Get("X-RateLimit-Remaining"), "remaining")
+
+	res = send(token)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "second request")
+	assert.Equal(t, "0", res.Header.Get("X-RateLimit-Remaining"), "remaining")
+
+	res = send(token)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "third request")
+	assert.Equal(t, "30", res.Header.Get("Retry-After"), "retry after")
+
+	// The key's rejected request did not use the global bucket, which has a request left.
+	res = send(other)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "other key request")
+
+	res = send(other)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "over the global limit")
+	assert.Equal(t, "3", res.Header.Get("X-RateLimit-Limit"), "global limit")
+	assert.Equal(t, "0", res.Header.Get("X-RateLimit-Remaining"), "global remaining")
+}
+
+func TestAllowMessages(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), tenant.Tenant{ID: "acme", Limits: tenant.Limits{MessagesPerMinute: 3}})
+	limiter := ratelimit.New(ratelimit.NewMemoryStore())
+	limits := handler.RateLimits{PerRecipient: ratelimit.Limit{Count: 3, Period: time.Minute}}
+
+	message, ok := handler.AllowMessages(ctx, zap.NewNop(), limiter, limits, 61400000000, 2)
+	assert.True(t, ok, "first recipient")
+	assert.Empty(t, message, "message")
+
+	message, ok = handler.AllowMessages(ctx, zap.NewNop(), limiter, limits, 61400000001, 2)
+	assert.False(t, ok, "over the tenant limit")
+	assert.Equal(t, "rate limit exceeded for tenant", message, "message")
+
+	// The messages rejected by the tenant's limit were given back to the recipient's bucket.
+	res, err := limiter.Take(ctx, "recipient:61400000001", limits.PerRecipient, 3)
+	require.NoError(t, err, "take")
+	assert.True(t, res.Allowed, "recipient refunded")
+}
+
+func TestSendV2(t *testing.T) {
+	var m mockFormatter
+	var s mockSender
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	s.OnSend(int64(61400000000), "see http://www.google.com").Return(service.Receipt{
+		Provider:  "transmit",
+		MessageID: "2937421",
+		Text:      "see http://bit.ly/xyz",
+		Links:     []service.Link{{URL: "http://www.google.com", Short: "http://bit.ly/xyz"}},
+	}, nil)
+	s.OnSend(int64(61400000000), "привет").Return(service.Receipt{Provider: "transmit"}, &service.ProviderError{StatusCode: 400, Code: "LEDGER_ERROR"})
+
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	_, token, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+	params := &wiring.Params{
+		Logger:        zap.NewNop(),
+		Formatter:     &m,
+		Sender:        &s,
+		Authenticator: keys,
+		Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}}),
+		Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+		RateLimits:    handler.RateLimits{},
+		Auditor:       audit.NewLog(audit.NewMemoryStore(), []byte("audit-key")),
+	}
+	ts := httptest.NewServer(wiring.NewRouter(params))
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/v2/sms/send", strings.NewReader(`{"phone_number":"0400000000","texts":["see http://www.google.com","","привет"]}`))
+	require.NoError(t, err, "Error creating request")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "Error executing request")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "status")
+	s.AssertExpectations(t)
+
+	var got handler.SendResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&got), "decode")
+	require.Len(t, got.Results, 3, "one result per text")
+	for i := range got.Results {
+		if got.Results[i].Status != handler.StatusSkipped {
+			assert.NotEmpty(t, got.Results[i].MessageID, "message id %d", i)
+			got.Results[i].MessageID = ""
+		}
+	}
+	assert.Equal(t, []handler.TextResult{
+		{
+			Index:             0,
+			Status:            handler.StatusSent,
+			Segments:          1,
+			Encoding:          "gsm7",
+			Provider:          "transmit",
+			ProviderMessageID: "2937421",
+			Links:             []service.Link{{URL: "http://www.google.com", Short: "http://bit.ly/xyz"}},
+		},
+		{Index: 1, Status: handler.StatusSkipped},
+		{
+			Index:        2,
+			Status:       handler.StatusFailed,
+			Segments:     1,
+			Encoding:     "ucs2",
+			Provider:     "transmit",
+			ErrorCode:    handler.CodeProviderBalance,
+			ProviderCode: "LEDGER_ERROR",
+		},
+	}, got.Results, "results")
+}
+
+func TestSendRequestID(t *testing.T) {
+	type args struct {
+		RequestID string
+	}
+	type want struct {
+		RequestID string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success - caller request id",
+			Args: args{RequestID: "req-123"},
+			Want: want{RequestID: "req-123"},
+		},
+		{
+			Name: "Success - request id assigned",
+		},
+		{
+			Name: "Success - invalid request id replaced",
+			Args: args{RequestID: "bad id"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var m mockFormatter
+			var s mockSender
+			m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+			s.OnSend(int64(61400000000), "text").Return(service.Receipt{}, errors.New("boom"))
+
+			core, logs := observer.New(zap.InfoLevel)
+			keys := auth.NewKeys(auth.NewMemoryStore())
+			key, token, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+			require.NoError(t, err, "issue send key")
+			auditLog := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+			params := &wiring.Params{
+				Logger:        zap.New(core),
+				Formatter:     &m,
+				Sender:        &s,
+				Authenticator: keys,
+				Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}}),
+				Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+				RateLimits:    handler.RateLimits{},
+				Auditor:       auditLog,
+			}
+			ts := httptest.NewServer(wiring.NewRouter(params))
+			defer ts.Close()
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/v1/sms/send", strings.NewReader(`{"phone_number":"0400000000","texts":["text"]}`))
+			require.NoError(t, err, "Error creating request")
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tt.Args.RequestID != "" {
+				req.Header.Set("X-Request-ID", tt.Args.RequestID)
 			}
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err, "Error executing request")
+			res.Body.Close()
+
+			id := res.Header.Get("X-Request-ID")
+			if tt.Want.RequestID != "" {
+				assert.Equal(t, tt.Want.RequestID, id, "request id")
+			} else {
+				assert.Len(t, id, 32, "generated request id")
+			}
+			entries := logs.FilterMessage("Unable to send sms").All()
+			require.Len(t, entries, 1, "log lines")
+			fields := entries[0].ContextMap()
+			assert.Equal(t, id, fields["request_id"], "request_id")
+			assert.Equal(t, key.ID, fields["key_id"], "key_id")
+			assert.Equal(t, tenant.DefaultID, fields["tenant_id"], "tenant_id")
+			assert.NotEmpty(t, fields["message_id"], "message_id")
+
+			sends, err := auditLog.Query(context.Background(), audit.Filter{Action: audit.ActionSend})
+			require.NoError(t, err, "query audit")
+			require.Len(t, sends, 1, "audit entries")
+			assert.Equal(t, key.ID, sends[0].Actor, "actor")
+			assert.Equal(t, auditLog.Pseudonym("61400000000"), sends[0].Target, "recipient pseudonym")
+			assert.Equal(t, auditLog.Pseudonym("text"), sends[0].Details["text_hmac"], "text pseudonym")
+			assert.Equal(t, id, sends[0].RequestID, "request id")
+			assert.Equal(t, fields["message_id"], sends[0].Details["message_id"], "message id")
+			assert.Equal(t, "failed", sends[0].Details["status"], "status")
+			assert.NotContains(t, sends[0].Details, "text", "text not kept")
 		})
 	}
 }
 
+func TestUnmatchedRouteRequestID(t *testing.T) {
+	type args struct {
+		Method string
+		Path   string
+	}
+	type want struct {
+		Status int
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Failure - unknown route",
+			Args: args{Method: "GET", Path: "/api/v1/unknown"},
+			Want: want{Status: http.StatusNotFound},
+		},
+		{
+			Name: "Failure - method not allowed",
+			Args: args{Method: "GET", Path: "/api/v1/sms/send"},
+			Want: want{Status: http.StatusMethodNotAllowed},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			params := &wiring.Params{Logger: zap.NewNop(), Authenticator: auth.NewKeys(auth.NewMemoryStore())}
+			ts := httptest.NewServer(wiring.NewRouter(params))
+			defer ts.Close()
+
+			req, err := http.NewRequest(tt.Args.Method, ts.URL+tt.Args.Path, nil)
+			require.NoError(t, err, "Error creating request")
+			req.Header.Set("X-Request-ID", "req-123")
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err, "Error executing request")
+			res.Body.Close()
+			assert.Equal(t, tt.Want.Status, res.StatusCode, "status")
+			assert.Equal(t, "req-123", res.Header.Get("X-Request-ID"), "request id")
+		})
+	}
+}
+
+// assertJSONSubset checks the fields of want in body, ignoring the fields want does not set such as request_id.
+func assertJSONSubset(t *testing.T, want string, body []byte) {
+	var w, got map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(want), &w), "want")
+	require.NoError(t, json.Unmarshal(body, &got), "response %s", body)
+	for k, v := range w {
+		assert.Equal(t, v, got[k], "response field %s", k)
+	}
+}
+
 type mockFormatter struct {
 	mock.Mock
 }
@@ -119,9 +666,9 @@ type mockSender struct {
 	mock.Mock
 }
 
-func (m *mockSender) Send(ctx context.Context, phoneNumber int64, text string) error {
+func (m *mockSender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
 	args := m.Called(ctx, phoneNumber, text)
-	return args.Error(0)
+	return args.Get(0).(service.Receipt), args.Error(1)
 }
 
 func (m *mockSender) OnSend(phoneNumber int64, text string) *mock.Call {
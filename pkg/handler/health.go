@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/health"
+)
+
+// Readiness provides method to run readiness checks.
+type Readiness interface {
+	Run(ctx context.Context) (bool, []health.Result)
+}
+
+// Breaker provides the state of a circuit breaker.
+type Breaker interface {
+	Name() string
+	State() breaker.State
+}
+
+// Build represent the version of the running binary.
+type Build struct {
+	Version   string
+	GitCommit string
+	StartedAt time.Time
+}
+
+// Health represent liveness and readiness responses.
+type Health struct {
+	Status   string          `json:"status"`
+	Checks   []health.Result `json:"checks,omitempty"`
+	Breakers []BreakerState  `json:"breakers,omitempty"`
+}
+
+// BreakerState represent the state of a circuit breaker.
+type BreakerState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Status represent the version and uptime of the app.
+type Status struct {
+	Version   string    `json:"version"`
+	GitCommit string    `json:"git_commit"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+}
+
+// Liveness reports the process is able to serve requests.
+// GET /healthz
+func Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&Health{Status: health.StatusOK})
+	}
+}
+
+// Ready reports whether dependencies are usable, with 503 when a critical check fails.
+// Open breakers are reported without failing readiness as every instance shares the provider.
+// GET /readyz
+func Ready(checks Readiness, breakers []Breaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		ready, results := checks.Run(r.Context())
+		res := Health{Status: health.StatusOK, Checks: results, Breakers: breakerStates(breakers)}
+		code := http.StatusOK
+		if !ready {
+			res.Status = health.StatusFailed
+			code = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(&res)
+	}
+}
+
+// AppStatus reports the version, commit and uptime.
+// GET /status
+func AppStatus(build Build) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&Status{
+			Version:   build.Version,
+			GitCommit: build.GitCommit,
+			StartedAt: build.StartedAt,
+			Uptime:    time.Since(build.StartedAt).Round(time.Second).String(),
+		})
+	}
+}
+
+func breakerStates(breakers []Breaker) []BreakerState {
+	states := make([]BreakerState, 0, len(breakers))
+	for _, b := range breakers {
+		states = append(states, BreakerState{Name: b.Name(), State: b.State().String()})
+	}
+	return states
+}
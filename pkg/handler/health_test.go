@@ -0,0 +1,115 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/health"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestHealth(t *testing.T) {
+	type args struct {
+		Path     string
+		StoreErr error
+		// Health calls the plain health listener instead of the API.
+		Health bool
+	}
+	type want struct {
+		Status int
+		Body   string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Liveness",
+			Args: args{Path: "/healthz"},
+			Want: want{Status: http.StatusOK, Body: `{"status":"ok"}`},
+		},
+		{
+			Name: "Ready",
+			Args: args{Path: "/readyz"},
+			Want: want{Status: http.StatusOK, Body: `{"status":"ok","checks":[{"name":"store","status":"ok","critical":true,"checked_at":"0001-01-01T00:00:00Z"}],"breakers":[{"name":"transmit","state":"closed"}]}`},
+		},
+		{
+			Name: "Not ready",
+			Args: args{Path: "/readyz", StoreErr: errors.New("connection refused")},
+			Want: want{Status: http.StatusServiceUnavailable, Body: `{"status":"failed","checks":[{"name":"store","status":"failed","critical":true,"error":"connection refused","checked_at":"0001-01-01T00:00:00Z"}],"breakers":[{"name":"transmit","state":"closed"}]}`},
+		},
+		{
+			Name: "Liveness on the health listener",
+			Args: args{Path: "/healthz", Health: true},
+			Want: want{Status: http.StatusOK, Body: `{"status":"ok"}`},
+		},
+		{
+			Name: "Not ready on the health listener",
+			Args: args{Path: "/readyz", StoreErr: errors.New("connection refused"), Health: true},
+			Want: want{Status: http.StatusServiceUnavailable, Body: `{"status":"failed","checks":[{"name":"store","status":"failed","critical":true,"error":"connection refused","checked_at":"0001-01-01T00:00:00Z"}],"breakers":[{"name":"transmit","state":"closed"}]}`},
+		},
+		{
+			Name: "Only probes on the health listener",
+			Args: args{Path: "/status", Health: true},
+			Want: want{Status: http.StatusNotFound, Body: "404 page not found\n"},
+		},
+		{
+			Name: "Status",
+			Args: args{Path: "/status"},
+			Want: want{Status: http.StatusOK, Body: `{"version":"1.0","git_commit":"abc123","started_at":"2019-05-01T00:00:00Z","uptime":"`},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			checks := stubReadiness{err: tt.Args.StoreErr}
+			params := &wiring.Params{
+				Logger:    zap.NewNop(),
+				Readiness: checks,
+				Breakers:  []handler.Breaker{breaker.New("transmit", 5, time.Minute)},
+				Build:     handler.Build{Version: "1.0", GitCommit: "abc123", StartedAt: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)},
+			}
+			rtr := wiring.NewRouter(params)
+			if tt.Args.Health {
+				rtr = wiring.NewHealthRouter(params)
+			}
+			ts := httptest.NewServer(rtr)
+			defer ts.Close()
+			res, err := http.Get(ts.URL + tt.Args.Path)
+			require.NoError(t, err, "Error executing request")
+			defer res.Body.Close()
+			assert.Equal(t, tt.Want.Status, res.StatusCode, "status")
+			body, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err, "Error reading response")
+			if tt.Want.Status == http.StatusNotFound || tt.Args.Path == "/status" {
+				assert.Contains(t, string(body), tt.Want.Body, "response")
+				return
+			}
+			assert.JSONEq(t, tt.Want.Body, string(body), "response")
+		})
+	}
+}
+
+type stubReadiness struct {
+	err error
+}
+
+func (s stubReadiness) Run(ctx context.Context) (bool, []health.Result) {
+	r := health.Result{Name: "store", Status: health.StatusOK, Critical: true}
+	if s.err != nil {
+		r.Status = health.StatusFailed
+		r.Error = s.err.Error()
+	}
+	return s.err == nil, []health.Result{r}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+)
+
+const defaultHistoryLimit = 50
+
+// HistoryRecorder provides method to keep the messages sent.
+type HistoryRecorder interface {
+	Record(ctx context.Context, m history.Message) (history.Message, error)
+}
+
+// HistoryReader provides method to search the messages sent.
+type HistoryReader interface {
+	Search(ctx context.Context, f history.Filter) ([]history.Message, error)
+}
+
+// HistoryPage represent a page of messages, newest first.
+type HistoryPage struct {
+	Messages []history.Message `json:"messages"`
+	// NextCursor is the cursor parameter of the next page, empty when there are no more messages.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// historyColumns are the columns of the CSV answer.
+var historyColumns = []string{"id", "time", "recipient", "status", "text", "segments", "encoding", "provider", "provider_message_id", "error_code", "provider_code", "key_id", "request_id"}
+
+// History handles request to search the messages sent for the caller's tenant, newest first.
+// Filters are recipient, formatted like a number to send to, since and until (RFC 3339), status,
+// key_id, provider and text, matching messages containing it. Pages continue from cursor, the
+// next cursor is in the body and the Link header. The answer is CSV when format is csv or the
+// client accepts text/csv.
+// GET /api/v1/sms
+func History(logger *zap.Logger, formatter Formatter, reader HistoryReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.From(ctx, logger)
+
+		f, err := historyFilter(r)
+		if err != nil {
+			responseBadRequest(w, r, CodeInvalidRequest, err.Error())
+			return
+		}
+		f.TenantID = tenantID(ctx)
+		if recipient := r.URL.Query().Get("recipient"); recipient != "" {
+			number, valid, err := formatter.Format(ctx, recipient)
+			if err != nil {
+				formatError(w, r, logger, err)
+				return
+			}
+			if !valid {
+				p := NewProblem(http.StatusBadRequest, CodeInvalidPhoneNumber, "invalid phone number")
+				p.Errors = []FieldError{{Field: "recipient", Code: CodeInvalidPhoneNumber, Detail: "invalid phone number"}}
+				writeProblem(w, r, p)
+				return
+			}
+			f.Recipient = number
+		}
+
+		messages, err := reader.Search(ctx, f)
+		if err != nil {
+			logger.Error("Unable to search message history", zap.Error(err))
+			serverError(w, r, "unable to search message history")
+			return
+		}
+		page := HistoryPage{Messages: messages}
+		if page.Messages == nil {
+			page.Messages = []history.Message{}
+		}
+		if len(messages) == f.Limit {
+			page.NextCursor = strconv.FormatInt(messages[len(messages)-1].Seq, 10)
+			q := r.URL.Query()
+			q.Set("cursor", page.NextCursor)
+			w.Header().Set("Link", `<`+r.URL.Path+`?`+q.Encode()+`>; rel="next"`)
+		}
+
+		if !wantsCSV(r) {
+			writeJSON(w, &page)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		out := csv.NewWriter(w)
+		out.Write(historyColumns)
+		for _, m := range page.Messages {
+			row := []string{
+				m.ID, m.Time.Format(time.RFC3339), strconv.FormatInt(m.Recipient, 10), m.Status, m.Text,
+				strconv.Itoa(m.Segments), m.Encoding, m.Provider, m.ProviderMessageID, m.ErrorCode, m.ProviderCode,
+				m.KeyID, m.RequestID,
+			}
+			for i := range row {
+				row[i] = csvCell(row[i])
+			}
+			out.Write(row)
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			logger.Error("Unable to write message history", zap.Error(err))
+		}
+	}
+}
+
+// recordHistory keeps the text as sent to number with its result, recorder may be nil.
+// Failures are logged and do not fail the send as the text already went out.
+func recordHistory(ctx context.Context, logger *zap.Logger, recorder HistoryRecorder, number int64, text string, tr TextResult) {
+	if recorder == nil {
+		return
+	}
+	_, err := recorder.Record(ctx, history.Message{
+		ID:                tr.MessageID,
+		TenantID:          tenantID(ctx),
+		KeyID:             callerID(ctx),
+		RequestID:         logging.RequestID(ctx),
+		Recipient:         number,
+		Text:              text,
+		Status:            tr.Status,
+		Segments:          tr.Segments,
+		Encoding:          tr.Encoding,
+		Provider:          tr.Provider,
+		ProviderMessageID: tr.ProviderMessageID,
+		ErrorCode:         tr.ErrorCode,
+		ProviderCode:      tr.ProviderCode,
+	})
+	if err != nil {
+		logger.Error("Unable to record message history", zap.Error(err))
+	}
+}
+
+// csvCell prefixes cells a spreadsheet would run as a formula with a quote so they open as text.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+func historyFilter(r *http.Request) (history.Filter, error) {
+	q := r.URL.Query()
+	f := history.Filter{
+		KeyID:    q.Get("key_id"),
+		Provider: q.Get("provider"),
+		Text:     q.Get("text"),
+		Limit:    defaultHistoryLimit,
+	}
+	var err error
+	switch status := q.Get("status"); status {
+	case "", StatusSent, StatusFailed:
+		f.Status = status
+	default:
+		return f, errors.New("status must be sent or failed")
+	}
+	switch q.Get("format") {
+	case "", "json", "csv":
+	default:
+		return f, errors.New("format must be json or csv")
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("invalid since")
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("invalid until")
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		if f.Before, err = strconv.ParseInt(v, 10, 64); err != nil || f.Before < 1 {
+			return f, errors.New("invalid cursor")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > 1000 {
+			return f, errors.New("limit must be between 1 and 1000")
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,172 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestHistory(t *testing.T) {
+	var m mockFormatter
+	var s mockSender
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	m.OnFormat("0400000001").Return(int64(61400000001), true, nil)
+	m.OnFormat("0499999999").Return(int64(0), false, nil)
+	s.OnSend(int64(61400000000), "Your order shipped").Return(service.Receipt{Provider: service.Provider, MessageID: "1"}, nil)
+	s.OnSend(int64(61400000000), "see http://www.google.com").Return(service.Receipt{Provider: service.Provider, MessageID: "2", Text: "see http://bit.ly/xyz"}, nil)
+	s.OnSend(int64(61400000000), "@fails").Return(service.Receipt{Provider: service.Provider}, &service.ProviderError{StatusCode: 400, Code: "RECIPIENTS_ERROR"})
+	s.OnSend(int64(61400000001), "Order for someone else").Return(service.Receipt{Provider: service.Provider, MessageID: "3"}, nil)
+
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	sendKey, sendToken, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+	_, readToken, err := keys.Issue(context.Background(), "read", "", []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err, "issue read key")
+	_, otherToken, err := keys.Issue(context.Background(), "other", "other", []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err, "issue key of other tenant")
+	messages := history.New(history.NewMemoryStore())
+	params := &wiring.Params{
+		Logger:        zap.NewNop(),
+		Formatter:     &m,
+		Sender:        &s,
+		Authenticator: keys,
+		Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}, {ID: "other"}}),
+		Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+		RateLimits:    handler.RateLimits{},
+		Auditor:       audit.NewLog(audit.NewMemoryStore(), []byte("audit-key")),
+		Recorder:      messages,
+		History:       messages,
+	}
+	ts := httptest.NewServer(wiring.NewRouter(params))
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"phone_number":"0400000000","texts":["Your order shipped","see http://www.google.com","@fails"]}`,
+		`{"phone_number":"0400000001","texts":["Order for someone else"]}`,
+	} {
+		res := do(t, "POST", ts.URL+"/api/v1/sms/send", sendToken, body, "")
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode, "send status")
+	}
+
+	type args struct {
+		Query  string
+		Token  string
+		Accept string
+	}
+	type want struct {
+		Status int
+		Texts  []string
+		Next   bool
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : newest first",
+			Args: args{Token: readToken},
+			Want: want{Status: http.StatusOK, Texts: []string{"Order for someone else", "@fails", "see http://bit.ly/xyz", "Your order shipped"}},
+		},
+		{
+			Name: "Success : by recipient formatted like a send",
+			Args: args{Query: "?recipient=0400000000&status=sent", Token: readToken},
+			Want: want{Status: http.StatusOK, Texts: []string{"see http://bit.ly/xyz", "Your order shipped"}},
+		},
+		{
+			Name: "Success : by text, key and provider",
+			Args: args{Query: "?text=ORDER&key_id=" + sendKey.ID + "&provider=" + service.Provider, Token: readToken},
+			Want: want{Status: http.StatusOK, Texts: []string{"Order for someone else", "Your order shipped"}},
+		},
+		{
+			Name: "Success : first page",
+			Args: args{Query: "?limit=3", Token: readToken},
+			Want: want{Status: http.StatusOK, Texts: []string{"Order for someone else", "@fails", "see http://bit.ly/xyz"}, Next: true},
+		},
+		{
+			Name: "Success : page after the cursor",
+			Args: args{Query: "?limit=3&cursor=2", Token: readToken},
+			Want: want{Status: http.StatusOK, Texts: []string{"Your order shipped"}},
+		},
+		{
+			Name: "Success : other tenants see their own messages",
+			Args: args{Token: otherToken},
+			Want: want{Status: http.StatusOK, Texts: []string{}},
+		},
+		{
+			Name: "Failure - invalid recipient",
+			Args: args{Query: "?recipient=0499999999", Token: readToken},
+			Want: want{Status: http.StatusBadRequest},
+		},
+		{
+			Name: "Failure - invalid date",
+			Args: args{Query: "?since=yesterday", Token: readToken},
+			Want: want{Status: http.StatusBadRequest},
+		},
+		{
+			Name: "Failure - send scope cannot read",
+			Args: args{Token: sendToken},
+			Want: want{Status: http.StatusForbidden},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			res := do(t, "GET", ts.URL+"/api/v1/sms"+tt.Args.Query, tt.Args.Token, "", tt.Args.Accept)
+			defer res.Body.Close()
+			require.Equal(t, tt.Want.Status, res.StatusCode, "status")
+			if tt.Want.Status != http.StatusOK {
+				return
+			}
+			var page handler.HistoryPage
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&page), "decode")
+			texts := []string{}
+			for _, m := range page.Messages {
+				texts = append(texts, m.Text)
+			}
+			assert.Equal(t, tt.Want.Texts, texts, "texts")
+			assert.Equal(t, tt.Want.Next, page.NextCursor != "", "next cursor")
+			assert.Equal(t, tt.Want.Next, res.Header.Get("Link") != "", "link header")
+		})
+	}
+
+	res := do(t, "GET", ts.URL+"/api/v1/sms?status=failed", readToken, "", "text/csv")
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode, "csv status")
+	assert.Equal(t, "text/csv; charset=UTF-8", res.Header.Get("Content-Type"), "content type")
+	rows, err := csv.NewReader(res.Body).ReadAll()
+	require.NoError(t, err, "csv")
+	require.Len(t, rows, 2, "header and one message")
+	assert.Equal(t, "id", rows[0][0], "header")
+	assert.Equal(t, []string{"61400000000", "failed", "'@fails"}, rows[1][2:5], "recipient, status and text, formula escaped")
+	assert.Equal(t, handler.CodeInvalidPhoneNumber, rows[1][9], "error code")
+}
+
+func do(t *testing.T, method string, url string, token string, body string, accept string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err, "Error creating request")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "Error executing request")
+	return res
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+// KeyRequest represent payload to issue an API key.
+type KeyRequest struct {
+	Name     string   `json:"name"`
+	TenantID string   `json:"tenant_id"`
+	Scopes   []string `json:"scopes"`
+}
+
+// IssuedKey represent an API key along with its plain token.
+type IssuedKey struct {
+	auth.Key
+	Token string `json:"token"`
+}
+
+// KeyList represent the list of API keys.
+type KeyList struct {
+	Keys []auth.Key `json:"keys"`
+}
+
+// KeyManager provides methods to administer API keys.
+type KeyManager interface {
+	Issue(ctx context.Context, name string, tenantID string, scopes []auth.Scope) (auth.Key, string, error)
+	Rotate(ctx context.Context, id string) (auth.Key, string, error)
+	Revoke(ctx context.Context, id string) (auth.Key, error)
+	List(ctx context.Context) ([]auth.Key, error)
+}
+
+// IssueKey handles request to create an API key.
+// Keys without a tenant send on behalf of the default tenant.
+// POST /api/v1/admin/keys
+func IssueKey(logger *zap.Logger, keys KeyManager, tenants TenantFinder, auditor Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		var req KeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Unable to parse JSON from request body", zap.Error(err))
+			responseBadRequest(w, r, CodeInvalidJSON, "invalid request body")
+			return
+		}
+		if req.Name == "" {
+			responseBadRequest(w, r, CodeInvalidRequest, "name missing")
+			return
+		}
+		scopes, err := auth.ParseScopes(req.Scopes)
+		if err != nil {
+			responseBadRequest(w, r, CodeInvalidRequest, err.Error())
+			return
+		}
+		if req.TenantID != "" {
+			_, err := tenants.Get(r.Context(), req.TenantID)
+			if err == tenant.ErrNotFound {
+				responseBadRequest(w, r, CodeInvalidRequest, "unknown tenant")
+				return
+			}
+			if err != nil {
+				logger.Error("Unable to find tenant", zap.String("issued_tenant_id", req.TenantID), zap.Error(err))
+				serverError(w, r, "unable to issue api key")
+				return
+			}
+		}
+
+		key, token, err := keys.Issue(r.Context(), req.Name, req.TenantID, scopes)
+		if err != nil {
+			logger.Error("Unable to issue api key", zap.Error(err))
+			serverError(w, r, "unable to issue api key")
+			return
+		}
+		logger.Info("Api key issued", zap.String("issued_key_id", key.ID), zap.String("issued_tenant_id", key.TenantID))
+		record(r.Context(), logger, auditor, audit.Entry{Action: audit.ActionKeyIssue, Target: key.ID, Details: map[string]string{
+			"name":      key.Name,
+			"tenant_id": key.TenantID,
+			"scopes":    scopeList(key.Scopes),
+		}})
+		w.WriteHeader(http.StatusCreated)
+		enc.Encode(&IssuedKey{Key: key, Token: token})
+	}
+}
+
+// ListKeys handles request to list API keys.
+// GET /api/v1/admin/keys
+func ListKeys(logger *zap.Logger, keys KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		list, err := keys.List(r.Context())
+		if err != nil {
+			logger.Error("Unable to list api keys", zap.Error(err))
+			serverError(w, r, "unable to list api keys")
+			return
+		}
+		if list == nil {
+			list = []auth.Key{}
+		}
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&KeyList{Keys: list})
+	}
+}
+
+// RotateKey handles request to replace the token of an API key.
+// POST /api/v1/admin/keys/{id}/rotate
+func RotateKey(logger *zap.Logger, keys KeyManager, auditor Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		id := mux.Vars(r)["id"]
+		key, token, err := keys.Rotate(r.Context(), id)
+		if errors.Cause(err) == auth.ErrNotFound {
+			notFound(w, r, err.Error())
+			return
+		}
+		if err != nil {
+			logger.Error("Unable to rotate api key", zap.String("rotated_key_id", id), zap.Error(err))
+			serverError(w, r, "unable to rotate api key")
+			return
+		}
+		logger.Info("Api key rotated", zap.String("rotated_key_id", key.ID))
+		record(r.Context(), logger, auditor, audit.Entry{Action: audit.ActionKeyRotate, Target: key.ID})
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&IssuedKey{Key: key, Token: token})
+	}
+}
+
+// RevokeKey handles request to revoke an API key.
+// DELETE /api/v1/admin/keys/{id}
+func RevokeKey(logger *zap.Logger, keys KeyManager, auditor Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		id := mux.Vars(r)["id"]
+		key, err := keys.Revoke(r.Context(), id)
+		if errors.Cause(err) == auth.ErrNotFound {
+			notFound(w, r, err.Error())
+			return
+		}
+		if err != nil {
+			logger.Error("Unable to revoke api key", zap.String("revoked_key_id", id), zap.Error(err))
+			serverError(w, r, "unable to revoke api key")
+			return
+		}
+		logger.Info("Api key revoked", zap.String("revoked_key_id", key.ID))
+		record(r.Context(), logger, auditor, audit.Entry{Action: audit.ActionKeyRevoke, Target: key.ID})
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&key)
+	}
+}
+
+func scopeList(scopes []auth.Scope) string {
+	list := make([]string, len(scopes))
+	for i, s := range scopes {
+		list[i] = string(s)
+	}
+	return strings.Join(list, ",")
+}
+
+func callerID(ctx context.Context) string {
+	key, ok := auth.KeyFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return key.ID
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestKeys(t *testing.T) {
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	_, adminToken, err := keys.Issue(context.Background(), "admin", "", []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err, "issue admin key")
+	_, sendToken, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+
+	tenants := tenant.NewStore([]tenant.Tenant{{ID: "retail"}})
+	auditLog := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+	params := &wiring.Params{Logger: zap.NewNop(), Authenticator: keys, Keys: keys, Tenants: tenants, Auditor: auditLog, Audit: auditLog}
+	ts := httptest.NewServer(wiring.NewRouter(params))
+	defer ts.Close()
+
+	do := func(method, path, token, body string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err, "Error creating request")
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err, "Error executing request")
+		return res
+	}
+
+	res := do("POST", "/api/v1/admin/keys", sendToken, `{"name":"x","scopes":["send"]}`)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "non admin key")
+
+	res = do("POST", "/api/v1/admin/keys", adminToken, `{"name":"x","scopes":["nope"]}`)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "invalid scope")
+
+	res = do("POST", "/api/v1/admin/keys", adminToken, `{"name":"x","tenant_id":"unknown","scopes":["send"]}`)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "unknown tenant")
+
+	res = do("POST", "/api/v1/admin/keys", adminToken, `{"name":"partner","tenant_id":"retail","scopes":["send","read"]}`)
+	var issued handler.IssuedKey
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&issued), "decode issued key")
+	res.Body.Close()
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "issue")
+	assert.NotEmpty(t, issued.Token, "token")
+	assert.Equal(t, []auth.Scope{auth.ScopeSend, auth.ScopeRead}, issued.Scopes, "scopes")
+	assert.Equal(t, "retail", issued.TenantID, "tenant")
+
+	res = do("POST", "/api/v1/admin/keys/"+issued.ID+"/rotate", adminToken, "")
+	var rotated handler.IssuedKey
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&rotated), "decode rotated key")
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "rotate")
+	assert.NotEqual(t, issued.Token, rotated.Token, "rotated token")
+
+	res = do("DELETE", "/api/v1/admin/keys/"+issued.ID, adminToken, "")
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "revoke")
+	_, err = keys.Authenticate(context.Background(), rotated.Token)
+	assert.Equal(t, auth.ErrUnauthenticated, err, "revoked key")
+
+	res = do("DELETE", "/api/v1/admin/keys/unknown", adminToken, "")
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "unknown key")
+
+	res = do("GET", "/api/v1/admin/keys", adminToken, "")
+	var list handler.KeyList
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&list), "decode key list")
+	res.Body.Close()
+	assert.Len(t, list.Keys, 3, "keys")
+
+	res = do("GET", "/api/v1/admin/audit", sendToken, "")
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "audit with non admin key")
+
+	res = do("GET", "/api/v1/admin/audit?limit=2", adminToken, "")
+	var page handler.AuditPage
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&page), "decode audit page")
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "audit")
+	require.Len(t, page.Entries, 2, "audit entries")
+	assert.Equal(t, audit.ActionKeyIssue, page.Entries[0].Action, "issue entry")
+	assert.Equal(t, issued.ID, page.Entries[0].Target, "issue target")
+	assert.Equal(t, "send,read", page.Entries[0].Details["scopes"], "issue scopes")
+	assert.Equal(t, audit.ActionKeyRotate, page.Entries[1].Action, "rotate entry")
+	assert.Equal(t, int64(2), page.Next, "next page")
+
+	res = do("GET", "/api/v1/admin/audit?action=key.revoke", adminToken, "")
+	page = handler.AuditPage{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&page), "decode audit page")
+	res.Body.Close()
+	require.Len(t, page.Entries, 1, "revoke entries")
+	assert.Equal(t, page.Entries[0].PrevHash, auditLog.Hash(mustEntry(t, auditLog, 2)), "chained to rotate entry")
+
+	res = do("GET", "/api/v1/admin/audit/export", adminToken, "")
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err, "read export")
+	assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"), "export content type")
+	assert.Len(t, strings.Split(strings.TrimSpace(string(body)), "\n"), 3, "exported lines")
+
+	res = do("GET", "/api/v1/admin/audit/verify", adminToken, "")
+	var verification handler.AuditVerification
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&verification), "decode verification")
+	res.Body.Close()
+	assert.Equal(t, handler.AuditVerification{Valid: true, Entries: 3}, verification, "verification")
+
+	res = do("GET", "/api/v1/admin/audit?since=yesterday", adminToken, "")
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "invalid filter")
+}
+
+func mustEntry(t *testing.T, log *audit.Log, seq int64) audit.Entry {
+	entries, err := log.Query(context.Background(), audit.Filter{After: seq - 1, Limit: 1})
+	require.NoError(t, err, "query audit")
+	require.Len(t, entries, 1, "audit entry")
+	return entries[0]
+}
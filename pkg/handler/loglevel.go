@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+)
+
+// LevelSetter provides methods to read and change the log level, such as zap.AtomicLevel.
+type LevelSetter interface {
+	Level() zapcore.Level
+	SetLevel(zapcore.Level)
+}
+
+// LogLevel represent the log level payload.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel handles request to read the log level.
+// GET /api/v1/admin/log/level
+func GetLogLevel(level LevelSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&LogLevel{Level: level.Level().String()})
+	}
+}
+
+// SetLogLevel handles request to change the log level of the running app.
+// The level lasts until the next restart or a reload changing LOG_LEVEL.
+// PUT /api/v1/admin/log/level
+func SetLogLevel(logger *zap.Logger, level LevelSetter, auditor Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		var req LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Unable to parse JSON from request body", zap.Error(err))
+			responseBadRequest(w, r, CodeInvalidJSON, "invalid request body")
+			return
+		}
+		var l zapcore.Level
+		if err := l.UnmarshalText([]byte(req.Level)); err != nil || req.Level == "" {
+			responseBadRequest(w, r, CodeInvalidRequest, "unknown log level")
+			return
+		}
+		previous := level.Level()
+		level.SetLevel(l)
+		// Logged at warn so the change is visible whatever the new level.
+		logger.Warn("Log level changed", zap.String("from", previous.String()), zap.String("to", l.String()))
+		record(r.Context(), logger, auditor, audit.Entry{Action: audit.ActionLogLevel, Target: l.String(), Details: map[string]string{"previous": previous.String()}})
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&LogLevel{Level: l.String()})
+	}
+}
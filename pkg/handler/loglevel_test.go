@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestLogLevel(t *testing.T) {
+	type args struct {
+		Method string
+		Body   string
+		Token  string
+	}
+	type want struct {
+		Status int
+		Body   string
+		Level  zapcore.Level
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success - read level",
+			Args: args{Method: "GET", Token: "admin"},
+			Want: want{Status: http.StatusOK, Body: `{"level":"error"}`, Level: zapcore.ErrorLevel},
+		},
+		{
+			Name: "Success - enable debug",
+			Args: args{Method: "PUT", Body: `{"level":"DEBUG"}`, Token: "admin"},
+			Want: want{Status: http.StatusOK, Body: `{"level":"debug"}`, Level: zapcore.DebugLevel},
+		},
+		{
+			Name: "Failure - unknown level",
+			Args: args{Method: "PUT", Body: `{"level":"verbose"}`, Token: "admin"},
+			Want: want{Status: http.StatusBadRequest, Body: `{"code":"invalid_request","message":"unknown log level"}`, Level: zapcore.ErrorLevel},
+		},
+		{
+			Name: "Failure - non admin key",
+			Args: args{Method: "PUT", Body: `{"level":"debug"}`, Token: "send"},
+			Want: want{Status: http.StatusForbidden, Body: `{"code":"forbidden","message":"api key not allowed to admin"}`, Level: zapcore.ErrorLevel},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			keys := auth.NewKeys(auth.NewMemoryStore())
+			_, adminToken, err := keys.Issue(context.Background(), "admin", "", []auth.Scope{auth.ScopeAdmin})
+			require.NoError(t, err, "issue admin key")
+			_, sendToken, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+			require.NoError(t, err, "issue send key")
+			level := zap.NewAtomicLevelAt(zapcore.ErrorLevel)
+			auditLog := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+			params := &wiring.Params{Logger: zap.NewNop(), Authenticator: keys, Level: level, Auditor: auditLog}
+			ts := httptest.NewServer(wiring.NewRouter(params))
+			defer ts.Close()
+
+			req, err := http.NewRequest(tt.Args.Method, ts.URL+"/api/v1/admin/log/level", strings.NewReader(tt.Args.Body))
+			require.NoError(t, err, "Error creating request")
+			token := adminToken
+			if tt.Args.Token == "send" {
+				token = sendToken
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err, "Error executing request")
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err, "Error reading response")
+
+			assert.Equal(t, tt.Want.Status, res.StatusCode, "status")
+			assertJSONSubset(t, tt.Want.Body, body)
+			assert.Equal(t, tt.Want.Level, level.Level(), "level")
+			if tt.Args.Method == "PUT" && tt.Want.Status == http.StatusOK {
+				entries, err := auditLog.Query(context.Background(), audit.Filter{Action: audit.ActionLogLevel})
+				require.NoError(t, err, "audit")
+				require.Len(t, entries, 1, "audit entries")
+				assert.Equal(t, "error", entries[0].Details["previous"], "previous level")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/openapi"
+)
+
+// OpenAPI serves the OpenAPI document of the app.
+// GET /openapi.json
+func OpenAPI(spec []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(spec)
+	}
+}
+
+// bodyOverhead is the room left in request bodies for fields other than texts.
+const bodyOverhead = 4 * 1024
+
+// maxBodySize returns the most bytes read from a request body: the tenant's texts at their longest,
+// with every byte escaped in JSON as \u00XX, and the other fields.
+func maxBodySize(ctx context.Context) int64 {
+	return int64(MaxTexts(ctx)*MaxTextLength*6 + bodyOverhead)
+}
+
+// bodyChecks are the rules the spec cannot express, by the operation whose request body they check.
+var bodyChecks = map[string]func(ctx context.Context, body []byte) []FieldError{
+	"send":   checkMessage,
+	"sendV2": checkMessage,
+}
+
+// checkMessage applies ValidateMessage to a send request body. Bodies that are not a message are
+// left to the spec.
+func checkMessage(ctx context.Context, body []byte) []FieldError {
+	var m Message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil
+	}
+	return ValidateMessage(m, MaxTexts(ctx))
+}
+
+// hasFieldError reports whether errs has an error with the code for the field.
+func hasFieldError(errs []FieldError, field string, code string) bool {
+	for _, e := range errs {
+		if e.Field == field && e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks requests against the operation the spec gives their route and the rules of
+// bodyChecks, answering 400 with every violation before next is called. The first violation gives
+// the response code. A rule and the spec reporting the same code for a field are reported once.
+// Bodies over maxBodySize are refused with 413. Requests to routes missing from the spec are passed through.
+func Validate(logger *zap.Logger, doc *openapi.Document) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, _, ok := doc.Operation(r.Method, r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var body []byte
+			if op.RequestBody != nil && r.Body != nil {
+				var err error
+				limit := maxBodySize(r.Context())
+				if body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit)); err != nil {
+					if _, ok := err.(*http.MaxBytesError); ok {
+						logging.From(r.Context(), logger).Warn("Request body too large", zap.Int64("limit", limit))
+						writeProblem(w, r, NewProblem(http.StatusRequestEntityTooLarge, CodeBodyTooLarge, fmt.Sprintf("request body must be at most %d bytes", limit)))
+						return
+					}
+					logging.From(r.Context(), logger).Warn("Unable to read request body", zap.Error(err))
+					responseBadRequest(w, r, CodeInvalidRequest, "unable to read request body")
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			violations, err := doc.ValidateRequest(op, r.URL.Query(), body)
+			if err == openapi.ErrInvalidJSON {
+				logging.From(r.Context(), logger).Warn("Unable to parse JSON from request body")
+				responseBadRequest(w, r, CodeInvalidJSON, "request body is not valid JSON")
+				return
+			}
+			var errs []FieldError
+			if check, ok := bodyChecks[op.OperationID]; ok {
+				errs = check(r.Context(), body)
+			}
+			for _, v := range violations {
+				code := v.Code
+				if code == "" {
+					code = CodeInvalidRequest
+				}
+				if !hasFieldError(errs, v.Field, code) {
+					errs = append(errs, FieldError{Field: v.Field, Code: code, Detail: v.Message})
+				}
+			}
+			if len(errs) > 0 {
+				p := NewProblem(http.StatusBadRequest, errs[0].Code, errs[0].Detail)
+				p.Errors = errs
+				writeProblem(w, r, p)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
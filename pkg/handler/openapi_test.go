@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/metrics"
+	"github.com/nikhil-github/sms-app/pkg/openapi"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+// TestOpenAPIRoutes fails when a route is added without its operation, or an operation without its route.
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := openapi.MustSpec()
+	router := wiring.NewRouter(specParams(t, &mockFormatter{}, &mockSender{}))
+
+	routes := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range methods {
+			if m == "OPTIONS" {
+				continue
+			}
+			routes[m+" "+template] = true
+			op, specPath, ok := doc.Operation(m, template)
+			if assert.True(t, ok, "%s %s not in spec", m, template) {
+				assert.Equal(t, template, specPath, "%s path", op.OperationID)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err, "walk")
+
+	for path, item := range doc.Paths {
+		for method := range *item {
+			key := strings.ToUpper(method) + " " + path
+			assert.True(t, routes[key], "%s in spec has no route", key)
+		}
+	}
+}
+
+// TestOpenAPIResponses fails when a handler answers with a response the spec does not describe.
+func TestOpenAPIResponses(t *testing.T) {
+	doc := openapi.MustSpec()
+	var m mockFormatter
+	var s mockSender
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	m.OnFormat("0411111111").Return(int64(0), false, &service.ProviderError{StatusCode: 400, Code: "LEDGER_ERROR"})
+	s.OnSend(int64(61400000000), "see http://www.google.com").Return(service.Receipt{
+		Provider:  service.Provider,
+		MessageID: "2937421",
+		Text:      "see http://bit.ly/xyz",
+		Links:     []service.Link{{URL: "http://www.google.com", Short: "http://bit.ly/xyz"}},
+	}, nil)
+	s.OnSend(int64(61400000000), "fails").Return(service.Receipt{Provider: service.Provider}, &service.ProviderError{StatusCode: 400, Code: "RECIPIENTS_ERROR"})
+	params := specParams(t, &m, &s)
+	router := wiring.NewRouter(params)
+
+	keys := params.Keys.(*auth.Keys)
+	_, admin, err := keys.Issue(context.Background(), "admin", "", []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err, "issue admin key")
+	_, sender, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+	rotated, _, err := keys.Issue(context.Background(), "rotated", "", []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err, "issue key to rotate")
+
+	type args struct {
+		Method string
+		Path   string
+		Token  string
+		Body   string
+	}
+	testTable := []struct {
+		Name   string
+		Args   args
+		Status int
+	}{
+		{Name: "send", Args: args{Method: "POST", Path: "/api/v1/sms/send", Token: sender, Body: `{"phone_number":"0400000000","texts":["see http://www.google.com","","fails"]}`}, Status: http.StatusOK},
+		{Name: "send v2", Args: args{Method: "POST", Path: "/api/v2/sms/send", Token: sender, Body: `{"phone_number":"0400000000","texts":["see http://www.google.com","","fails"]}`}, Status: http.StatusOK},
+		{Name: "send invalid", Args: args{Method: "POST", Path: "/api/v2/sms/send", Token: sender, Body: `{"texts":[]}`}, Status: http.StatusBadRequest},
+		{Name: "send provider error", Args: args{Method: "POST", Path: "/api/v1/sms/send", Token: sender, Body: `{"phone_number":"0411111111","texts":["text"]}`}, Status: http.StatusServiceUnavailable},
+		{Name: "send unauthenticated", Args: args{Method: "POST", Path: "/api/v1/sms/send", Body: `{}`}, Status: http.StatusUnauthorized},
+		{Name: "history", Args: args{Method: "GET", Path: "/api/v1/sms?recipient=0400000000&limit=1", Token: admin}, Status: http.StatusOK},
+		{Name: "history csv", Args: args{Method: "GET", Path: "/api/v1/sms?format=csv", Token: admin}, Status: http.StatusOK},
+		{Name: "history invalid", Args: args{Method: "GET", Path: "/api/v1/sms?status=delivered", Token: admin}, Status: http.StatusBadRequest},
+		{Name: "erase subject", Args: args{Method: "DELETE", Path: "/api/v1/subjects/0400000000", Token: admin}, Status: http.StatusOK},
+		{Name: "erase invalid subject", Args: args{Method: "DELETE", Path: "/api/v1/subjects/+0400", Token: admin}, Status: http.StatusBadRequest},
+		{Name: "events invalid filter", Args: args{Method: "GET", Path: "/api/v1/events?type=message.read", Token: admin}, Status: http.StatusBadRequest},
+		{Name: "issue key", Args: args{Method: "POST", Path: "/api/v1/admin/keys", Token: admin, Body: `{"name":"partner","tenant_id":"default","scopes":["send"]}`}, Status: http.StatusCreated},
+		{Name: "list keys", Args: args{Method: "GET", Path: "/api/v1/admin/keys", Token: admin}, Status: http.StatusOK},
+		{Name: "rotate key", Args: args{Method: "POST", Path: "/api/v1/admin/keys/" + rotated.ID + "/rotate", Token: admin}, Status: http.StatusOK},
+		{Name: "revoke key", Args: args{Method: "DELETE", Path: "/api/v1/admin/keys/" + rotated.ID, Token: admin}, Status: http.StatusOK},
+		{Name: "revoke unknown key", Args: args{Method: "DELETE", Path: "/api/v1/admin/keys/unknown", Token: admin}, Status: http.StatusNotFound},
+		{Name: "audit log", Args: args{Method: "GET", Path: "/api/v1/admin/audit?limit=2", Token: admin}, Status: http.StatusOK},
+		{Name: "audit log invalid", Args: args{Method: "GET", Path: "/api/v1/admin/audit?limit=0", Token: admin}, Status: http.StatusBadRequest},
+		{Name: "audit export", Args: args{Method: "GET", Path: "/api/v1/admin/audit/export", Token: admin}, Status: http.StatusOK},
+		{Name: "audit verify", Args: args{Method: "GET", Path: "/api/v1/admin/audit/verify", Token: admin}, Status: http.StatusOK},
+		{Name: "get log level", Args: args{Method: "GET", Path: "/api/v1/admin/log/level", Token: admin}, Status: http.StatusOK},
+		{Name: "set log level", Args: args{Method: "PUT", Path: "/api/v1/admin/log/level", Token: admin, Body: `{"level":"debug"}`}, Status: http.StatusOK},
+		{Name: "forbidden", Args: args{Method: "GET", Path: "/api/v1/admin/keys", Token: sender}, Status: http.StatusForbidden},
+		{Name: "liveness", Args: args{Method: "GET", Path: "/healthz"}, Status: http.StatusOK},
+		{Name: "readiness", Args: args{Method: "GET", Path: "/readyz"}, Status: http.StatusOK},
+		{Name: "status", Args: args{Method: "GET", Path: "/status"}, Status: http.StatusOK},
+		{Name: "metrics", Args: args{Method: "GET", Path: "/metrics"}, Status: http.StatusOK},
+		{Name: "openapi", Args: args{Method: "GET", Path: "/openapi.json"}, Status: http.StatusOK},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.Args.Method, tt.Args.Path, strings.NewReader(tt.Args.Body))
+			if tt.Args.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.Args.Token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.Status, rec.Code, "status: %s", rec.Body.String())
+
+			op, _, ok := doc.Operation(tt.Args.Method, req.URL.Path)
+			require.True(t, ok, "operation")
+			violations, err := doc.ValidateResponse(op, rec.Code, rec.Header().Get("Content-Type"), rec.Body.Bytes())
+			require.NoError(t, err, "response")
+			assert.Empty(t, violations, "response violates the spec: %s", rec.Body.String())
+		})
+	}
+}
+
+func specParams(t *testing.T, m *mockFormatter, s *mockSender) *wiring.Params {
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	auditLog := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+	broker := events.NewBroker(10)
+	messages := history.New(history.NewMemoryStore())
+	return &wiring.Params{
+		Logger:        zap.NewNop(),
+		Formatter:     m,
+		Sender:        s,
+		Authenticator: keys,
+		Keys:          keys,
+		Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}}),
+		Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+		RateLimits:    handler.RateLimits{},
+		Readiness:     stubReadiness{},
+		Build:         handler.Build{Version: "1.0", GitCommit: "abc123", StartedAt: time.Now()},
+		Metrics:       metrics.New(),
+		Auditor:       auditLog,
+		Audit:         auditLog,
+		Publisher:     broker,
+		Events:        broker,
+		Recorder:      messages,
+		History:       messages,
+		Eraser:        messages,
+		Level:         zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		Webhooks:      handler.NewWebhooks("https://sms.example.com", "webhook-secret"),
+	}
+}
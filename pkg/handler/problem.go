@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+// ProblemContentType is the media type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// Error codes of problem responses. Codes are stable, titles and details may change.
+const (
+	CodeInvalidJSON         = "invalid_json"
+	CodeMissingPhoneNumber  = "missing_phone_number"
+	CodeInvalidPhoneNumber  = "invalid_phone_number"
+	CodeMissingTexts        = "missing_texts"
+	CodeTooManyTexts        = "too_many_texts"
+	CodeTextTooLong         = "text_too_long"
+	CodeUnauthenticated     = "unauthenticated"
+	CodeForbidden           = "forbidden"
+	CodeTenantNotAssigned   = "tenant_not_assigned"
+	CodeRateLimited         = "rate_limited"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeProviderRateLimited = "provider_rate_limited"
+	CodeProviderBalance     = "provider_insufficient_balance"
+	CodeProviderAuth        = "provider_auth_failed"
+	CodeProviderRejected    = "provider_rejected"
+	CodeSendFailed          = "send_failed"
+	CodeUndelivered         = "undelivered"
+	CodeInvalidRequest      = "invalid_request"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeNotFound            = "not_found"
+	CodeCORSNotAllowed      = "cors_not_allowed"
+	CodeInternal            = "internal_error"
+)
+
+// problemTypePrefix makes codes into the URIs RFC 7807 expects as problem types.
+const problemTypePrefix = "urn:sms-app:problem:"
+
+// Problem represent an RFC 7807 problem details response.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is the stable error code clients should act on.
+	Code string `json:"code"`
+	// Message repeats Detail for clients of the previous error format.
+	Message      string       `json:"message"`
+	Errors       []FieldError `json:"errors,omitempty"`
+	ProviderCode string       `json:"provider_code,omitempty"`
+	RequestID    string       `json:"request_id,omitempty"`
+}
+
+// FieldError represent a problem with one field of the request, such as texts[1].
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+	// ProviderCode is the transmit error code when the provider rejected the field.
+	ProviderCode string `json:"provider_code,omitempty"`
+}
+
+// NewProblem creates a problem with the code and detail.
+func NewProblem(status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:    problemTypePrefix + code,
+		Title:   http.StatusText(status),
+		Status:  status,
+		Detail:  detail,
+		Code:    code,
+		Message: detail,
+	}
+}
+
+// providerCodes maps categories of provider errors to problem codes.
+var providerCodes = map[service.Category]string{
+	service.CategoryAuth:             CodeProviderAuth,
+	service.CategoryBalance:          CodeProviderBalance,
+	service.CategoryInvalidRecipient: CodeInvalidPhoneNumber,
+	service.CategoryRateLimited:      CodeProviderRateLimited,
+	service.CategoryServer:           CodeProviderUnavailable,
+	service.CategoryUnknown:          CodeProviderRejected,
+}
+
+// providerError is implemented by errors carrying the provider's error code and its category.
+type providerError interface {
+	ProviderCode() string
+	Category() service.Category
+}
+
+// ErrorCode classifies a send or format error by the provider's category of it,
+// returning the provider's code when it rejected the call.
+func ErrorCode(err error) (string, string) {
+	cause := errors.Cause(err)
+	if breaker.IsOpen(err) || cause == ratelimit.ErrBackpressure {
+		return CodeProviderUnavailable, ""
+	}
+	if p, ok := cause.(providerError); ok {
+		return providerCodes[p.Category()], p.ProviderCode()
+	}
+	return CodeSendFailed, ""
+}
+
+// writeProblem sends the problem, tagged with the request path and ID.
+func writeProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = logging.RequestID(r.Context())
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func responseBadRequest(w http.ResponseWriter, r *http.Request, code string, response string) {
+	writeProblem(w, r, NewProblem(http.StatusBadRequest, code, response))
+}
+
+func serverError(w http.ResponseWriter, r *http.Request, response string) {
+	writeProblem(w, r, NewProblem(http.StatusInternalServerError, CodeInternal, response))
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, response string) {
+	writeProblem(w, r, NewProblem(http.StatusNotFound, CodeNotFound, response))
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, code string, response string) {
+	writeProblem(w, r, NewProblem(http.StatusForbidden, code, response))
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, response string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="sms-app"`)
+	writeProblem(w, r, NewProblem(http.StatusUnauthorized, CodeUnauthenticated, response))
+}
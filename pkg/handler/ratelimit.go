@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+// Limiter provides methods to consume tokens from a rate limit bucket, and to give them back
+// when another bucket rejects the request.
+type Limiter interface {
+	Take(ctx context.Context, key string, limit ratelimit.Limit, n int) (ratelimit.Result, error)
+	Refund(ctx context.Context, key string, limit ratelimit.Limit, n int) error
+}
+
+// RateLimits represent the rate limits applied to send requests.
+// Global and PerKey count requests, PerRecipient counts messages.
+type RateLimits struct {
+	Global       ratelimit.Limit
+	PerKey       ratelimit.Limit
+	PerRecipient ratelimit.Limit
+}
+
+// LimitSource provides the current rate limits, which may change on config reload.
+type LimitSource interface {
+	RateLimits() RateLimits
+}
+
+// RateLimits returns the limits, so fixed limits can be used as a LimitSource.
+func (l RateLimits) RateLimits() RateLimits {
+	return l
+}
+
+// RateLimit rejects requests over the API key's or the global limit. The API key's bucket is
+// checked first so a key over its own limit does not use up the global one, and its request is
+// given back when the global limit rejects it.
+// Usage of the API key's bucket is reported in response headers. Must run after Authenticate.
+func RateLimit(logger *zap.Logger, limiter Limiter, source LimitSource) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, ok := take(w, r, logger, limiter, 1, requestBuckets(r.Context(), source)...)
+			if !ok {
+				return
+			}
+			ratelimit.SetHeaders(w.Header(), res)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AllowRequest consumes a request from the API key's and the global limits, for callers
+// other than HTTP. The message of the exceeded limit is returned when one is empty.
+func AllowRequest(ctx context.Context, logger *zap.Logger, limiter Limiter, source LimitSource) (string, bool) {
+	_, message, ok := allow(ctx, logger, limiter, 1, requestBuckets(ctx, source)...)
+	return message, ok
+}
+
+// AllowMessages consumes n messages from the recipient's and the tenant's limits, for callers
+// other than HTTP. The message of the exceeded limit is returned when one is empty.
+func AllowMessages(ctx context.Context, logger *zap.Logger, limiter Limiter, source LimitSource, number int64, n int) (string, bool) {
+	_, message, ok := allow(ctx, logger, limiter, n, messageBuckets(ctx, source, number)...)
+	return message, ok
+}
+
+// bucket is a rate limit bucket and the message given when it is exceeded.
+type bucket struct {
+	key     string
+	limit   ratelimit.Limit
+	message string
+}
+
+// requestBuckets returns the buckets counting requests of the caller.
+func requestBuckets(ctx context.Context, source LimitSource) []bucket {
+	limits := source.RateLimits()
+	return []bucket{
+		{key: "key:" + callerID(ctx), limit: limits.PerKey, message: "rate limit exceeded for api key"},
+		{key: "global", limit: limits.Global, message: "rate limit exceeded"},
+	}
+}
+
+// messageBuckets returns the buckets counting messages to the recipient.
+func messageBuckets(ctx context.Context, source LimitSource, number int64) []bucket {
+	return []bucket{
+		{key: recipientKey(number), limit: source.RateLimits().PerRecipient, message: "rate limit exceeded for recipient"},
+		{key: "tenant:" + tenantID(ctx), limit: tenantLimit(ctx), message: "rate limit exceeded for tenant"},
+	}
+}
+
+// take consumes n tokens from the buckets and writes a 429 response, with the headers of the
+// bucket that rejected the request, when one is empty.
+func take(w http.ResponseWriter, r *http.Request, logger *zap.Logger, limiter Limiter, n int, buckets ...bucket) (ratelimit.Result, bool) {
+	res, message, ok := allow(r.Context(), logger, limiter, n, buckets...)
+	if ok {
+		return res, true
+	}
+	ratelimit.SetHeaders(w.Header(), res)
+	writeProblem(w, r, NewProblem(http.StatusTooManyRequests, CodeRateLimited, message))
+	return res, false
+}
+
+// allow consumes n tokens from each bucket in turn. When one is empty, the tokens taken from the
+// previous ones are given back and its result and message are returned. Otherwise the result of the
+// first bucket is returned. Errors from the limiter are logged and the bucket is skipped.
+func allow(ctx context.Context, logger *zap.Logger, limiter Limiter, n int, buckets ...bucket) (ratelimit.Result, string, bool) {
+	logger = logging.From(ctx, logger)
+	first := ratelimit.Result{Allowed: true}
+	var taken []bucket
+	for i, b := range buckets {
+		res, err := limiter.Take(ctx, b.key, b.limit, n)
+		if err != nil {
+			logger.Error("Unable to check rate limit", zap.String("bucket", b.key), zap.Error(err))
+			continue
+		}
+		if !res.Allowed {
+			logger.Warn("Rate limit exceeded", zap.String("bucket", b.key), zap.String("limit", b.limit.String()))
+			for _, t := range taken {
+				if err := limiter.Refund(ctx, t.key, t.limit, n); err != nil {
+					logger.Error("Unable to refund rate limit", zap.String("bucket", t.key), zap.Error(err))
+				}
+			}
+			return res, b.message, false
+		}
+		if i == 0 {
+			first = res
+		}
+		taken = append(taken, b)
+	}
+	return first, "", true
+}
+
+// tenantLimit returns the tenant's messages per minute limit.
+func tenantLimit(ctx context.Context) ratelimit.Limit {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return ratelimit.Limit{}
+	}
+	return ratelimit.Limit{Count: t.Limits.MessagesPerMinute, Period: time.Minute}
+}
+
+func recipientKey(number int64) string {
+	return "recipient:" + strconv.FormatInt(number, 10)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+)
+
+// Eraser provides method to erase the messages sent to a phone number.
+type Eraser interface {
+	Erase(ctx context.Context, recipient int64) (int, error)
+}
+
+// Erasure represent the result of erasing a data subject.
+type Erasure struct {
+	Recipient int64 `json:"recipient"`
+	// Messages is the number of messages erased from the history.
+	Messages int `json:"messages"`
+}
+
+// EraseSubject handles request to erase the messages sent to a phone number, of every tenant.
+// Numbers starting with + are taken as international, others are formatted like a number to send to.
+// The erasure is recorded in the audit log against the pseudonym of the number.
+// DELETE /api/v1/subjects/{phone}
+func EraseSubject(logger *zap.Logger, formatter Formatter, eraser Eraser, auditor Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		logger := logging.From(r.Context(), logger)
+
+		phone := mux.Vars(r)["phone"]
+		number, valid, err := subjectNumber(r.Context(), formatter, phone)
+		if err != nil {
+			formatError(w, r, logger, err)
+			return
+		}
+		if !valid {
+			p := NewProblem(http.StatusBadRequest, CodeInvalidPhoneNumber, "invalid phone number")
+			p.Errors = []FieldError{{Field: "phone", Code: CodeInvalidPhoneNumber, Detail: "invalid phone number"}}
+			writeProblem(w, r, p)
+			return
+		}
+
+		n, err := eraser.Erase(r.Context(), number)
+		if err != nil {
+			logger.Error("Unable to erase subject", zap.Error(err))
+			serverError(w, r, "unable to erase subject")
+			return
+		}
+		logger.Info("Subject erased", zap.Int("messages", n))
+		record(r.Context(), logger, auditor, audit.Entry{Action: audit.ActionSubjectErase, Target: auditor.Pseudonym(strconv.FormatInt(number, 10)), Details: map[string]string{
+			"messages": strconv.Itoa(n),
+		}})
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		enc.Encode(&Erasure{Recipient: number, Messages: n})
+	}
+}
+
+// subjectNumber returns the international number of phone. Numbers no longer in service may fail
+// the provider's validation, so numbers starting with + are parsed without it.
+func subjectNumber(ctx context.Context, formatter Formatter, phone string) (int64, bool, error) {
+	if !strings.HasPrefix(phone, "+") {
+		return formatter.Format(ctx, phone)
+	}
+	digits := phone[1:]
+	if len(digits) < 7 || len(digits) > 15 || strings.Trim(digits, "0123456789") != "" || digits[0] == '0' {
+		return 0, false, nil
+	}
+	number, err := strconv.ParseInt(digits, 10, 64)
+	return number, err == nil, nil
+}
@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+func TestEraseSubject(t *testing.T) {
+	type args struct {
+		Phone string
+		Admin bool
+	}
+	type want struct {
+		Status   int
+		Messages int
+		Target   string
+		Left     []string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : number formatted like a send",
+			Args: args{Phone: "0400000000", Admin: true},
+			Want: want{Status: http.StatusOK, Messages: 2, Target: "61400000000", Left: []string{"other"}},
+		},
+		{
+			Name: "Success : international number without formatting",
+			Args: args{Phone: "+61400000000", Admin: true},
+			Want: want{Status: http.StatusOK, Messages: 2, Target: "61400000000", Left: []string{"other"}},
+		},
+		{
+			Name: "Success : nothing to erase",
+			Args: args{Phone: "+61400000009", Admin: true},
+			Want: want{Status: http.StatusOK, Target: "61400000009", Left: []string{"other", "second", "first"}},
+		},
+		{
+			Name: "Failure - invalid number",
+			Args: args{Phone: "0499999999", Admin: true},
+			Want: want{Status: http.StatusBadRequest, Left: []string{"other", "second", "first"}},
+		},
+		{
+			Name: "Failure - admin scope required",
+			Args: args{Phone: "0400000000"},
+			Want: want{Status: http.StatusForbidden, Left: []string{"other", "second", "first"}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var m mockFormatter
+			m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+			m.OnFormat("0499999999").Return(int64(0), false, nil)
+			ctx := context.Background()
+			messages := history.New(history.NewMemoryStore())
+			for _, msg := range []history.Message{
+				{TenantID: tenant.DefaultID, Recipient: 61400000000, Text: "first"},
+				{TenantID: "other", Recipient: 61400000000, Text: "second"},
+				{TenantID: tenant.DefaultID, Recipient: 61400000001, Text: "other"},
+			} {
+				_, err := messages.Record(ctx, msg)
+				require.NoError(t, err, "record")
+			}
+			keys := auth.NewKeys(auth.NewMemoryStore())
+			scope := auth.ScopeRead
+			if tt.Args.Admin {
+				scope = auth.ScopeAdmin
+			}
+			_, token, err := keys.Issue(ctx, "dpo", "", []auth.Scope{scope})
+			require.NoError(t, err, "issue key")
+			auditLog := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+			params := &wiring.Params{
+				Logger:        zap.NewNop(),
+				Formatter:     &m,
+				Authenticator: keys,
+				Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}, {ID: "other"}}),
+				Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+				RateLimits:    handler.RateLimits{},
+				Auditor:       auditLog,
+				History:       messages,
+				Eraser:        messages,
+			}
+			ts := httptest.NewServer(wiring.NewRouter(params))
+			defer ts.Close()
+
+			res := do(t, "DELETE", ts.URL+"/api/v1/subjects/"+tt.Args.Phone, token, "", "")
+			defer res.Body.Close()
+			require.Equal(t, tt.Want.Status, res.StatusCode, "status")
+
+			left, err := messages.Search(ctx, history.Filter{})
+			require.NoError(t, err, "search")
+			texts := []string{}
+			for _, msg := range left {
+				texts = append(texts, msg.Text)
+			}
+			assert.Equal(t, tt.Want.Left, texts, "messages left")
+
+			entries, err := auditLog.Query(ctx, audit.Filter{Action: audit.ActionSubjectErase})
+			require.NoError(t, err, "query audit log")
+			if tt.Want.Status != http.StatusOK {
+				assert.Empty(t, entries, "no erasure recorded")
+				return
+			}
+			var erasure handler.Erasure
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&erasure), "decode")
+			assert.Equal(t, tt.Want.Messages, erasure.Messages, "messages erased")
+			require.Len(t, entries, 1, "erasure recorded")
+			assert.Equal(t, auditLog.Pseudonym(tt.Want.Target), entries[0].Target, "target pseudonym")
+			assert.Equal(t, strconv.Itoa(tt.Want.Messages), entries[0].Details["messages"], "erased messages")
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+// TenantFinder provides method to find a tenant.
+type TenantFinder interface {
+	Get(ctx context.Context, id string) (tenant.Tenant, error)
+}
+
+// ResolveTenant stores the tenant of the authenticated API key in the request context.
+// The tenant ID is added to the request logger. Must run after Authenticate.
+func ResolveTenant(logger *zap.Logger, tenants TenantFinder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.From(r.Context(), logger)
+			id := tenant.DefaultID
+			if key, ok := auth.KeyFromContext(r.Context()); ok && key.TenantID != "" {
+				id = key.TenantID
+			}
+			t, err := tenants.Get(r.Context(), id)
+			if err == tenant.ErrNotFound {
+				logger.Warn("Api key not assigned to a tenant", zap.String("tenant_id", id))
+				forbidden(w, r, CodeTenantNotAssigned, "api key not assigned to a tenant")
+				return
+			}
+			if err != nil {
+				logger.Error("Unable to find tenant", zap.String("tenant_id", id), zap.Error(err))
+				serverError(w, r, "unable to find tenant")
+				return
+			}
+			ctx := logging.With(tenant.WithTenant(r.Context(), t), logger, zap.String("tenant_id", t.ID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func tenantID(ctx context.Context) string {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return t.ID
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+// Paths transmit calls back, relative to the base URL of the app.
+const (
+	DeliveryWebhookPath = "/api/v1/webhooks/transmit/delivery"
+	ReplyWebhookPath    = "/api/v1/webhooks/transmit/reply"
+)
+
+type messageIDKey struct{}
+
+// withMessageID stores the ID of the message being sent.
+func withMessageID(ctx context.Context, messageID string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, messageID)
+}
+
+// Webhooks gives transmit callback URLs naming the tenant and the message they are about, signed so
+// delivery receipts and replies are attributed without storing what was sent.
+type Webhooks struct {
+	baseURL string
+	key     []byte
+}
+
+// NewWebhooks creates Webhooks for the app reachable by transmit at baseURL, signing with secret.
+func NewWebhooks(baseURL string, secret string) *Webhooks {
+	return &Webhooks{baseURL: strings.TrimSuffix(baseURL, "/"), key: []byte(secret)}
+}
+
+// Callbacks returns the callback URLs of a message of the tenant.
+func (h *Webhooks) Callbacks(tenantID string, messageID string) service.Callbacks {
+	q := url.Values{}
+	q.Set("tenant", tenantID)
+	q.Set("message", messageID)
+	q.Set("sig", h.sign(tenantID, messageID))
+	return service.Callbacks{
+		Delivery: h.baseURL + DeliveryWebhookPath + "?" + q.Encode(),
+		Reply:    h.baseURL + ReplyWebhookPath + "?" + q.Encode(),
+	}
+}
+
+func (h *Webhooks) sign(tenantID string, messageID string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(tenantID + "\n" + messageID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// event checks the signature of a callback and returns the event of its message, answering the
+// request when it is invalid.
+func (h *Webhooks) event(w http.ResponseWriter, r *http.Request, logger *zap.Logger) (events.Event, bool) {
+	tenantID, messageID, sig := r.FormValue("tenant"), r.FormValue("message"), r.FormValue("sig")
+	if tenantID == "" || messageID == "" {
+		responseBadRequest(w, r, CodeInvalidRequest, "tenant and message are required")
+		return events.Event{}, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(h.sign(tenantID, messageID))) {
+		logger.Warn("Callback signature mismatch", zap.String("tenant_id", tenantID), zap.String("message_id", messageID))
+		forbidden(w, r, CodeForbidden, "invalid callback signature")
+		return events.Event{}, false
+	}
+	return events.Event{
+		TenantID:          tenantID,
+		MessageID:         messageID,
+		RequestID:         logging.RequestID(r.Context()),
+		Provider:          service.Provider,
+		ProviderMessageID: r.FormValue("message_id"),
+	}, true
+}
+
+// Sender adds the callbacks of the message being sent to the calls of next.
+func (h *Webhooks) Sender(next Sender) Sender {
+	return &callbackSender{next: next, webhooks: h}
+}
+
+type callbackSender struct {
+	next     Sender
+	webhooks *Webhooks
+}
+
+func (s *callbackSender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
+	if id, ok := ctx.Value(messageIDKey{}).(string); ok {
+		ctx = service.WithCallbacks(ctx, s.webhooks.Callbacks(tenantID(ctx), id))
+	}
+	return s.next.Send(ctx, phoneNumber, text)
+}
+
+// TransmitDelivery handles the delivery receipts transmit sends to the callback of a message, publishing
+// its delivered event, or its failed event when it bounced. Receipts of pending messages are ignored.
+// GET or POST /api/v1/webhooks/transmit/delivery
+func TransmitDelivery(logger *zap.Logger, webhooks *Webhooks, publisher Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.From(r.Context(), logger)
+		e, ok := webhooks.event(w, r, logger)
+		if !ok {
+			return
+		}
+		status := r.FormValue("status")
+		switch status {
+		case "delivered":
+			e.Type = events.TypeDelivered
+		case "soft-bounce", "hard-bounce":
+			e.Type = events.TypeFailed
+			e.ErrorCode = CodeUndelivered
+			e.ProviderCode = status
+		default:
+			logger.Info("Delivery receipt ignored", zap.String("message_id", e.MessageID), zap.String("status", status))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if publisher != nil {
+			publisher.Publish(e)
+		}
+		logger.Info("Delivery receipt received", zap.String("message_id", e.MessageID), zap.String("status", status))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// TransmitReply handles the replies transmit sends to the callback of a message, publishing an inbound
+// event of the message replied to. The reply itself is not kept.
+// GET or POST /api/v1/webhooks/transmit/reply
+func TransmitReply(logger *zap.Logger, webhooks *Webhooks, publisher Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.From(r.Context(), logger)
+		e, ok := webhooks.event(w, r, logger)
+		if !ok {
+			return
+		}
+		e.Type = events.TypeInbound
+		if publisher != nil {
+			publisher.Publish(e)
+		}
+		logger.Info("Reply received", zap.String("message_id", e.MessageID))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,131 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/wiring"
+)
+
+// transmitClient answers sends like transmit and keeps the last request form.
+type transmitClient struct {
+	form url.Values
+}
+
+func (c *transmitClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	c.form = req.PostForm
+	res := httptest.NewRecorder()
+	res.WriteString(`{"message_id":2937421,"error":{"code":"SUCCESS","description":"OK"}}`)
+	return res.Result(), nil
+}
+
+func TestTransmitWebhooks(t *testing.T) {
+	var m mockFormatter
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	var client transmitClient
+	webhooks := handler.NewWebhooks("https://sms.example.com/", "webhook-secret")
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	_, sendToken, err := keys.Issue(context.Background(), "send", "", []auth.Scope{auth.ScopeSend})
+	require.NoError(t, err, "issue send key")
+	broker := events.NewBroker(10)
+	params := &wiring.Params{
+		Logger:        zap.NewNop(),
+		Formatter:     &m,
+		Sender:        webhooks.Sender(service.New("key", "secret", &client, zap.NewNop(), nil)),
+		Authenticator: keys,
+		Tenants:       tenant.NewStore([]tenant.Tenant{{ID: tenant.DefaultID}}),
+		Limiter:       ratelimit.New(ratelimit.NewMemoryStore()),
+		RateLimits:    handler.RateLimits{},
+		Auditor:       audit.NewLog(audit.NewMemoryStore(), []byte("audit-key")),
+		Publisher:     broker,
+		Events:        broker,
+		Webhooks:      webhooks,
+	}
+	ts := httptest.NewServer(wiring.NewRouter(params))
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/v1/sms/send", strings.NewReader(`{"phone_number":"0400000000","texts":["hello"]}`))
+	require.NoError(t, err, "Error creating request")
+	req.Header.Set("Authorization", "Bearer "+sendToken)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "Error executing request")
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode, "send status")
+
+	delivery, err := url.Parse(client.form.Get("dlr_callback"))
+	require.NoError(t, err, "delivery callback")
+	assert.Equal(t, "https://sms.example.com/api/v1/webhooks/transmit/delivery", delivery.Scheme+"://"+delivery.Host+delivery.Path, "delivery callback")
+	reply, err := url.Parse(client.form.Get("reply_callback"))
+	require.NoError(t, err, "reply callback")
+	assert.Equal(t, "https://sms.example.com/api/v1/webhooks/transmit/reply", reply.Scheme+"://"+reply.Host+reply.Path, "reply callback")
+	messageID := delivery.Query().Get("message")
+	require.NotEmpty(t, messageID, "message id")
+
+	sub := broker.Subscribe(events.Filter{TenantID: tenant.DefaultID, Types: []events.Type{events.TypeDelivered, events.TypeFailed, events.TypeInbound}}, 0)
+	defer sub.Close()
+
+	forged := delivery.Query()
+	forged.Set("message", "other")
+	type args struct {
+		Method string
+		Path   string
+		Query  string
+		Form   string
+	}
+	testTable := []struct {
+		Name   string
+		Args   args
+		Status int
+	}{
+		{Name: "Success : delivered", Args: args{Method: "GET", Path: delivery.Path, Query: delivery.RawQuery + "&message_id=2937421&status=delivered"}, Status: http.StatusNoContent},
+		{Name: "Success : pending is ignored", Args: args{Method: "GET", Path: delivery.Path, Query: delivery.RawQuery + "&message_id=2937421&status=pending"}, Status: http.StatusNoContent},
+		{Name: "Success : bounced", Args: args{Method: "POST", Path: delivery.Path, Query: delivery.RawQuery, Form: "message_id=2937421&status=hard-bounce"}, Status: http.StatusNoContent},
+		{Name: "Success : reply", Args: args{Method: "POST", Path: reply.Path, Query: reply.RawQuery, Form: "message_id=2937421&mobile=61400000000&response=stop"}, Status: http.StatusNoContent},
+		{Name: "Failure - forged message", Args: args{Method: "GET", Path: delivery.Path, Query: forged.Encode() + "&status=delivered"}, Status: http.StatusForbidden},
+		{Name: "Failure - no message", Args: args{Method: "GET", Path: reply.Path, Query: "tenant=default"}, Status: http.StatusBadRequest},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.Args.Method, ts.URL+tt.Args.Path+"?"+tt.Args.Query, strings.NewReader(tt.Args.Form))
+			require.NoError(t, err, "Error creating request")
+			if tt.Args.Form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err, "Error executing request")
+			res.Body.Close()
+			assert.Equal(t, tt.Status, res.StatusCode, "status")
+		})
+	}
+
+	var published []events.Event
+	for len(published) < 3 && len(sub.Events()) > 0 {
+		published = append(published, <-sub.Events())
+	}
+	require.Len(t, published, 3, "events")
+	for i, want := range []events.Type{events.TypeDelivered, events.TypeFailed, events.TypeInbound} {
+		assert.Equal(t, want, published[i].Type, "type")
+		assert.Equal(t, messageID, published[i].MessageID, "message id")
+		assert.Equal(t, "2937421", published[i].ProviderMessageID, "provider message id")
+	}
+	assert.Equal(t, handler.CodeUndelivered, published[1].ErrorCode, "error code")
+	assert.Equal(t, "hard-bounce", published[1].ProviderCode, "provider code")
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Check verifies a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Status values reported for a check.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Result represent the outcome of a check.
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type check struct {
+	name     string
+	fn       Check
+	critical bool
+}
+
+// Checker runs readiness checks. Only failed critical checks make the app not ready.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []check
+	now    func() time.Time
+}
+
+// New creates a Checker without checks.
+func New() *Checker {
+	return &Checker{now: time.Now}
+}
+
+// Add registers a check.
+func (c *Checker) Add(name string, fn Check, critical bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn, critical: critical})
+}
+
+// Run executes all checks concurrently and reports whether the app is ready.
+func (c *Checker) Run(ctx context.Context) (bool, []Result) {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+			r := Result{Name: ch.name, Status: StatusOK, Critical: ch.critical}
+			if err := ch.fn(ctx); err != nil {
+				r.Status = StatusFailed
+				r.Error = err.Error()
+			}
+			r.CheckedAt = c.now().UTC()
+			results[i] = r
+		}(i, ch)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		if r.Critical && r.Status != StatusOK {
+			ready = false
+		}
+	}
+	return ready, results
+}
+
+// Cached reuses the outcome of fn for ttl so slow or rate limited
+// dependencies are not called on every probe.
+func Cached(fn Check, ttl time.Duration) Check {
+	var mu sync.Mutex
+	var last time.Time
+	var lastErr error
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !last.IsZero() && time.Since(last) < ttl {
+			return lastErr
+		}
+		lastErr = fn(ctx)
+		last = time.Now()
+		return lastErr
+	}
+}
+
+// Reachable checks the URL answers HTTP requests. Any response below 500 counts as reachable,
+// credentials are not sent so 401 or 404 are expected.
+func Reachable(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequest("HEAD", url, nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to create request")
+		}
+		res, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return errors.Wrapf(err, "%s unreachable", url)
+		}
+		res.Body.Close()
+		if res.StatusCode >= http.StatusInternalServerError {
+			return errors.Errorf("%s returned status %d", url, res.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nikhil-github/sms-app/pkg/health"
+)
+
+func TestRun(t *testing.T) {
+	type args struct {
+		Critical    error
+		NonCritical error
+	}
+	type want struct {
+		Ready    bool
+		Statuses []string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Ready : all checks pass",
+			Want: want{Ready: true, Statuses: []string{health.StatusOK, health.StatusOK}},
+		},
+		{
+			Name: "Ready : non critical check fails",
+			Args: args{NonCritical: errors.New("down")},
+			Want: want{Ready: true, Statuses: []string{health.StatusOK, health.StatusFailed}},
+		},
+		{
+			Name: "Not ready : critical check fails",
+			Args: args{Critical: errors.New("down")},
+			Want: want{Ready: false, Statuses: []string{health.StatusFailed, health.StatusOK}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			c := health.New()
+			c.Add("store", func(ctx context.Context) error { return tt.Args.Critical }, true)
+			c.Add("transmit", func(ctx context.Context) error { return tt.Args.NonCritical }, false)
+			ready, results := c.Run(context.Background())
+			assert.Equal(t, tt.Want.Ready, ready, "ready")
+			var statuses []string
+			for _, r := range results {
+				statuses = append(statuses, r.Status)
+			}
+			assert.Equal(t, tt.Want.Statuses, statuses, "statuses")
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	check := health.Cached(func(ctx context.Context) error {
+		calls++
+		return nil
+	}, time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, check(context.Background()))
+	}
+	assert.Equal(t, 1, calls, "calls")
+}
+
+func TestReachable(t *testing.T) {
+	testTable := []struct {
+		Name   string
+		Status int
+		Err    bool
+	}{
+		{Name: "unauthorized is reachable", Status: http.StatusUnauthorized},
+		{Name: "server error", Status: http.StatusBadGateway, Err: true},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.Status)
+			}))
+			defer ts.Close()
+			err := health.Reachable(ts.Client(), ts.URL)(context.Background())
+			if tt.Err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Package history keeps the messages sent by the service so they can be searched later.
+package history
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Message represent a text sent, or failed to be sent, to a recipient.
+type Message struct {
+	Seq               int64     `json:"seq"`
+	ID                string    `json:"id"`
+	Time              time.Time `json:"time"`
+	TenantID          string    `json:"tenant_id"`
+	KeyID             string    `json:"key_id,omitempty"`
+	RequestID         string    `json:"request_id,omitempty"`
+	Recipient         int64     `json:"recipient"`
+	Text              string    `json:"text"`
+	Status            string    `json:"status"`
+	Segments          int       `json:"segments"`
+	Encoding          string    `json:"encoding,omitempty"`
+	Provider          string    `json:"provider,omitempty"`
+	ProviderMessageID string    `json:"provider_message_id,omitempty"`
+	ErrorCode         string    `json:"error_code,omitempty"`
+	ProviderCode      string    `json:"provider_code,omitempty"`
+	// Anonymised is set once the text and recipient were removed after the retention period.
+	Anonymised bool `json:"anonymised,omitempty"`
+}
+
+// Action is what happens to messages past their retention period.
+type Action string
+
+// Retention actions.
+const (
+	// ActionDelete removes the messages.
+	ActionDelete Action = "delete"
+	// ActionAnonymise keeps the messages for reporting without their text and recipient.
+	ActionAnonymise Action = "anonymise"
+)
+
+// ParseAction returns the action named s.
+func ParseAction(s string) (Action, bool) {
+	switch a := Action(s); a {
+	case ActionDelete, ActionAnonymise:
+		return a, true
+	}
+	return "", false
+}
+
+// Policy is how long the messages of a tenant are kept and what happens to them after.
+type Policy struct {
+	Days   int
+	Action Action
+}
+
+// PurgeResult counts the messages changed by a purge.
+type PurgeResult struct {
+	Deleted    int
+	Anonymised int
+}
+
+// Filter selects messages, zero values match everything.
+type Filter struct {
+	TenantID  string
+	Recipient int64
+	Status    string
+	KeyID     string
+	Provider  string
+	// Text matches messages containing it, ignoring case.
+	Text  string
+	Since time.Time
+	Until time.Time
+	// Before skips messages from this sequence number on, used as the cursor of the next page.
+	Before int64
+	// Limit caps the number of messages returned, 0 for no limit.
+	Limit int
+}
+
+// Match reports whether the message is selected by the filter.
+func (f Filter) Match(m Message) bool {
+	switch {
+	case f.Before > 0 && m.Seq >= f.Before:
+		return false
+	case f.TenantID != "" && m.TenantID != f.TenantID:
+		return false
+	case f.Recipient != 0 && m.Recipient != f.Recipient:
+		return false
+	case f.Status != "" && m.Status != f.Status:
+		return false
+	case f.KeyID != "" && m.KeyID != f.KeyID:
+		return false
+	case f.Provider != "" && m.Provider != f.Provider:
+		return false
+	case !f.Since.IsZero() && m.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !m.Time.Before(f.Until):
+		return false
+	case f.Text != "" && !strings.Contains(strings.ToLower(m.Text), strings.ToLower(f.Text)):
+		return false
+	}
+	return true
+}
+
+// History appends messages to a store and searches them.
+type History struct {
+	mu    sync.Mutex
+	store Store
+	now   func() time.Time
+}
+
+// New creates a History backed by store.
+func New(store Store) *History {
+	return &History{store: store, now: time.Now}
+}
+
+// Record assigns the sequence number of m, stamps it when its time is not set and appends it.
+func (h *History) Record(ctx context.Context, m Message) (Message, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	last, err := h.store.LastSeq(ctx)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "failed to read last message")
+	}
+	m.Seq = last + 1
+	if m.Time.IsZero() {
+		m.Time = h.now().UTC()
+	}
+	if err := h.store.Append(ctx, m); err != nil {
+		return Message{}, errors.Wrap(err, "failed to append message")
+	}
+	return m, nil
+}
+
+// Search returns the messages matching the filter, newest first.
+func (h *History) Search(ctx context.Context, f Filter) ([]Message, error) {
+	var messages []Message
+	err := h.store.ScanBefore(ctx, f.Before, func(m Message) (bool, error) {
+		if f.Match(m) {
+			messages = append(messages, m)
+		}
+		return f.Limit == 0 || len(messages) < f.Limit, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Purge applies the retention policy of each message's tenant, policies with no days keep messages.
+func (h *History) Purge(ctx context.Context, now time.Time, policy func(tenantID string) Policy) (PurgeResult, error) {
+	var res PurgeResult
+	policies := map[string]Policy{}
+	err := h.store.Rewrite(ctx, func(m Message) (Message, bool) {
+		p, ok := policies[m.TenantID]
+		if !ok {
+			p = policy(m.TenantID)
+			policies[m.TenantID] = p
+		}
+		if p.Days <= 0 || m.Time.After(now.AddDate(0, 0, -p.Days)) {
+			return m, true
+		}
+		if p.Action == ActionDelete {
+			res.Deleted++
+			return m, false
+		}
+		if m.Anonymised {
+			return m, true
+		}
+		res.Anonymised++
+		m.Text = ""
+		m.Recipient = 0
+		m.Anonymised = true
+		return m, true
+	})
+	if err != nil {
+		return PurgeResult{}, errors.Wrap(err, "failed to purge messages")
+	}
+	return res, nil
+}
+
+// Erase deletes every message sent to recipient, of any tenant, and returns how many there were.
+func (h *History) Erase(ctx context.Context, recipient int64) (int, error) {
+	n := 0
+	err := h.store.Rewrite(ctx, func(m Message) (Message, bool) {
+		if m.Recipient == recipient {
+			n++
+			return m, false
+		}
+		return m, true
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to erase messages")
+	}
+	return n, nil
+}
@@ -0,0 +1,264 @@
+package history_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/history"
+)
+
+func TestSearch(t *testing.T) {
+	type args struct {
+		Filter history.Filter
+	}
+	type want struct {
+		Seqs []int64
+	}
+	base := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : newest first",
+			Args: args{Filter: history.Filter{TenantID: "a"}},
+			Want: want{Seqs: []int64{5, 4, 2, 1}},
+		},
+		{
+			Name: "Success : by recipient",
+			Args: args{Filter: history.Filter{TenantID: "a", Recipient: 61400000001}},
+			Want: want{Seqs: []int64{4, 1}},
+		},
+		{
+			Name: "Success : by status, key and provider",
+			Args: args{Filter: history.Filter{TenantID: "a", Status: "failed", KeyID: "k2", Provider: "sms-provider"}},
+			Want: want{Seqs: []int64{5}},
+		},
+		{
+			Name: "Success : text ignoring case",
+			Args: args{Filter: history.Filter{TenantID: "a", Text: "ORDER"}},
+			Want: want{Seqs: []int64{4, 1}},
+		},
+		{
+			Name: "Success : date range",
+			Args: args{Filter: history.Filter{TenantID: "a", Since: base.Add(time.Hour), Until: base.Add(4 * time.Hour)}},
+			Want: want{Seqs: []int64{2}},
+		},
+		{
+			Name: "Success : page of the newest",
+			Args: args{Filter: history.Filter{TenantID: "a", Limit: 2}},
+			Want: want{Seqs: []int64{5, 4}},
+		},
+		{
+			Name: "Success : next page before the cursor",
+			Args: args{Filter: history.Filter{TenantID: "a", Before: 4, Limit: 2}},
+			Want: want{Seqs: []int64{2, 1}},
+		},
+		{
+			Name: "Failure - nothing matches",
+			Args: args{Filter: history.Filter{TenantID: "c"}},
+			Want: want{Seqs: []int64{}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			h := history.New(history.NewMemoryStore())
+			for i, m := range []history.Message{
+				{TenantID: "a", KeyID: "k1", Recipient: 61400000001, Text: "Your order shipped", Status: "sent", Provider: "sms-provider"},
+				{TenantID: "a", KeyID: "k1", Recipient: 61400000002, Text: "Hello", Status: "sent", Provider: "sms-provider"},
+				{TenantID: "b", KeyID: "k3", Recipient: 61400000001, Text: "Order of b", Status: "sent", Provider: "sms-provider"},
+				{TenantID: "a", KeyID: "k2", Recipient: 61400000001, Text: "order delivered", Status: "sent", Provider: "sms-provider"},
+				{TenantID: "a", KeyID: "k2", Recipient: 61400000003, Text: "Hi", Status: "failed", Provider: "sms-provider"},
+			} {
+				m.Time = base.Add(time.Duration(i) * 2 * time.Hour)
+				_, err := h.Record(context.Background(), m)
+				require.NoError(t, err, "record")
+			}
+			messages, err := h.Search(context.Background(), tt.Args.Filter)
+			require.NoError(t, err, "search")
+			seqs := []int64{}
+			for _, m := range messages {
+				seqs = append(seqs, m.Seq)
+			}
+			assert.Equal(t, tt.Want.Seqs, seqs, "messages")
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.OpenFile(path)
+	require.NoError(t, err, "open")
+	h := history.New(store)
+	for _, text := range []string{"one", "two"} {
+		_, err := h.Record(ctx, history.Message{TenantID: "a", Text: text})
+		require.NoError(t, err, "record")
+	}
+	require.NoError(t, store.Close(), "close")
+
+	store, err = history.OpenFile(path)
+	require.NoError(t, err, "reopen")
+	defer store.Close()
+	h = history.New(store)
+	m, err := h.Record(ctx, history.Message{TenantID: "a", Text: "three"})
+	require.NoError(t, err, "record after reopen")
+	assert.Equal(t, int64(3), m.Seq, "sequence continues")
+	assert.False(t, m.Time.IsZero(), "time stamped")
+
+	messages, err := h.Search(ctx, history.Filter{Text: "t"})
+	require.NoError(t, err, "search")
+	require.Len(t, messages, 2, "messages")
+	assert.Equal(t, "three", messages[0].Text, "newest first")
+	assert.Equal(t, "two", messages[1].Text, "then older")
+
+	messages, err = h.Search(ctx, history.Filter{Before: 3, Limit: 1})
+	require.NoError(t, err, "search page")
+	require.Len(t, messages, 1, "page")
+	assert.Equal(t, "two", messages[0].Text, "before the cursor")
+}
+
+func TestFileStorePing(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.OpenFile(path)
+	require.NoError(t, err, "open")
+	require.NoError(t, store.Ping(ctx), "open file")
+
+	require.NoError(t, os.Rename(path, path+".1"), "rotate")
+	assert.EqualError(t, store.Ping(ctx), "history file is missing: stat "+path+": no such file or directory", "missing file")
+	require.NoError(t, os.WriteFile(path, nil, 0600), "replace")
+	assert.EqualError(t, store.Ping(ctx), "history file was replaced, messages are written to the old one", "replaced file")
+
+	require.NoError(t, store.Close(), "close")
+	assert.Error(t, store.Ping(ctx), "closed file")
+}
+
+func TestPurge(t *testing.T) {
+	type args struct {
+		Policies map[string]history.Policy
+	}
+	type want struct {
+		Result history.PurgeResult
+		Texts  []string
+	}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : old messages anonymised",
+			Args: args{Policies: map[string]history.Policy{"a": {Days: 7, Action: history.ActionAnonymise}, "b": {Days: 7, Action: history.ActionAnonymise}}},
+			Want: want{Result: history.PurgeResult{Anonymised: 2}, Texts: []string{"b new", "a new", "", ""}},
+		},
+		{
+			Name: "Success : old messages deleted",
+			Args: args{Policies: map[string]history.Policy{"a": {Days: 7, Action: history.ActionDelete}, "b": {Days: 7, Action: history.ActionDelete}}},
+			Want: want{Result: history.PurgeResult{Deleted: 2}, Texts: []string{"b new", "a new"}},
+		},
+		{
+			Name: "Success : policies per tenant",
+			Args: args{Policies: map[string]history.Policy{"a": {Days: 60, Action: history.ActionDelete}, "b": {Days: 1, Action: history.ActionDelete}}},
+			Want: want{Result: history.PurgeResult{Deleted: 1}, Texts: []string{"b new", "a new", "a old"}},
+		},
+		{
+			Name: "Success : no days keeps messages",
+			Args: args{Policies: map[string]history.Policy{}},
+			Want: want{Texts: []string{"b new", "a new", "b old", "a old"}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			h := history.New(history.NewMemoryStore())
+			for _, m := range []history.Message{
+				{TenantID: "a", Recipient: 61400000001, Text: "a old", Time: now.AddDate(0, 0, -30)},
+				{TenantID: "b", Recipient: 61400000002, Text: "b old", Time: now.AddDate(0, 0, -8)},
+				{TenantID: "a", Recipient: 61400000001, Text: "a new", Time: now.AddDate(0, 0, -6)},
+				{TenantID: "b", Recipient: 61400000002, Text: "b new", Time: now},
+			} {
+				_, err := h.Record(ctx, m)
+				require.NoError(t, err, "record")
+			}
+			policy := func(tenantID string) history.Policy { return tt.Args.Policies[tenantID] }
+			res, err := h.Purge(ctx, now, policy)
+			require.NoError(t, err, "purge")
+			assert.Equal(t, tt.Want.Result, res, "result")
+
+			messages, err := h.Search(ctx, history.Filter{})
+			require.NoError(t, err, "search")
+			texts := []string{}
+			for _, m := range messages {
+				texts = append(texts, m.Text)
+				assert.Equal(t, m.Text == "", m.Anonymised, "anonymised")
+				assert.Equal(t, m.Text == "", m.Recipient == 0, "recipient removed")
+			}
+			assert.Equal(t, tt.Want.Texts, texts, "texts")
+
+			res, err = h.Purge(ctx, now, policy)
+			require.NoError(t, err, "purge again")
+			assert.Equal(t, history.PurgeResult{}, res, "nothing left to purge")
+		})
+	}
+}
+
+func TestErase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.OpenFile(path)
+	require.NoError(t, err, "open")
+	h := history.New(store)
+	for _, m := range []history.Message{
+		{TenantID: "a", Recipient: 61400000001, Text: "one"},
+		{TenantID: "b", Recipient: 61400000001, Text: "two"},
+		{TenantID: "a", Recipient: 61400000002, Text: "three"},
+	} {
+		_, err := h.Record(ctx, m)
+		require.NoError(t, err, "record")
+	}
+
+	n, err := h.Erase(ctx, 61400000001)
+	require.NoError(t, err, "erase")
+	assert.Equal(t, 2, n, "messages of every tenant erased")
+	before, err := os.Stat(path)
+	require.NoError(t, err, "stat")
+	n, err = h.Erase(ctx, 61400000009)
+	require.NoError(t, err, "erase unknown recipient")
+	assert.Equal(t, 0, n, "nothing erased")
+	after, err := os.Stat(path)
+	require.NoError(t, err, "stat again")
+	assert.True(t, os.SameFile(before, after), "file not rewritten")
+	m, err := h.Record(ctx, history.Message{TenantID: "a", Recipient: 61400000003, Text: "four"})
+	require.NoError(t, err, "record after erase")
+	assert.Equal(t, int64(4), m.Seq, "sequence continues")
+	require.NoError(t, store.Close(), "close")
+
+	store, err = history.OpenFile(path)
+	require.NoError(t, err, "reopen")
+	messages, err := history.New(store).Search(ctx, history.Filter{})
+	require.NoError(t, err, "search")
+	require.Len(t, messages, 2, "messages left")
+	assert.Equal(t, "four", messages[0].Text, "appended after the rewrite")
+	assert.Equal(t, "three", messages[1].Text, "other recipient kept")
+
+	// Erasing the latest message does not make its sequence number available again after a restart.
+	n, err = history.New(store).Erase(ctx, 61400000003)
+	require.NoError(t, err, "erase latest")
+	assert.Equal(t, 1, n, "latest message erased")
+	require.NoError(t, store.Close(), "close after erase")
+	store, err = history.OpenFile(path)
+	require.NoError(t, err, "reopen after erase")
+	defer store.Close()
+	m, err = history.New(store).Record(ctx, history.Message{TenantID: "a", Recipient: 61400000004, Text: "five"})
+	require.NoError(t, err, "record after reopen")
+	assert.Equal(t, int64(5), m.Seq, "sequence not reused")
+}
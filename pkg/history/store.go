@@ -0,0 +1,382 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// readChunk is the most bytes a FileStore reads at once when scanning back.
+const readChunk = 256 * 1024
+
+// Store persists messages in append order.
+type Store interface {
+	Append(ctx context.Context, m Message) error
+	// LastSeq returns the sequence number of the latest message appended, 0 when there is none.
+	LastSeq(ctx context.Context) (int64, error)
+	// ScanBefore calls fn for each message with a sequence number below before, or every message
+	// when before is 0, newest first until fn returns false or an error.
+	ScanBefore(ctx context.Context, before int64, fn func(Message) (bool, error)) error
+	// Rewrite replaces each message by the one fn returns, dropping those fn does not keep.
+	// The store is left as it is when fn changes nothing. LastSeq is not changed.
+	Rewrite(ctx context.Context, fn func(Message) (Message, bool)) error
+}
+
+// MemoryStore keeps messages in memory.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	messages []Message
+	last     int64
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append adds a message.
+func (s *MemoryStore) Append(ctx context.Context, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+	s.last = m.Seq
+	return nil
+}
+
+// LastSeq returns the sequence number of the latest message.
+func (s *MemoryStore) LastSeq(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last, nil
+}
+
+// ScanBefore iterates over a snapshot of the messages.
+func (s *MemoryStore) ScanBefore(ctx context.Context, before int64, fn func(Message) (bool, error)) error {
+	s.mu.RLock()
+	messages := s.messages[:len(s.messages):len(s.messages)]
+	s.mu.RUnlock()
+	for i := seqIndex(len(messages), func(i int) int64 { return messages[i].Seq }, before) - 1; i >= 0; i-- {
+		more, err := fn(messages[i])
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Rewrite replaces the messages, scans in progress keep their snapshot.
+func (s *MemoryStore) Rewrite(ctx context.Context, fn func(Message) (Message, bool)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([]Message, 0, len(s.messages))
+	changed := false
+	for _, m := range s.messages {
+		got, keep := fn(m)
+		if keep {
+			messages = append(messages, got)
+		}
+		changed = changed || !keep || got != m
+	}
+	if changed {
+		s.messages = messages
+	}
+	return nil
+}
+
+// line locates the message with sequence number seq in the history file.
+type line struct {
+	seq    int64
+	offset int64
+}
+
+// FileStore appends messages as JSON lines to a file. It indexes the lines by sequence number so
+// pages are read from the end of the file instead of scanning it whole. The last sequence number
+// is kept in a file next to it when rewrites drop messages, so it is not reused after a restart.
+type FileStore struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	last  int64
+	lines []line
+	size  int64
+}
+
+// OpenFile opens or creates the history file at path.
+func OpenFile(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open history file")
+	}
+	s := &FileStore{path: path, file: f}
+	if s.last, err = readSeq(seqPath(path)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	err = s.scan(func(m Message, n int) error {
+		s.lines = append(s.lines, line{seq: m.Seq, offset: s.size})
+		s.size += int64(n) + 1
+		if m.Seq > s.last {
+			s.last = m.Seq
+		}
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append writes the message.
+func (s *FileStore) Append(ctx context.Context, m Message) error {
+	b, err := json.Marshal(&m)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode message")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write message")
+	}
+	s.lines = append(s.lines, line{seq: m.Seq, offset: s.size})
+	s.size += int64(len(b)) + 1
+	s.last = m.Seq
+	return nil
+}
+
+// LastSeq returns the sequence number of the latest message.
+func (s *FileStore) LastSeq(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, nil
+}
+
+// ScanBefore reads the messages from the end of the file, a chunk of lines at a time.
+func (s *FileStore) ScanBefore(ctx context.Context, before int64, fn func(Message) (bool, error)) error {
+	// The file is opened with the index so a rewrite replacing it does not move the lines.
+	s.mu.Lock()
+	lines, size := s.lines[:len(s.lines):len(s.lines)], s.size
+	f, err := os.Open(s.path)
+	s.mu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "failed to open history file")
+	}
+	defer f.Close()
+
+	i := seqIndex(len(lines), func(i int) int64 { return lines[i].seq }, before)
+	end := size
+	if i < len(lines) {
+		end = lines[i].offset
+	}
+	for i > 0 {
+		j := i - 1
+		for j > 0 && end-lines[j-1].offset <= readChunk {
+			j--
+		}
+		buf := make([]byte, end-lines[j].offset)
+		if _, err := f.ReadAt(buf, lines[j].offset); err != nil {
+			return errors.Wrap(err, "failed to read history file")
+		}
+		for k := i - 1; k >= j; k-- {
+			b := buf[lines[k].offset-lines[j].offset:]
+			b = b[:bytes.IndexByte(b, '\n')]
+			var m Message
+			if err := json.Unmarshal(b, &m); err != nil {
+				return errors.Wrapf(err, "invalid message on line %d", k+1)
+			}
+			more, err := fn(m)
+			if err != nil {
+				return err
+			}
+			if !more {
+				return nil
+			}
+		}
+		i, end = j, lines[j].offset
+	}
+	return nil
+}
+
+// Rewrite writes the kept messages to a new file which then replaces the history file. The new
+// file is only created at the first message fn changes, starting with a copy of the lines before it.
+func (s *FileStore) Rewrite(ctx context.Context, fn func(Message) (Message, bool)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tmp *os.File
+	var w *bufio.Writer
+	var lines []line
+	var size int64
+	defer func() {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	err := s.scan(func(m Message, n int) error {
+		got, keep := fn(m)
+		if tmp == nil {
+			if keep && got == m {
+				lines = append(lines, line{seq: m.Seq, offset: size})
+				size += int64(n) + 1
+				return nil
+			}
+			var err error
+			if tmp, w, err = s.copyPrefix(size); err != nil {
+				return err
+			}
+		}
+		if !keep {
+			return nil
+		}
+		b, err := json.Marshal(&got)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode message")
+		}
+		lines = append(lines, line{seq: got.Seq, offset: size})
+		size += int64(len(b)) + 1
+		_, err = w.Write(append(b, '\n'))
+		return errors.Wrap(err, "failed to write message")
+	})
+	if err != nil || tmp == nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "failed to write history file")
+	}
+	if err := tmp.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync history file")
+	}
+	if err := writeSeq(seqPath(s.path), s.last); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return errors.Wrap(err, "failed to replace history file")
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open history file")
+	}
+	s.file.Close()
+	s.file, s.lines, s.size = f, lines, size
+	return nil
+}
+
+// copyPrefix creates the file replacing the history file with its first n bytes.
+func (s *FileStore) copyPrefix(n int64) (*os.File, *bufio.Writer, error) {
+	tmp, err := os.OpenFile(s.path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create history file")
+	}
+	w := bufio.NewWriter(tmp)
+	f, err := os.Open(s.path)
+	if err == nil {
+		_, err = io.CopyN(w, f, n)
+		f.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, nil, errors.Wrap(err, "failed to copy history file")
+	}
+	return tmp, w, nil
+}
+
+// seqPath returns the path of the file keeping the last sequence number of the history file at path.
+func seqPath(path string) string {
+	return path + ".seq"
+}
+
+// readSeq reads the last sequence number, 0 when the file does not exist.
+func readSeq(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read history sequence file")
+	}
+	seq, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	return seq, errors.Wrap(err, "invalid history sequence file")
+}
+
+// writeSeq replaces the last sequence number.
+func writeSeq(path string, seq int64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(seq, 10)+"\n"), 0600); err != nil {
+		return errors.Wrap(err, "failed to write history sequence file")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "failed to replace history sequence file")
+}
+
+// scan reads the messages from the file in order, with the length of their line.
+func (s *FileStore) scan(fn func(m Message, n int) error) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open history file")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		var m Message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return errors.Wrapf(err, "invalid message on line %d", n)
+		}
+		if err := fn(m, len(scanner.Bytes())); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(scanner.Err(), "failed to read history file")
+}
+
+// Ping checks the file is still open and at its path, and that files can be created next to it
+// as rewrites do.
+func (s *FileStore) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	open, err := s.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "history file is not open")
+	}
+	current, err := os.Stat(s.path)
+	if err != nil {
+		return errors.Wrap(err, "history file is missing")
+	}
+	if !os.SameFile(open, current) {
+		return errors.New("history file was replaced, messages are written to the old one")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".ping-*")
+	if err != nil {
+		return errors.Wrap(err, "history directory is not writable")
+	}
+	tmp.Close()
+	return errors.Wrap(os.Remove(tmp.Name()), "failed to remove history ping file")
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+// seqIndex returns the number of the n messages, in sequence order, numbered below before.
+func seqIndex(n int, seq func(int) int64, before int64) int {
+	if before <= 0 {
+		return n
+	}
+	return sort.Search(n, func(i int) bool { return seq(i) >= before })
+}
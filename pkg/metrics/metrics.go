@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+// Message outcomes counted by sms_messages_total.
+const (
+	Accepted   = "accepted"
+	Sent       = "sent"
+	Failed     = "failed"
+	Suppressed = "suppressed"
+)
+
+// Metrics holds the prometheus collectors of the app.
+type Metrics struct {
+	registry *prometheus.Registry
+	messages *prometheus.CounterVec
+	calls    *prometheus.HistogramVec
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// New creates the collectors in a dedicated registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sms_messages_total",
+			Help: "Messages by outcome, reason and provider.",
+		}, []string{"outcome", "reason", "provider"}),
+		calls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sms_operation_duration_seconds",
+			Help:    "Latency of Format, Send and ShortURL calls.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "provider", "result"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+	}
+	m.registry.MustRegister(
+		m.messages,
+		m.calls,
+		m.requests,
+		m.latency,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records request count and latency per route template.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		m.latency.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// Gauge registers a gauge reading its value from fn, such as a queue depth.
+func (m *Metrics) Gauge(name string, help string, fn func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, fn))
+}
+
+// Breakers registers the state of the breakers.
+func (m *Metrics) Breakers(breakers ...*breaker.Breaker) {
+	for _, b := range breakers {
+		b := b
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "sms_circuit_breaker_state",
+			Help:        "Circuit breaker state, 0 closed, 1 open, 2 half-open.",
+			ConstLabels: prometheus.Labels{"name": b.Name()},
+		}, func() float64 { return float64(b.State()) }))
+	}
+}
+
+func (m *Metrics) observe(operation string, provider string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.calls.WithLabelValues(operation, provider, result).Observe(time.Since(start).Seconds())
+}
+
+// reason classifies errors into a small set of label values, using the category of errors
+// returned by transmit.
+func reason(ctx context.Context, err error) string {
+	cause := errors.Cause(err)
+	if p, ok := cause.(*service.ProviderError); ok {
+		return string(p.Category())
+	}
+	switch {
+	case cause == breaker.ErrOpen:
+		return "circuit_open"
+	case cause == ratelimit.ErrBackpressure:
+		return "backpressure"
+	case ctx.Err() != nil:
+		return "cancelled"
+	}
+	return "error"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets streaming handlers flush through the recorder.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
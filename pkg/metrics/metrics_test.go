@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+type stubSender struct {
+	err error
+}
+
+func (s stubSender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
+	return service.Receipt{Provider: service.Provider}, s.err
+}
+
+func TestSender(t *testing.T) {
+	testTable := []struct {
+		Name    string
+		Err     error
+		Outcome string
+		Reason  string
+	}{
+		{Name: "sent", Outcome: Sent},
+		{Name: "provider error", Err: errors.New("boom"), Outcome: Failed, Reason: "error"},
+		{Name: "breaker open", Err: breaker.ErrOpen, Outcome: Failed, Reason: "circuit_open"},
+		{Name: "backpressure", Err: ratelimit.ErrBackpressure, Outcome: Failed, Reason: "backpressure"},
+		{Name: "out of credit", Err: &service.ProviderError{StatusCode: 402, Code: "LEDGER_ERROR"}, Outcome: Failed, Reason: "balance"},
+		{Name: "invalid recipient", Err: &service.ProviderError{StatusCode: 400, Code: "FIELD_INVALID", Description: "to is invalid"}, Outcome: Failed, Reason: "invalid_recipient"},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			m := New()
+			_, err := m.Sender(stubSender{err: tt.Err}, "transmit").Send(context.Background(), 61400000000, "text")
+			assert.Equal(t, tt.Err, err, "error")
+			assert.Equal(t, float64(1), testutil.ToFloat64(m.messages.WithLabelValues(Accepted, "", "transmit")), "accepted")
+			assert.Equal(t, float64(1), testutil.ToFloat64(m.messages.WithLabelValues(tt.Outcome, tt.Reason, "transmit")), "outcome")
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	m := New()
+	l := m.Limiter(ratelimit.New(ratelimit.NewMemoryStore()), "transmit")
+	limit := ratelimit.Limit{Count: 1, Period: time.Minute}
+	for _, key := range []string{"recipient:61400000000", "key:abc"} {
+		for i := 0; i < 2; i++ {
+			_, err := l.Take(context.Background(), key, limit, 1)
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.messages.WithLabelValues(Suppressed, "ratelimit_recipient", "transmit")), "recipient")
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.messages.WithLabelValues(Suppressed, "ratelimit_key", "transmit")), "requests are not messages")
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	rtr := mux.NewRouter()
+	rtr.Handle("/api/v1/admin/keys/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})).Methods("DELETE")
+	rtr.Use(m.Middleware)
+
+	req := httptest.NewRequest("DELETE", "/api/v1/admin/keys/abc", nil)
+	rtr.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("/api/v1/admin/keys/{id}", "DELETE", "404")), "requests")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `http_requests_total{code="404",method="DELETE",route="/api/v1/admin/keys/{id}"} 1`, "exposition")
+}
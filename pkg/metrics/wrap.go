@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+type formatter struct {
+	next     handler.Formatter
+	metrics  *Metrics
+	provider string
+}
+
+// Formatter records the latency of Format calls.
+func (m *Metrics) Formatter(next handler.Formatter, provider string) handler.Formatter {
+	return &formatter{next: next, metrics: m, provider: provider}
+}
+
+func (f *formatter) Format(ctx context.Context, phoneNumber string) (int64, bool, error) {
+	start := time.Now()
+	number, valid, err := f.next.Format(ctx, phoneNumber)
+	f.metrics.observe("format", f.provider, start, err)
+	return number, valid, err
+}
+
+type sender struct {
+	next     handler.Sender
+	metrics  *Metrics
+	provider string
+}
+
+// Sender counts accepted, sent and failed messages and records the latency of Send calls.
+func (m *Metrics) Sender(next handler.Sender, provider string) handler.Sender {
+	return &sender{next: next, metrics: m, provider: provider}
+}
+
+func (s *sender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
+	s.metrics.messages.WithLabelValues(Accepted, "", s.provider).Inc()
+	start := time.Now()
+	receipt, err := s.next.Send(ctx, phoneNumber, text)
+	s.metrics.observe("send", s.provider, start, err)
+	if err != nil {
+		s.metrics.messages.WithLabelValues(Failed, reason(ctx, err), s.provider).Inc()
+		return receipt, err
+	}
+	s.metrics.messages.WithLabelValues(Sent, "", s.provider).Inc()
+	return receipt, nil
+}
+
+type shorter struct {
+	next     service.Shorter
+	metrics  *Metrics
+	provider string
+}
+
+// Shorter records the latency of ShortURL calls.
+func (m *Metrics) Shorter(next service.Shorter, provider string) service.Shorter {
+	return &shorter{next: next, metrics: m, provider: provider}
+}
+
+func (s *shorter) ShortURL(ctx context.Context, longURL string) (string, error) {
+	start := time.Now()
+	short, err := s.next.ShortURL(ctx, longURL)
+	s.metrics.observe("shorten", s.provider, start, err)
+	return short, err
+}
+
+type limiter struct {
+	next     handler.Limiter
+	metrics  *Metrics
+	provider string
+}
+
+// Limiter counts messages suppressed by per recipient and per tenant rate limits.
+func (m *Metrics) Limiter(next handler.Limiter, provider string) handler.Limiter {
+	return &limiter{next: next, metrics: m, provider: provider}
+}
+
+func (l *limiter) Take(ctx context.Context, key string, limit ratelimit.Limit, n int) (ratelimit.Result, error) {
+	res, err := l.next.Take(ctx, key, limit, n)
+	if err == nil && !res.Allowed && countsMessages(key) {
+		l.metrics.messages.WithLabelValues(Suppressed, "ratelimit_"+bucket(key), l.provider).Add(float64(n))
+	}
+	return res, err
+}
+
+func (l *limiter) Refund(ctx context.Context, key string, limit ratelimit.Limit, n int) error {
+	return l.next.Refund(ctx, key, limit, n)
+}
+
+// bucket returns the kind of bucket from keys such as recipient:61400000000.
+func bucket(key string) string {
+	if i := strings.Index(key, ":"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// countsMessages reports whether the bucket counts messages rather than requests.
+func countsMessages(key string) bool {
+	switch bucket(key) {
+	case "recipient", "tenant":
+		return true
+	}
+	return false
+}
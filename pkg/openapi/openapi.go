@@ -0,0 +1,509 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidJSON returned when a request body is not JSON.
+var ErrInvalidJSON = errors.New("body is not valid JSON")
+
+// Document represent the subset of an OpenAPI 3 document the app uses.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+// Info represent the API title and version.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem represent the operations of a path, keyed by lower case method.
+type PathItem map[string]*Operation
+
+// Operation represent a route.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+// Parameter represent a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody represent the accepted bodies of an operation.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response represent a response of an operation, or a reference to a shared one.
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType represent the schema of a content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components represent the shared schemas, responses and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme represent a way to authenticate.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+}
+
+// Schema represent the subset of JSON schema used to describe values.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	// ErrorCodes are the error codes reported for violated keywords, such as required.
+	ErrorCodes map[string]string `json:"x-error-codes,omitempty"`
+}
+
+// Violation describes where a value breaks the spec.
+type Violation struct {
+	// Field is the path of the value, such as texts[1], or the name of a parameter.
+	Field string
+	// Keyword is the violated schema keyword, such as required or maxLength.
+	Keyword string
+	// Code is the error code the spec gives the violation, empty when it gives none.
+	Code    string
+	Message string
+}
+
+// Parse reads a document and checks its references resolve.
+func Parse(data []byte) (*Document, error) {
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, errors.Wrap(err, "failed to parse openapi document")
+	}
+	for path, item := range d.Paths {
+		for method, op := range *item {
+			if err := d.checkRefs(op); err != nil {
+				return nil, errors.Wrapf(err, "%s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+	return &d, nil
+}
+
+func (d *Document) checkRefs(op *Operation) error {
+	for code, res := range op.Responses {
+		r, err := d.response(res)
+		if err != nil {
+			return errors.Wrapf(err, "response %s", code)
+		}
+		for _, mt := range r.Content {
+			if err := d.checkSchema(mt.Schema); err != nil {
+				return errors.Wrapf(err, "response %s", code)
+			}
+		}
+	}
+	if op.RequestBody != nil {
+		for _, mt := range op.RequestBody.Content {
+			if err := d.checkSchema(mt.Schema); err != nil {
+				return errors.Wrap(err, "request body")
+			}
+		}
+	}
+	for _, p := range op.Parameters {
+		if err := d.checkSchema(p.Schema); err != nil {
+			return errors.Wrapf(err, "parameter %s", p.Name)
+		}
+	}
+	return nil
+}
+
+// checkSchema resolves every reference of s, stopping at referenced schemas as they are checked by name.
+func (d *Document) checkSchema(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		_, err := d.schema(s)
+		return err
+	}
+	for _, p := range s.Properties {
+		if err := d.checkSchema(p); err != nil {
+			return err
+		}
+	}
+	if err := d.checkSchema(s.Items); err != nil {
+		return err
+	}
+	return d.checkSchema(s.AdditionalProperties)
+}
+
+// Operation finds the operation of a request, matching {name} segments of path templates.
+// The template is returned along with the operation.
+func (d *Document) Operation(method string, path string) (*Operation, string, bool) {
+	method = strings.ToLower(method)
+	for template, item := range d.Paths {
+		if !matchPath(template, path) {
+			continue
+		}
+		op, ok := (*item)[method]
+		return op, template, ok
+	}
+	return nil, "", false
+}
+
+func matchPath(template string, path string) bool {
+	want := strings.Split(strings.Trim(template, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if strings.HasPrefix(want[i], "{") && strings.HasSuffix(want[i], "}") {
+			if got[i] == "" {
+				return false
+			}
+			continue
+		}
+		if want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRequest checks the query parameters and JSON body of a request to op.
+// Unknown properties are accepted so older clients keep working.
+// ErrInvalidJSON is returned when the body cannot be parsed.
+func (d *Document) ValidateRequest(op *Operation, query url.Values, body []byte) ([]Violation, error) {
+	var vs []Violation
+	for _, p := range op.Parameters {
+		if p.In != "query" {
+			continue
+		}
+		v, ok := query[p.Name]
+		if !ok || len(v) == 0 {
+			if p.Required {
+				vs = append(vs, violation(p.Name, "required", p.Schema, p.Name+" is required"))
+			}
+			continue
+		}
+		vs = append(vs, d.validateParameter(p, v[0])...)
+	}
+	if op.RequestBody == nil {
+		return vs, nil
+	}
+	mt, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return vs, nil
+	}
+	if len(body) == 0 && !op.RequestBody.Required {
+		return vs, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return vs, ErrInvalidJSON
+	}
+	return append(vs, d.validate(mt.Schema, value, "", false)...), nil
+}
+
+// ValidateResponse checks a response of op. JSON bodies must match the schema
+// exactly, undeclared properties are violations so handlers cannot drift from the spec.
+func (d *Document) ValidateResponse(op *Operation, status int, contentType string, body []byte) ([]Violation, error) {
+	res, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if res, ok = op.Responses["default"]; !ok {
+			return nil, errors.Errorf("status %d not in spec", status)
+		}
+	}
+	r, err := d.response(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Content) == 0 {
+		if len(body) > 0 {
+			return nil, errors.Errorf("status %d has no body in spec", status)
+		}
+		return nil, nil
+	}
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "content type %q", contentType)
+	}
+	mt, ok := r.Content[media]
+	if !ok {
+		return nil, errors.Errorf("content type %s of status %d not in spec", media, status)
+	}
+	if media != "application/json" && media != "application/problem+json" {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, errors.Wrap(err, "response body")
+	}
+	return d.validate(mt.Schema, value, "", true), nil
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	name := strings.TrimPrefix(r.Ref, "#/components/responses/")
+	res, ok := d.Components.Responses[name]
+	if !ok || name == r.Ref {
+		return nil, errors.Errorf("unknown response %s", r.Ref)
+	}
+	return res, nil
+}
+
+func (d *Document) schema(s *Schema) (*Schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	ref, ok := d.Components.Schemas[name]
+	if !ok || name == s.Ref {
+		return nil, errors.Errorf("unknown schema %s", s.Ref)
+	}
+	return ref, nil
+}
+
+func (d *Document) validateParameter(p Parameter, v string) []Violation {
+	s, err := d.schema(p.Schema)
+	if err != nil {
+		return []Violation{{Field: p.Name, Keyword: "$ref", Message: err.Error()}}
+	}
+	var value interface{} = v
+	switch s.Type {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return []Violation{violation(p.Name, "type", s, p.Name+" must be "+article(s.Type))}
+		}
+		value = n
+	case "boolean":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return []Violation{violation(p.Name, "type", s, p.Name+" must be "+article(s.Type))}
+		}
+		value = b
+	}
+	return d.validate(s, value, p.Name, false)
+}
+
+// validate checks value against s, properties are checked in name order so violations are stable.
+func (d *Document) validate(s *Schema, value interface{}, field string, strict bool) []Violation {
+	s, err := d.schema(s)
+	if err != nil {
+		return []Violation{{Field: field, Keyword: "$ref", Message: err.Error()}}
+	}
+	name := field
+	if name == "" {
+		name = "body"
+	}
+	if !hasType(s.Type, value) {
+		return []Violation{violation(field, "type", s, fmt.Sprintf("%s must be %s", name, article(s.Type)))}
+	}
+	var vs []Violation
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		vs = append(vs, violation(field, "enum", s, fmt.Sprintf("%s must be one of %s", name, enumList(s.Enum))))
+	}
+	switch v := value.(type) {
+	case string:
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			msg := fmt.Sprintf("%s must be at least %d characters", name, *s.MinLength)
+			if *s.MinLength == 1 {
+				msg = name + " must not be empty"
+			}
+			vs = append(vs, violation(field, "minLength", s, msg))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			vs = append(vs, violation(field, "maxLength", s, fmt.Sprintf("%s must be at most %d characters", name, *s.MaxLength)))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				vs = append(vs, violation(field, "format", s, name+" must be an RFC 3339 date-time"))
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			vs = append(vs, violation(field, "minimum", s, fmt.Sprintf("%s must be at least %v", name, *s.Minimum)))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			vs = append(vs, violation(field, "maximum", s, fmt.Sprintf("%s must be at most %v", name, *s.Maximum)))
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			msg := fmt.Sprintf("%s must have at least %d items", name, *s.MinItems)
+			if *s.MinItems == 1 {
+				msg = name + " must not be empty"
+			}
+			vs = append(vs, violation(field, "minItems", s, msg))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			vs = append(vs, violation(field, "maxItems", s, fmt.Sprintf("%s must have at most %d items", name, *s.MaxItems)))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				vs = append(vs, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i), strict)...)
+			}
+		}
+	case map[string]interface{}:
+		vs = append(vs, d.validateObject(s, v, field, strict)...)
+	}
+	return vs
+}
+
+func (d *Document) validateObject(s *Schema, v map[string]interface{}, field string, strict bool) []Violation {
+	var vs []Violation
+	names := make([]string, 0, len(s.Properties)+len(v))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	for name := range v {
+		if _, ok := s.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := name
+		if field != "" {
+			path = field + "." + name
+		}
+		prop, declared := s.Properties[name]
+		value, present := v[name]
+		switch {
+		case declared && present:
+			vs = append(vs, d.validate(prop, value, path, strict)...)
+		case declared && contains(s.Required, name):
+			ps, err := d.schema(prop)
+			if err != nil {
+				ps = prop
+			}
+			vs = append(vs, violation(path, "required", ps, path+" is required"))
+		case !declared && s.AdditionalProperties != nil:
+			vs = append(vs, d.validate(s.AdditionalProperties, value, path, strict)...)
+		case !declared && strict && len(s.Properties) > 0:
+			vs = append(vs, Violation{Field: path, Keyword: "additionalProperties", Message: path + " is not in the spec"})
+		}
+	}
+	return vs
+}
+
+func violation(field string, keyword string, s *Schema, message string) Violation {
+	v := Violation{Field: field, Keyword: keyword, Message: message}
+	if s != nil {
+		v.Code = s.ErrorCodes[keyword]
+	}
+	return v
+}
+
+func hasType(t string, value interface{}) bool {
+	switch t {
+	case "":
+		return true
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func article(t string) string {
+	switch t {
+	case "integer", "array", "object":
+		return "an " + t
+	}
+	return "a " + t
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []interface{}) string {
+	list := make([]string, len(enum))
+	for i, e := range enum {
+		list[i] = fmt.Sprint(e)
+	}
+	return strings.Join(list, ", ")
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package openapi_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/openapi"
+)
+
+func TestSpec(t *testing.T) {
+	doc, err := openapi.Spec()
+	require.NoError(t, err, "spec")
+	assert.Equal(t, "3.0.3", doc.OpenAPI, "version")
+	for path, item := range doc.Paths {
+		for method, op := range *item {
+			assert.NotEmpty(t, op.OperationID, "%s %s operation id", method, path)
+			assert.NotEmpty(t, op.Responses, "%s %s responses", method, path)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	_, err := openapi.Parse([]byte(`{"paths": {"/a": {"get": {"responses": {"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}}`))
+	assert.EqualError(t, err, "GET /a: response 200: unknown schema #/components/schemas/Missing", "unknown schema")
+	_, err = openapi.Parse([]byte(`{"paths": {"/a": {"get": {"responses": {"default": {"$ref": "#/components/responses/Missing"}}}}}}`))
+	assert.EqualError(t, err, "GET /a: response default: unknown response #/components/responses/Missing", "unknown response")
+}
+
+func TestOperation(t *testing.T) {
+	doc := openapi.MustSpec()
+	type want struct {
+		ID       string
+		Template string
+		Found    bool
+	}
+	testTable := []struct {
+		Name   string
+		Method string
+		Path   string
+		Want   want
+	}{
+		{
+			Name:   "Success : static path",
+			Method: "POST",
+			Path:   "/api/v1/sms/send",
+			Want:   want{ID: "send", Template: "/api/v1/sms/send", Found: true},
+		},
+		{
+			Name:   "Success : path parameter",
+			Method: "POST",
+			Path:   "/api/v1/admin/keys/k1/rotate",
+			Want:   want{ID: "rotateKey", Template: "/api/v1/admin/keys/{id}/rotate", Found: true},
+		},
+		{
+			Name:   "Failure : method not in spec",
+			Method: "GET",
+			Path:   "/api/v1/sms/send",
+		},
+		{
+			Name:   "Failure : path not in spec",
+			Method: "GET",
+			Path:   "/api/v1/admin/keys/k1/other",
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			op, template, ok := doc.Operation(tt.Method, tt.Path)
+			assert.Equal(t, tt.Want.Found, ok, "found")
+			if !tt.Want.Found {
+				return
+			}
+			assert.Equal(t, tt.Want.ID, op.OperationID, "operation")
+			assert.Equal(t, tt.Want.Template, template, "template")
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := openapi.MustSpec()
+	type args struct {
+		Method string
+		Path   string
+		Query  string
+		Body   string
+	}
+	type want struct {
+		Err        error
+		Violations []openapi.Violation
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : valid body",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Body: `{"phone_number":"0400000000","texts":["hello", "", "привет"]}`},
+		},
+		{
+			Name: "Success : unknown properties accepted",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Body: `{"phone_number":"0400000000","texts":["hello"],"priority":1}`},
+		},
+		{
+			Name: "Success : valid query",
+			Args: args{Method: "GET", Path: "/api/v1/admin/audit", Query: "since=2019-04-01T00:00:00Z&limit=10"},
+		},
+		{
+			Name: "Failure : invalid JSON",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Body: `{"phone_number":`},
+			Want: want{Err: openapi.ErrInvalidJSON},
+		},
+		{
+			Name: "Failure : empty body",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send"},
+			Want: want{Err: openapi.ErrInvalidJSON},
+		},
+		{
+			Name: "Failure : every violation with its code",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Body: `{"phone_number":"","texts":["ok", 1, "` + strings.Repeat("x", 161) + `"]}`},
+			Want: want{Violations: []openapi.Violation{
+				{Field: "phone_number", Keyword: "minLength", Code: "missing_phone_number", Message: "phone_number must not be empty"},
+				{Field: "texts[1]", Keyword: "type", Message: "texts[1] must be a string"},
+				{Field: "texts[2]", Keyword: "maxLength", Code: "text_too_long", Message: "texts[2] must be at most 160 characters"},
+			}},
+		},
+		{
+			Name: "Failure : required properties",
+			Args: args{Method: "POST", Path: "/api/v1/sms/send", Body: `{}`},
+			Want: want{Violations: []openapi.Violation{
+				{Field: "phone_number", Keyword: "required", Code: "missing_phone_number", Message: "phone_number is required"},
+				{Field: "texts", Keyword: "required", Code: "missing_texts", Message: "texts is required"},
+			}},
+		},
+		{
+			Name: "Failure : enum",
+			Args: args{Method: "POST", Path: "/api/v1/admin/keys", Body: `{"name":"partner","scopes":["send","root"]}`},
+			Want: want{Violations: []openapi.Violation{
+				{Field: "scopes[1]", Keyword: "enum", Message: "scopes[1] must be one of send, read, admin"},
+			}},
+		},
+		{
+			Name: "Failure : query parameters",
+			Args: args{Method: "GET", Path: "/api/v1/admin/audit", Query: "since=yesterday&after=x&limit=0"},
+			Want: want{Violations: []openapi.Violation{
+				{Field: "since", Keyword: "format", Message: "since must be an RFC 3339 date-time"},
+				{Field: "after", Keyword: "type", Message: "after must be an integer"},
+				{Field: "limit", Keyword: "minimum", Message: "limit must be at least 1"},
+			}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			op, _, ok := doc.Operation(tt.Args.Method, tt.Args.Path)
+			require.True(t, ok, "operation")
+			query, err := url.ParseQuery(tt.Args.Query)
+			require.NoError(t, err, "query")
+			violations, err := doc.ValidateRequest(op, query, []byte(tt.Args.Body))
+			assert.Equal(t, tt.Want.Err, err, "error")
+			assert.Equal(t, tt.Want.Violations, violations, "violations")
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc := openapi.MustSpec()
+	op, _, ok := doc.Operation("POST", "/api/v2/sms/send")
+	require.True(t, ok, "operation")
+	type args struct {
+		Status      int
+		ContentType string
+		Body        string
+	}
+	type want struct {
+		Err        string
+		Violations []openapi.Violation
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : described response",
+			Args: args{Status: 200, ContentType: "application/json; charset=UTF-8", Body: `{"results":[{"index":0,"status":"sent","segments":1,"encoding":"gsm7"}]}`},
+		},
+		{
+			Name: "Success : problem as default response",
+			Args: args{Status: 429, ContentType: "application/problem+json", Body: `{"type":"urn:sms-app:problem:rate_limited","title":"Too Many Requests","status":429,"code":"rate_limited","message":"slow down"}`},
+		},
+		{
+			Name: "Failure : undeclared property",
+			Args: args{Status: 200, ContentType: "application/json", Body: `{"results":[{"index":0,"status":"sent","segments":1,"cost":0.05}]}`},
+			Want: want{Violations: []openapi.Violation{
+				{Field: "results[0].cost", Keyword: "additionalProperties", Message: "results[0].cost is not in the spec"},
+			}},
+		},
+		{
+			Name: "Failure : missing and invalid properties",
+			Args: args{Status: 200, ContentType: "application/json", Body: `{"results":[{"index":0.5,"status":"queued"}]}`},
+			Want: want{Violations: []openapi.Violation{
+				{Field: "results[0].index", Keyword: "type", Message: "results[0].index must be an integer"},
+				{Field: "results[0].segments", Keyword: "required", Message: "results[0].segments is required"},
+				{Field: "results[0].status", Keyword: "enum", Message: "results[0].status must be one of sent, failed, skipped"},
+			}},
+		},
+		{
+			Name: "Failure : content type not in spec",
+			Args: args{Status: 200, ContentType: "text/plain", Body: `ok`},
+			Want: want{Err: "content type text/plain of status 200 not in spec"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			violations, err := doc.ValidateResponse(op, tt.Args.Status, tt.Args.ContentType, []byte(tt.Args.Body))
+			if tt.Want.Err != "" {
+				assert.EqualError(t, err, tt.Want.Err, "error")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Violations, violations, "violations")
+		})
+	}
+}
@@ -0,0 +1,714 @@
+package openapi
+
+// Spec returns the OpenAPI document of the app's routes.
+// Request bodies and parameters are validated against it, and handler tests fail
+// when a response is not described by it.
+func Spec() (*Document, error) {
+	return Parse([]byte(specJSON))
+}
+
+// MustSpec returns the document like Spec, panicking when it is invalid.
+// The package tests parse the document, so it only panics on untested changes.
+func MustSpec() *Document {
+	d, err := Spec()
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// JSON returns the document as served at /openapi.json.
+func JSON() []byte {
+	return []byte(specJSON)
+}
+
+const specJSON = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "sms-app",
+    "description": "Sends sms through Transmit, shortening links with bitly. Clients may also authenticate with a client certificate mapped to an identity when the server requires mTLS.",
+    "version": "1.0"
+  },
+  "security": [{"bearer": []}, {"apiKey": []}],
+  "paths": {
+    "/api/v1/sms": {
+      "get": {
+        "operationId": "history",
+        "summary": "Search the messages sent for the caller's tenant, newest first",
+        "tags": ["sms"],
+        "parameters": [
+          {"name": "recipient", "in": "query", "description": "Phone number the messages were sent to, formatted like a number to send to", "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "status", "in": "query", "schema": {"type": "string", "enum": ["sent", "failed"]}},
+          {"name": "key_id", "in": "query", "description": "ID of the API key that sent the messages", "schema": {"type": "string"}},
+          {"name": "provider", "in": "query", "schema": {"type": "string"}},
+          {"name": "text", "in": "query", "description": "Text the messages contain, ignoring case", "schema": {"type": "string"}},
+          {"name": "cursor", "in": "query", "description": "next_cursor of the previous page", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "description": "Messages per page, 50 by default", "schema": {"type": "integer", "minimum": 1, "maximum": 1000}},
+          {"name": "format", "in": "query", "description": "json by default, or csv as when accepting text/csv", "schema": {"type": "string", "enum": ["json", "csv"]}}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of messages, the next page is linked by the Link header",
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/HistoryPage"}},
+              "text/csv": {"schema": {"type": "string"}}
+            }
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/sms/send": {
+      "post": {
+        "operationId": "send",
+        "summary": "Send texts to a phone number",
+        "tags": ["sms"],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SendRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "Status of each non empty text, failed texts are explained in errors",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SendResult"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v2/sms/send": {
+      "post": {
+        "operationId": "sendV2",
+        "summary": "Send texts to a phone number, with one result per text",
+        "tags": ["sms"],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SendRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "One result per input text, in the order of texts",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SendResponse"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/events": {
+      "get": {
+        "operationId": "events",
+        "summary": "Stream the message events of the caller's tenant as server-sent events",
+        "tags": ["sms"],
+        "parameters": [
+          {"name": "type", "in": "query", "description": "Event types to stream, separated by commas, every type by default", "schema": {"type": "string"}},
+          {"name": "message_id", "in": "query", "description": "Message IDs to stream, separated by commas, every message by default", "schema": {"type": "string"}},
+          {"name": "last_event_id", "in": "query", "description": "ID of the last event received, for clients unable to send Last-Event-ID", "schema": {"type": "integer", "minimum": 0}},
+          {"name": "Last-Event-ID", "in": "header", "description": "ID of the last event received, buffered events after it are replayed", "schema": {"type": "integer", "minimum": 0}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Events named by their type with an Event as data, and a reset event when events after Last-Event-ID were lost",
+            "content": {"text/event-stream": {"schema": {"type": "string"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/subjects/{phone}": {
+      "delete": {
+        "operationId": "eraseSubject",
+        "summary": "Erase the messages sent to a phone number, of every tenant",
+        "tags": ["admin"],
+        "parameters": [{"name": "phone", "in": "path", "required": true, "description": "International number starting with +, or a number formatted like a number to send to", "schema": {"type": "string"}}],
+        "responses": {
+          "200": {
+            "description": "The messages were erased and the erasure recorded in the audit log",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Erasure"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/keys": {
+      "post": {
+        "operationId": "issueKey",
+        "summary": "Issue an API key, keys without a tenant send for the default tenant",
+        "tags": ["admin"],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/KeyRequest"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The key and its token, the token is not shown again",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedKey"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "get": {
+        "operationId": "listKeys",
+        "summary": "List API keys",
+        "tags": ["admin"],
+        "responses": {
+          "200": {
+            "description": "Every key, revoked keys included",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/KeyList"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/keys/{id}/rotate": {
+      "post": {
+        "operationId": "rotateKey",
+        "summary": "Replace the token of an API key",
+        "tags": ["admin"],
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {
+          "200": {
+            "description": "The key and its new token",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedKey"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/keys/{id}": {
+      "delete": {
+        "operationId": "revokeKey",
+        "summary": "Revoke an API key",
+        "tags": ["admin"],
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {
+          "200": {
+            "description": "The revoked key",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Key"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/audit": {
+      "get": {
+        "operationId": "auditLog",
+        "summary": "Query the audit log",
+        "tags": ["admin"],
+        "parameters": [
+          {"name": "action", "in": "query", "schema": {"type": "string"}},
+          {"name": "actor", "in": "query", "description": "ID of the key that acted", "schema": {"type": "string"}},
+          {"name": "tenant_id", "in": "query", "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "after", "in": "query", "description": "Sequence number to continue after, the next of the previous page", "schema": {"type": "integer", "minimum": 0}},
+          {"name": "limit", "in": "query", "description": "Entries per page, 100 by default", "schema": {"type": "integer", "minimum": 1, "maximum": 1000}}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of entries",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuditPage"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/audit/export": {
+      "get": {
+        "operationId": "exportAudit",
+        "summary": "Download the audit log as JSON lines, every matching entry by default",
+        "tags": ["admin"],
+        "parameters": [
+          {"name": "action", "in": "query", "schema": {"type": "string"}},
+          {"name": "actor", "in": "query", "schema": {"type": "string"}},
+          {"name": "tenant_id", "in": "query", "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "after", "in": "query", "schema": {"type": "integer", "minimum": 0}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 1000}}
+        ],
+        "responses": {
+          "200": {
+            "description": "One audit entry per line",
+            "content": {"application/x-ndjson": {"schema": {"type": "string"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/audit/verify": {
+      "get": {
+        "operationId": "verifyAudit",
+        "summary": "Check the hash chain of the audit log",
+        "tags": ["admin"],
+        "responses": {
+          "200": {
+            "description": "The chain is intact",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuditVerification"}}}
+          },
+          "409": {
+            "description": "The chain is broken after the verified entries",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuditVerification"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/admin/log/level": {
+      "get": {
+        "operationId": "getLogLevel",
+        "summary": "Read the log level",
+        "tags": ["admin"],
+        "responses": {
+          "200": {
+            "description": "The current level",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogLevel"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "put": {
+        "operationId": "setLogLevel",
+        "summary": "Change the log level until the next restart or reload",
+        "tags": ["admin"],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogLevel"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "The new level",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogLevel"}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/webhooks/transmit/delivery": {
+      "get": {
+        "operationId": "transmitDelivery",
+        "summary": "Receive a delivery receipt from Transmit, called at the callback URL given with the message",
+        "tags": ["webhooks"],
+        "security": [],
+        "parameters": [
+          {"name": "tenant", "in": "query", "required": true, "description": "Tenant of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message", "in": "query", "required": true, "description": "ID of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "sig", "in": "query", "required": true, "description": "Signature of tenant and message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message_id", "in": "query", "description": "Transmit's ID of the message", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "description": "delivered, pending, soft-bounce or hard-bounce", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "204": {"description": "The receipt was accepted and its event published, receipts of pending messages are ignored"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "post": {
+        "operationId": "transmitDeliveryForm",
+        "summary": "Receive a delivery receipt from Transmit posted as a form",
+        "tags": ["webhooks"],
+        "security": [],
+        "parameters": [
+          {"name": "tenant", "in": "query", "required": true, "description": "Tenant of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message", "in": "query", "required": true, "description": "ID of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "sig", "in": "query", "required": true, "description": "Signature of tenant and message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message_id", "in": "query", "description": "Transmit's ID of the message", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "description": "delivered, pending, soft-bounce or hard-bounce", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "204": {"description": "The receipt was accepted and its event published, receipts of pending messages are ignored"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/api/v1/webhooks/transmit/reply": {
+      "get": {
+        "operationId": "transmitReply",
+        "summary": "Receive a reply to a message from Transmit, called at the callback URL given with the message",
+        "tags": ["webhooks"],
+        "security": [],
+        "parameters": [
+          {"name": "tenant", "in": "query", "required": true, "description": "Tenant of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message", "in": "query", "required": true, "description": "ID of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "sig", "in": "query", "required": true, "description": "Signature of tenant and message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message_id", "in": "query", "description": "Transmit's ID of the message", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "204": {"description": "The reply was accepted and its inbound event published"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "post": {
+        "operationId": "transmitReplyForm",
+        "summary": "Receive a reply to a message from Transmit posted as a form",
+        "tags": ["webhooks"],
+        "security": [],
+        "parameters": [
+          {"name": "tenant", "in": "query", "required": true, "description": "Tenant of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message", "in": "query", "required": true, "description": "ID of the message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "sig", "in": "query", "required": true, "description": "Signature of tenant and message, from the callback URL", "schema": {"type": "string"}},
+          {"name": "message_id", "in": "query", "description": "Transmit's ID of the message", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "204": {"description": "The reply was accepted and its inbound event published"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "operationId": "liveness",
+        "summary": "Report the process is able to serve requests",
+        "tags": ["ops"],
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "Alive",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}
+          }
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "operationId": "readiness",
+        "summary": "Report whether dependencies are usable",
+        "tags": ["ops"],
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "Ready",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}
+          },
+          "503": {
+            "description": "A critical check failed",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}
+          }
+        }
+      }
+    },
+    "/status": {
+      "get": {
+        "operationId": "status",
+        "summary": "Report the version, commit and uptime",
+        "tags": ["ops"],
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "Build and uptime",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Status"}}}
+          }
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "operationId": "metrics",
+        "summary": "Prometheus metrics, served when metrics are enabled",
+        "tags": ["ops"],
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "Metrics in the Prometheus text format",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "openapi",
+        "summary": "This document",
+        "tags": ["ops"],
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "The OpenAPI document",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer", "description": "API key sent as a bearer token"},
+      "apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"}
+    },
+    "responses": {
+      "Problem": {
+        "description": "RFC 7807 problem, clients should act on code",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      }
+    },
+    "schemas": {
+      "SendRequest": {
+        "type": "object",
+        "required": ["phone_number", "texts"],
+        "properties": {
+          "phone_number": {
+            "type": "string",
+            "minLength": 1,
+            "description": "Number in national or international format, formatted by Transmit",
+            "x-error-codes": {"required": "missing_phone_number", "minLength": "missing_phone_number"}
+          },
+          "texts": {
+            "type": "array",
+            "minItems": 1,
+            "description": "Texts sent as separate messages, empty texts are skipped. Tenants limit the number of texts, 3 by default",
+            "x-error-codes": {"required": "missing_texts", "minItems": "missing_texts"},
+            "items": {
+              "type": "string",
+              "maxLength": 160,
+              "description": "At most 160 bytes, multi-byte characters count for each of their bytes",
+              "x-error-codes": {"maxLength": "text_too_long"}
+            }
+          }
+        }
+      },
+      "SendResult": {
+        "type": "object",
+        "required": ["status"],
+        "properties": {
+          "status": {"type": "array", "items": {"type": "string", "enum": ["success", "failed"]}},
+          "errors": {"type": "array", "items": {"$ref": "#/components/schemas/FieldError"}}
+        }
+      },
+      "SendResponse": {
+        "type": "object",
+        "required": ["results"],
+        "properties": {
+          "results": {"type": "array", "items": {"$ref": "#/components/schemas/TextResult"}}
+        }
+      },
+      "TextResult": {
+        "type": "object",
+        "required": ["index", "status", "segments"],
+        "properties": {
+          "index": {"type": "integer", "description": "Position of the text in the request"},
+          "message_id": {"type": "string", "description": "Our ID of the message, in logs and the audit log"},
+          "status": {"type": "string", "enum": ["sent", "failed", "skipped"]},
+          "segments": {"type": "integer", "minimum": 0},
+          "encoding": {"type": "string", "enum": ["gsm7", "ucs2"]},
+          "provider": {"type": "string"},
+          "provider_message_id": {"type": "string"},
+          "links": {"type": "array", "items": {"$ref": "#/components/schemas/Link"}},
+          "error_code": {"type": "string"},
+          "provider_code": {"type": "string"}
+        }
+      },
+      "Link": {
+        "type": "object",
+        "required": ["url", "short"],
+        "properties": {
+          "url": {"type": "string"},
+          "short": {"type": "string"}
+        }
+      },
+      "Problem": {
+        "type": "object",
+        "required": ["type", "title", "status", "code", "message"],
+        "properties": {
+          "type": {"type": "string"},
+          "title": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string"},
+          "instance": {"type": "string"},
+          "code": {"type": "string", "description": "Stable error code"},
+          "message": {"type": "string", "description": "Repeats detail for clients of the previous error format"},
+          "errors": {"type": "array", "items": {"$ref": "#/components/schemas/FieldError"}},
+          "provider_code": {"type": "string"},
+          "request_id": {"type": "string"}
+        }
+      },
+      "FieldError": {
+        "type": "object",
+        "required": ["field", "code", "detail"],
+        "properties": {
+          "field": {"type": "string"},
+          "code": {"type": "string"},
+          "detail": {"type": "string"},
+          "provider_code": {"type": "string"}
+        }
+      },
+      "KeyRequest": {
+        "type": "object",
+        "required": ["name", "scopes"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1},
+          "tenant_id": {"type": "string"},
+          "scopes": {"type": "array", "minItems": 1, "items": {"$ref": "#/components/schemas/Scope"}}
+        }
+      },
+      "Scope": {"type": "string", "enum": ["send", "read", "admin"]},
+      "Key": {
+        "type": "object",
+        "required": ["id", "name", "scopes", "created_at"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "tenant_id": {"type": "string"},
+          "scopes": {"type": "array", "items": {"$ref": "#/components/schemas/Scope"}},
+          "created_at": {"type": "string", "format": "date-time"},
+          "rotated_at": {"type": "string", "format": "date-time"},
+          "revoked_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "IssuedKey": {
+        "type": "object",
+        "required": ["id", "name", "scopes", "created_at", "token"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "tenant_id": {"type": "string"},
+          "scopes": {"type": "array", "items": {"$ref": "#/components/schemas/Scope"}},
+          "created_at": {"type": "string", "format": "date-time"},
+          "rotated_at": {"type": "string", "format": "date-time"},
+          "revoked_at": {"type": "string", "format": "date-time"},
+          "token": {"type": "string"}
+        }
+      },
+      "KeyList": {
+        "type": "object",
+        "required": ["keys"],
+        "properties": {
+          "keys": {"type": "array", "items": {"$ref": "#/components/schemas/Key"}}
+        }
+      },
+      "Event": {
+        "type": "object",
+        "required": ["id", "type", "time", "tenant_id", "message_id"],
+        "properties": {
+          "id": {"type": "integer"},
+          "type": {"type": "string", "enum": ["message.queued", "message.sent", "message.delivered", "message.failed", "message.inbound"]},
+          "time": {"type": "string", "format": "date-time"},
+          "tenant_id": {"type": "string"},
+          "message_id": {"type": "string"},
+          "request_id": {"type": "string"},
+          "segments": {"type": "integer"},
+          "provider": {"type": "string"},
+          "provider_message_id": {"type": "string"},
+          "error_code": {"type": "string"},
+          "provider_code": {"type": "string"}
+        }
+      },
+      "HistoryMessage": {
+        "type": "object",
+        "required": ["seq", "id", "time", "tenant_id", "recipient", "text", "status", "segments"],
+        "properties": {
+          "seq": {"type": "integer"},
+          "id": {"type": "string", "description": "Message ID of the send result"},
+          "time": {"type": "string", "format": "date-time"},
+          "tenant_id": {"type": "string"},
+          "key_id": {"type": "string"},
+          "request_id": {"type": "string"},
+          "recipient": {"type": "integer"},
+          "text": {"type": "string", "description": "Text as sent, after links were shortened"},
+          "status": {"type": "string", "enum": ["sent", "failed"]},
+          "segments": {"type": "integer"},
+          "encoding": {"type": "string"},
+          "provider": {"type": "string"},
+          "provider_message_id": {"type": "string"},
+          "error_code": {"type": "string"},
+          "provider_code": {"type": "string"},
+          "anonymised": {"type": "boolean", "description": "Set once text and recipient were removed after the retention period"}
+        }
+      },
+      "HistoryPage": {
+        "type": "object",
+        "required": ["messages"],
+        "properties": {
+          "messages": {"type": "array", "items": {"$ref": "#/components/schemas/HistoryMessage"}},
+          "next_cursor": {"type": "string", "description": "cursor parameter of the next page, absent on the last page"}
+        }
+      },
+      "Erasure": {
+        "type": "object",
+        "required": ["recipient", "messages"],
+        "properties": {
+          "recipient": {"type": "integer"},
+          "messages": {"type": "integer", "description": "Number of messages erased from the history"}
+        }
+      },
+      "AuditEntry": {
+        "type": "object",
+        "required": ["seq", "time", "action", "prev_hash", "hash"],
+        "properties": {
+          "seq": {"type": "integer"},
+          "time": {"type": "string", "format": "date-time"},
+          "action": {"type": "string"},
+          "actor": {"type": "string"},
+          "tenant_id": {"type": "string"},
+          "request_id": {"type": "string"},
+          "target": {"type": "string"},
+          "details": {"type": "object", "additionalProperties": {"type": "string"}},
+          "prev_hash": {"type": "string"},
+          "hash": {"type": "string"}
+        }
+      },
+      "AuditPage": {
+        "type": "object",
+        "required": ["entries"],
+        "properties": {
+          "entries": {"type": "array", "items": {"$ref": "#/components/schemas/AuditEntry"}},
+          "next": {"type": "integer", "description": "after parameter of the next page, absent on the last page"}
+        }
+      },
+      "AuditVerification": {
+        "type": "object",
+        "required": ["valid", "entries"],
+        "properties": {
+          "valid": {"type": "boolean"},
+          "entries": {"type": "integer"},
+          "error": {"type": "string"}
+        }
+      },
+      "LogLevel": {
+        "type": "object",
+        "required": ["level"],
+        "properties": {
+          "level": {"type": "string", "minLength": 1, "description": "debug, info, warn, error, dpanic, panic or fatal"}
+        }
+      },
+      "Health": {
+        "type": "object",
+        "required": ["status"],
+        "properties": {
+          "status": {"type": "string", "enum": ["ok", "failed"]},
+          "checks": {"type": "array", "items": {"$ref": "#/components/schemas/Check"}},
+          "breakers": {"type": "array", "items": {"$ref": "#/components/schemas/BreakerState"}}
+        }
+      },
+      "Check": {
+        "type": "object",
+        "required": ["name", "status", "critical", "checked_at"],
+        "properties": {
+          "name": {"type": "string"},
+          "status": {"type": "string", "enum": ["ok", "failed"]},
+          "critical": {"type": "boolean"},
+          "error": {"type": "string"},
+          "checked_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "BreakerState": {
+        "type": "object",
+        "required": ["name", "state"],
+        "properties": {
+          "name": {"type": "string"},
+          "state": {"type": "string", "enum": ["closed", "open", "half-open"]}
+        }
+      },
+      "Status": {
+        "type": "object",
+        "required": ["version", "git_commit", "started_at", "uptime"],
+        "properties": {
+          "version": {"type": "string"},
+          "git_commit": {"type": "string"},
+          "started_at": {"type": "string", "format": "date-time"},
+          "uptime": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBackpressure returned when the next slot is further away than the pacer's max delay.
+var ErrBackpressure = errors.New("outbound rate limit reached, try again later")
+
+// Pacer spaces calls evenly so no more than Limit.Count happen per Limit.Period.
+// Callers block until their slot, callers whose slot is beyond maxDelay are rejected.
+type Pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	maxDelay time.Duration
+	next     time.Time
+	waiting  int
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// NewPacer creates a Pacer for the limit. An unlimited limit never blocks.
+func NewPacer(limit Limit, maxDelay time.Duration) *Pacer {
+	p := &Pacer{now: time.Now, sleep: sleep}
+	p.SetLimit(limit, maxDelay)
+	return p
+}
+
+// SetLimit replaces the limit, callers already waiting keep their slot.
+func (p *Pacer) SetLimit(limit Limit, maxDelay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxDelay = maxDelay
+	p.interval = 0
+	if !limit.Unlimited() {
+		p.interval = limit.Period / time.Duration(limit.Count)
+	}
+}
+
+// Wait blocks until the caller's slot or ctx is done. The slot is given back when ctx is done first.
+func (p *Pacer) Wait(ctx context.Context) error {
+	r, err := p.Reserve()
+	if err != nil {
+		return err
+	}
+	return r.Wait(ctx)
+}
+
+// Reserve takes the next slot without waiting for it. The caller must Wait for the reservation
+// or Cancel it.
+func (p *Pacer) Reserve() (*Reservation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.interval == 0 {
+		return &Reservation{}, nil
+	}
+	now := p.now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	if p.maxDelay > 0 && at.Sub(now) > p.maxDelay {
+		return nil, ErrBackpressure
+	}
+	r := &Reservation{p: p, at: at, prev: p.next, next: at.Add(p.interval)}
+	p.next = r.next
+	p.waiting++
+	return r, nil
+}
+
+// Reservation is a slot taken from a Pacer.
+type Reservation struct {
+	p         *Pacer
+	at        time.Time
+	prev      time.Time
+	next      time.Time
+	done      bool
+	cancelled bool
+}
+
+// Wait blocks until the slot or ctx is done. The slot is cancelled when ctx is done first.
+func (r *Reservation) Wait(ctx context.Context) error {
+	if r.p == nil {
+		return nil
+	}
+	if err := r.p.sleep(ctx, r.at.Sub(r.p.now())); err != nil {
+		r.Cancel()
+		return err
+	}
+	r.p.mu.Lock()
+	defer r.p.mu.Unlock()
+	r.finish()
+	return nil
+}
+
+// Cancel gives the slot back when no later slot was taken since, so the next caller gets it.
+// A slot followed by others is dropped, moving them forward would exceed the limit.
+// A slot already waited for can be cancelled when the call it was for is not made.
+func (r *Reservation) Cancel() {
+	if r.p == nil {
+		return
+	}
+	r.p.mu.Lock()
+	defer r.p.mu.Unlock()
+	if r.cancelled {
+		return
+	}
+	r.cancelled = true
+	r.finish()
+	if r.p.next.Equal(r.next) {
+		r.p.next = r.prev
+	}
+}
+
+// finish stops counting the reservation as waiting, p.mu must be held.
+func (r *Reservation) finish() {
+	if !r.done {
+		r.done = true
+		r.p.waiting--
+	}
+}
+
+// Waiting returns the number of callers waiting for their slot.
+func (p *Pacer) Waiting() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.waiting
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limit represent a token bucket holding Count tokens refilled over Period.
+// A zero Count means unlimited.
+type Limit struct {
+	Count  int
+	Period time.Duration
+}
+
+// Unlimited reports whether the limit is disabled.
+func (l Limit) Unlimited() bool {
+	return l.Count <= 0 || l.Period <= 0
+}
+
+// String formats the limit as count/period.
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "unlimited"
+	}
+	return strconv.Itoa(l.Count) + "/" + l.Period.String()
+}
+
+// ratePerSecond returns the refill rate in tokens per second.
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.Count) / l.Period.Seconds()
+}
+
+// ParseLimit parses limits such as "60/m", "5/1m30s" or "10/s".
+// An empty string or "0" means unlimited.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, errors.Errorf("invalid rate limit %q: expected count/period", s)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 0 {
+		return Limit{}, errors.Errorf("invalid rate limit %q: count must be a positive number", s)
+	}
+	var period time.Duration
+	switch parts[1] {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(parts[1])
+		if err != nil || period <= 0 {
+			return Limit{}, errors.Errorf("invalid rate limit %q: unknown period", s)
+		}
+	}
+	return Limit{Count: count, Period: period}, nil
+}
+
+// Result represent the outcome of taking tokens from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must take and refund tokens atomically.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, n int, now time.Time) (Result, error)
+	Refund(ctx context.Context, key string, limit Limit, n int, now time.Time) error
+}
+
+// Limiter consumes tokens from buckets kept in a store.
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+// New creates a new Limiter.
+func New(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Take consumes n tokens from the bucket identified by key.
+func (l *Limiter) Take(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true, Limit: limit}, nil
+	}
+	return l.store.Take(ctx, key, limit, n, l.now())
+}
+
+// Refund gives back n tokens taken from the bucket identified by key, when the request
+// they were taken for is rejected by another bucket.
+func (l *Limiter) Refund(ctx context.Context, key string, limit Limit, n int) error {
+	if limit.Unlimited() {
+		return nil
+	}
+	return l.store.Refund(ctx, key, limit, n, l.now())
+}
+
+// SetHeaders reports the bucket usage in response headers.
+func SetHeaders(h http.Header, r Result) {
+	if r.Limit.Unlimited() {
+		return
+	}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(r.Limit.Count))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(seconds(r.ResetAfter)))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.Itoa(seconds(r.RetryAfter)))
+	}
+}
+
+// result builds the Result from the tokens left in the bucket.
+func result(limit Limit, tokens float64, allowed bool, n int) Result {
+	rate := limit.ratePerSecond()
+	r := Result{
+		Allowed:    allowed,
+		Limit:      limit,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: duration((float64(limit.Count) - tokens) / rate),
+	}
+	if !allowed {
+		if n > limit.Count {
+			r.RetryAfter = limit.Period
+		} else {
+			r.RetryAfter = duration((float64(n) - tokens) / rate)
+		}
+	}
+	return r
+}
+
+func duration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// seconds rounds up so clients never retry too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,155 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	testTable := []struct {
+		Name  string
+		Input string
+		Want  ratelimit.Limit
+		Err   string
+	}{
+		{Name: "per minute", Input: "60/m", Want: ratelimit.Limit{Count: 60, Period: time.Minute}},
+		{Name: "per duration", Input: "5/1m30s", Want: ratelimit.Limit{Count: 5, Period: 90 * time.Second}},
+		{Name: "disabled", Input: "0", Want: ratelimit.Limit{}},
+		{Name: "missing period", Input: "60", Err: `invalid rate limit "60": expected count/period`},
+		{Name: "bad count", Input: "x/m", Err: `invalid rate limit "x/m": count must be a positive number`},
+		{Name: "bad period", Input: "1/fortnight", Err: `invalid rate limit "1/fortnight": unknown period`},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			limit, err := ratelimit.ParseLimit(tt.Input)
+			if tt.Err != "" {
+				assert.EqualError(t, err, tt.Err, "error message")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want, limit, "limit")
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	type step struct {
+		After      time.Duration
+		Refund     int
+		N          int
+		Allowed    bool
+		Remaining  int
+		RetryAfter time.Duration
+	}
+	testTable := []struct {
+		Name  string
+		Limit ratelimit.Limit
+		Steps []step
+	}{
+		{
+			Name:  "bucket drains and refills",
+			Limit: ratelimit.Limit{Count: 2, Period: time.Minute},
+			Steps: []step{
+				{N: 1, Allowed: true, Remaining: 1},
+				{N: 1, Allowed: true, Remaining: 0},
+				{N: 1, Allowed: false, Remaining: 0, RetryAfter: 30 * time.Second},
+				{After: 30 * time.Second, N: 1, Allowed: true, Remaining: 0},
+				{After: 10 * time.Minute, N: 1, Allowed: true, Remaining: 1},
+			},
+		},
+		{
+			Name:  "request larger than bucket",
+			Limit: ratelimit.Limit{Count: 2, Period: time.Minute},
+			Steps: []step{
+				{N: 3, Allowed: false, Remaining: 2, RetryAfter: time.Minute},
+			},
+		},
+		{
+			Name:  "refund returns tokens up to the limit",
+			Limit: ratelimit.Limit{Count: 2, Period: time.Minute},
+			Steps: []step{
+				{N: 2, Allowed: true, Remaining: 0},
+				{Refund: 1, N: 1, Allowed: true, Remaining: 0},
+				{Refund: 5, N: 1, Allowed: true, Remaining: 1},
+			},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			store := ratelimit.NewMemoryStore()
+			now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+			for i, s := range tt.Steps {
+				now = now.Add(s.After)
+				if s.Refund > 0 {
+					require.NoError(t, store.Refund(context.Background(), "key", tt.Limit, s.Refund, now), "step %d refund", i)
+				}
+				res, err := store.Take(context.Background(), "key", tt.Limit, s.N, now)
+				require.NoError(t, err, "step %d", i)
+				assert.Equal(t, s.Allowed, res.Allowed, "step %d allowed", i)
+				assert.Equal(t, s.Remaining, res.Remaining, "step %d remaining", i)
+				assert.Equal(t, s.RetryAfter, res.RetryAfter, "step %d retry after", i)
+			}
+		})
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	l := ratelimit.New(ratelimit.NewMemoryStore())
+	for i := 0; i < 10; i++ {
+		res, err := l.Take(context.Background(), "key", ratelimit.Limit{}, 1)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+}
+
+func TestPacer(t *testing.T) {
+	p := ratelimit.NewPacer(ratelimit.Limit{Count: 10, Period: time.Second}, 150*time.Millisecond)
+	start := time.Now()
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { errs <- p.Wait(context.Background()) }()
+	}
+	var rejected int
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			assert.Equal(t, ratelimit.ErrBackpressure, err, "error")
+			rejected++
+		}
+	}
+	assert.Equal(t, 1, rejected, "rejected")
+	assert.True(t, time.Since(start) >= 100*time.Millisecond, "paced")
+	assert.Equal(t, 0, p.Waiting(), "waiting")
+}
+
+func TestPacerCancelled(t *testing.T) {
+	p := ratelimit.NewPacer(ratelimit.Limit{Count: 1, Period: time.Hour}, 0)
+	require.NoError(t, p.Wait(context.Background()), "first slot")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.Wait(ctx), "cancelled")
+	assert.Equal(t, 0, p.Waiting(), "waiting")
+}
+
+func TestPacerCancelReturnsSlot(t *testing.T) {
+	p := ratelimit.NewPacer(ratelimit.Limit{Count: 10, Period: time.Second}, 150*time.Millisecond)
+	require.NoError(t, p.Wait(context.Background()), "first slot")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, p.Wait(ctx), "cancelled")
+	r, err := p.Reserve()
+	require.NoError(t, err, "cancelled slot reused")
+	_, err = p.Reserve()
+	assert.Equal(t, ratelimit.ErrBackpressure, err, "next slot too far")
+	assert.Equal(t, 1, p.Waiting(), "waiting")
+
+	r.Cancel()
+	r.Cancel()
+	assert.Equal(t, 0, p.Waiting(), "cancelled once")
+	require.NoError(t, p.Wait(context.Background()), "slot given back")
+}
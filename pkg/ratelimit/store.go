@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+// MemoryStore keeps token buckets in process memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// sweepInterval is how often full buckets are dropped from memory.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take consumes n tokens from the bucket.
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit, n int, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Count), last: now}
+		m.buckets[key] = b
+	}
+	b.period = limit.Period
+	b.tokens = refill(b.tokens, limit, now.Sub(b.last))
+	b.last = now
+
+	allowed := b.tokens >= float64(n)
+	if allowed {
+		b.tokens -= float64(n)
+	}
+	return result(limit, b.tokens, allowed, n), nil
+}
+
+// Refund gives back n tokens to the bucket, never filling it past its limit.
+func (m *MemoryStore) Refund(ctx context.Context, key string, limit Limit, n int, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		return nil
+	}
+	b.tokens = math.Min(float64(limit.Count), refill(b.tokens, limit, now.Sub(b.last))+float64(n))
+	if now.After(b.last) {
+		b.last = now
+	}
+	return nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.last) > b.period {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func refill(tokens float64, limit Limit, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return tokens
+	}
+	return math.Min(float64(limit.Count), tokens+elapsed.Seconds()*limit.ratePerSecond())
+}
+
+// takeScript implements the token bucket atomically in redis.
+// Tokens are returned as a string as redis truncates lua numbers to integers.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil then
+	tokens = capacity
+	last = now
+end
+if now > last then
+	tokens = math.min(capacity, tokens + (now - last) * capacity / period)
+	last = now
+end
+local allowed = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'last', last)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// refundScript gives back tokens to a bucket in redis. Expired buckets are full and left alone.
+var refundScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil then
+	return 0
+end
+if now > last then
+	tokens = tokens + (now - last) * capacity / period
+	last = now
+end
+tokens = math.min(capacity, tokens + n)
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'last', last)
+redis.call('PEXPIRE', KEYS[1], period)
+return 1
+`)
+
+// RedisStore keeps token buckets in redis so limits are shared between instances.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore prefixing bucket keys with prefix.
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take consumes n tokens from the bucket.
+func (r *RedisStore) Take(ctx context.Context, key string, limit Limit, n int, now time.Time) (Result, error) {
+	ms := func(d time.Duration) int64 { return int64(d / time.Millisecond) }
+	res, err := takeScript.Run(r.client.WithContext(ctx), []string{r.prefix + key},
+		limit.Count, ms(limit.Period), n, now.UnixNano()/int64(time.Millisecond)).Result()
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to take rate limit tokens")
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, errors.Errorf("unexpected rate limit script result %v", res)
+	}
+	allowed, _ := values[0].(int64)
+	raw, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to parse rate limit tokens")
+	}
+	return result(limit, tokens, allowed == 1, n), nil
+}
+
+// Refund gives back n tokens to the bucket.
+func (r *RedisStore) Refund(ctx context.Context, key string, limit Limit, n int, now time.Time) error {
+	ms := func(d time.Duration) int64 { return int64(d / time.Millisecond) }
+	err := refundScript.Run(r.client.WithContext(ctx), []string{r.prefix + key},
+		limit.Count, ms(limit.Period), n, now.UnixNano()/int64(time.Millisecond)).Err()
+	return errors.Wrap(err, "failed to refund rate limit tokens")
+}
+
+// Ping checks redis is reachable.
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.WithContext(ctx).Ping().Err()
+}
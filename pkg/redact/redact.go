@@ -0,0 +1,167 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+// Body policies applied to message texts.
+const (
+	// BodyHash replaces texts with a keyed digest so repeated texts can still be matched.
+	BodyHash = "hash"
+	// BodyDrop removes texts from log lines.
+	BodyDrop = "drop"
+	// BodyKeep logs texts unchanged.
+	BodyKeep = "keep"
+)
+
+// Policy represent how sensitive fields are redacted.
+type Policy struct {
+	// PhoneDigits is the number of trailing digits of phone numbers kept, all digits are masked when 0.
+	PhoneDigits int
+	// Body is one of BodyHash, BodyDrop or BodyKeep.
+	Body string
+	// StripQuery removes query strings and fragments from URLs.
+	StripQuery bool
+	// Key is the HMAC key of BodyHash digests, without it short texts such as codes could be
+	// recovered by hashing every candidate.
+	Key []byte
+}
+
+// Field keys holding phone numbers, message texts and URLs.
+var (
+	phoneKeys = map[string]bool{"phone_number": true, "to": true, "msisdn": true}
+	bodyKeys  = map[string]bool{"text": true, "message_text": true, "body": true}
+	urlKeys   = map[string]bool{"long-link": true, "url": true, "link": true}
+)
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s"'<>]+`)
+
+// Validate checks the policy is usable.
+func (p Policy) Validate() error {
+	switch p.Body {
+	case BodyHash, BodyDrop, BodyKeep:
+	default:
+		return errors.Errorf("unknown body policy %q", p.Body)
+	}
+	if p.PhoneDigits < 0 {
+		return errors.New("phone digits must not be negative")
+	}
+	return nil
+}
+
+// Core wraps core so fields are redacted before they are encoded.
+func Core(core zapcore.Core, p Policy) zapcore.Core {
+	return &redactCore{Core: core, policy: p}
+}
+
+type redactCore struct {
+	zapcore.Core
+	policy Policy
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.policy.fields(fields)), policy: c.policy}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.policy.fields(fields))
+}
+
+// fields returns a redacted copy of fields, dropped fields are left out.
+func (p Policy) fields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		if f, ok := p.Field(f); ok {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Field redacts a single field, reporting false when it must be dropped.
+func (p Policy) Field(f zapcore.Field) (zapcore.Field, bool) {
+	switch {
+	case phoneKeys[f.Key]:
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: p.Phone(fieldString(f))}, true
+	case bodyKeys[f.Key]:
+		if p.Body == BodyDrop {
+			return f, false
+		}
+		if p.Body == BodyKeep {
+			return f, true
+		}
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: p.Hash(fieldString(f))}, true
+	case urlKeys[f.Key] && p.StripQuery:
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: p.URLs(fieldString(f))}, true
+	case f.Type == zapcore.ErrorType && p.StripQuery:
+		if err, ok := f.Interface.(error); ok {
+			return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: p.URLs(err.Error())}, true
+		}
+	}
+	return f, true
+}
+
+// Phone masks all but the trailing digits of a phone number.
+func (p Policy) Phone(number string) string {
+	keep := p.PhoneDigits
+	if keep > len(number) {
+		keep = len(number)
+	}
+	return strings.Repeat("*", len(number)-keep) + number[len(number)-keep:]
+}
+
+// URLs strips query strings and fragments from the URLs found in s.
+func (p Policy) URLs(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, func(match string) string {
+		raw := strings.TrimRight(match, ".,:;)")
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "[invalid url]"
+		}
+		u.RawQuery = ""
+		u.Fragment = ""
+		u.User = nil
+		return u.String() + match[len(raw):]
+	})
+}
+
+// Hash returns a digest of s keyed with the policy key.
+func (p Policy) Hash(s string) string {
+	mac := hmac.New(sha256.New, p.Key)
+	mac.Write([]byte(s))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func fieldString(f zapcore.Field) string {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.Int64Type, zapcore.Int32Type:
+		return strconv.FormatInt(f.Integer, 10)
+	case zapcore.Uint64Type, zapcore.Uint32Type:
+		return strconv.FormatUint(uint64(f.Integer), 10)
+	case zapcore.StringerType:
+		return f.Interface.(fmt.Stringer).String()
+	}
+	if f.Interface != nil {
+		return fmt.Sprint(f.Interface)
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package redact_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/nikhil-github/sms-app/pkg/redact"
+)
+
+func TestCore(t *testing.T) {
+	type args struct {
+		Policy redact.Policy
+		Fields []zap.Field
+	}
+	type want struct {
+		Fields map[string]interface{}
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success - phone number masked to last digits",
+			Args: args{Policy: redact.Policy{PhoneDigits: 4, Body: redact.BodyKeep}, Fields: []zap.Field{zap.Int64("phone_number", 61412345678)}},
+			Want: want{Fields: map[string]interface{}{"phone_number": "*******5678"}},
+		},
+		{
+			Name: "Success - phone number fully masked",
+			Args: args{Policy: redact.Policy{Body: redact.BodyKeep}, Fields: []zap.Field{zap.String("to", "0412345678")}},
+			Want: want{Fields: map[string]interface{}{"to": "**********"}},
+		},
+		{
+			Name: "Success - text hashed",
+			Args: args{Policy: redact.Policy{Body: redact.BodyHash, Key: []byte("key")}, Fields: []zap.Field{zap.String("text", "your code is 1234")}},
+			Want: want{Fields: map[string]interface{}{"text": redact.Policy{Key: []byte("key")}.Hash("your code is 1234")}},
+		},
+		{
+			Name: "Success - text dropped",
+			Args: args{Policy: redact.Policy{Body: redact.BodyDrop}, Fields: []zap.Field{zap.String("text", "your code is 1234"), zap.String("tenant_id", "retail")}},
+			Want: want{Fields: map[string]interface{}{"tenant_id": "retail"}},
+		},
+		{
+			Name: "Success - query string stripped",
+			Args: args{Policy: redact.Policy{Body: redact.BodyKeep, StripQuery: true}, Fields: []zap.Field{zap.String("long-link", "https://example.com/reset?token=secret#top")}},
+			Want: want{Fields: map[string]interface{}{"long-link": "https://example.com/reset"}},
+		},
+		{
+			Name: "Success - query string stripped from errors",
+			Args: args{Policy: redact.Policy{Body: redact.BodyKeep, StripQuery: true}, Fields: []zap.Field{zap.Error(errors.New("failed to shorten https://example.com/a?token=secret: timeout"))}},
+			Want: want{Fields: map[string]interface{}{"error": "failed to shorten https://example.com/a: timeout"}},
+		},
+		{
+			Name: "Success - other fields unchanged",
+			Args: args{Policy: redact.Policy{Body: redact.BodyHash, StripQuery: true}, Fields: []zap.Field{zap.String("key_id", "key-1"), zap.Int("status code", 500)}},
+			Want: want{Fields: map[string]interface{}{"key_id": "key-1", "status code": int64(500)}},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(redact.Core(core, tt.Args.Policy))
+			logger.Info("Unable to send sms", tt.Args.Fields...)
+			entries := logs.All()
+			if assert.Len(t, entries, 1, "log lines") {
+				assert.Equal(t, tt.Want.Fields, entries[0].ContextMap(), "fields")
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	p := redact.Policy{Key: []byte("key")}
+	assert.Equal(t, p.Hash("your code is 1234"), p.Hash("your code is 1234"), "same text, same digest")
+	assert.NotEqual(t, p.Hash("your code is 1234"), p.Hash("your code is 1235"), "other text")
+	assert.NotEqual(t, p.Hash("your code is 1234"), redact.Policy{Key: []byte("other")}.Hash("your code is 1234"), "other key")
+	assert.Equal(t, "hmac-sha256:", p.Hash("")[:12], "prefix")
+	assert.Len(t, p.Hash(""), 12+32, "128 bits")
+}
+
+func TestCoreWith(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(redact.Core(core, redact.Policy{PhoneDigits: 3, Body: redact.BodyDrop}))
+	logger.With(zap.Int64("phone_number", 61400000123), zap.String("text", "hello")).Info("Sms sent")
+	assert.Equal(t, map[string]interface{}{"phone_number": "********123"}, logs.All()[0].ContextMap(), "fields")
+}
@@ -0,0 +1,224 @@
+package rpc
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/rpc/smsv1"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+// requestIDKey is the metadata carrying the ID correlating a call with its log lines.
+const requestIDKey = "x-request-id"
+
+// scopes lists the scopes allowed to call each SmsService method, any one of them is enough.
+// Methods not listed, such as health and reflection, are not authenticated.
+var scopes = map[string][]auth.Scope{
+	smsv1.SmsService_Send_FullMethodName:               {auth.ScopeSend},
+	smsv1.SmsService_BulkSend_FullMethodName:           {auth.ScopeSend},
+	smsv1.SmsService_GetMessageStatus_FullMethodName:   {auth.ScopeSend, auth.ScopeRead},
+	smsv1.SmsService_WatchMessageStatus_FullMethodName: {auth.ScopeSend, auth.ScopeRead},
+}
+
+// rateLimited lists the methods counting against the global and the API key's rate limits.
+var rateLimited = map[string]bool{
+	smsv1.SmsService_Send_FullMethodName:     true,
+	smsv1.SmsService_BulkSend_FullMethodName: true,
+}
+
+// Interceptor authenticates calls like the REST API's Authenticate, ResolveTenant and RateLimit.
+type Interceptor struct {
+	logger        *zap.Logger
+	authenticator handler.Authenticator
+	certs         handler.CertAuthenticator
+	tenants       handler.TenantFinder
+	limiter       handler.Limiter
+	limits        handler.LimitSource
+}
+
+// NewInterceptor creates the interceptor, certs may be nil when client certificates are not mapped to identities.
+func NewInterceptor(logger *zap.Logger, authenticator handler.Authenticator, certs handler.CertAuthenticator, tenants handler.TenantFinder, limiter handler.Limiter, limits handler.LimitSource) *Interceptor {
+	return &Interceptor{
+		logger:        logger,
+		authenticator: authenticator,
+		certs:         certs,
+		tenants:       tenants,
+		limiter:       limiter,
+		limits:        limits,
+	}
+}
+
+// Unary intercepts unary calls.
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, err := i.authorize(ctx, info.FullMethod)
+		var res interface{}
+		if err == nil {
+			res, err = next(ctx, req)
+		}
+		i.log(ctx, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// Stream intercepts streaming calls.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, err := i.authorize(ss.Context(), info.FullMethod)
+		if err == nil {
+			err = next(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		}
+		i.log(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// authorize tags the call with a request ID, then resolves the API key and tenant of SmsService calls.
+// The key is read from the authorization metadata as a bearer token or from x-api-key.
+// Without a token, a verified client certificate is mapped to its identity when certs is set.
+func (i *Interceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	id := first(metadata.ValueFromIncomingContext(ctx, requestIDKey))
+	if !handler.ValidRequestID(id) {
+		id = logging.NewID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	ctx = logging.WithRequestID(ctx, id)
+	ctx = logging.WithLogger(ctx, i.logger.With(zap.String("request_id", id), zap.String("grpc.method", method)))
+	logger := logging.From(ctx, i.logger)
+
+	allowed, ok := scopes[method]
+	if !ok {
+		return ctx, nil
+	}
+	token := apiToken(ctx)
+	cert := clientCert(ctx)
+	var key auth.Key
+	var err error
+	switch {
+	case token != "":
+		key, err = i.authenticator.Authenticate(ctx, token)
+	case cert != nil && i.certs != nil:
+		key, err = i.certs.AuthenticateCert(ctx, cert)
+		if err == auth.ErrUnauthenticated {
+			logger.Warn("Client certificate not mapped to an identity", zap.String("subject", cert.Subject.String()))
+			return ctx, errorStatus(codes.Unauthenticated, handler.CodeUnauthenticated, "client certificate not allowed", "")
+		}
+	default:
+		return ctx, errorStatus(codes.Unauthenticated, handler.CodeUnauthenticated, "api key missing", "")
+	}
+	if err == auth.ErrUnauthenticated {
+		return ctx, errorStatus(codes.Unauthenticated, handler.CodeUnauthenticated, err.Error(), "")
+	}
+	if err != nil {
+		logger.Error("Unable to authenticate api key", zap.Error(err))
+		return ctx, errorStatus(codes.Internal, handler.CodeInternal, "unable to authenticate", "")
+	}
+	if !hasScope(key, allowed) {
+		logger.Warn("Api key missing scope", zap.String("key_id", key.ID), zap.String("scope", string(allowed[0])))
+		return ctx, errorStatus(codes.PermissionDenied, handler.CodeForbidden, "api key not allowed to "+string(allowed[0]), "")
+	}
+	ctx = logging.With(auth.WithKey(ctx, key), logger, zap.String("key_id", key.ID))
+	logger = logging.From(ctx, i.logger)
+
+	tenantID := tenant.DefaultID
+	if key.TenantID != "" {
+		tenantID = key.TenantID
+	}
+	t, err := i.tenants.Get(ctx, tenantID)
+	if err == tenant.ErrNotFound {
+		logger.Warn("Api key not assigned to a tenant", zap.String("tenant_id", tenantID))
+		return ctx, errorStatus(codes.PermissionDenied, handler.CodeTenantNotAssigned, "api key not assigned to a tenant", "")
+	}
+	if err != nil {
+		logger.Error("Unable to find tenant", zap.String("tenant_id", tenantID), zap.Error(err))
+		return ctx, errorStatus(codes.Internal, handler.CodeInternal, "unable to find tenant", "")
+	}
+	ctx = logging.With(tenant.WithTenant(ctx, t), logger, zap.String("tenant_id", t.ID))
+
+	if rateLimited[method] {
+		if msg, ok := handler.AllowRequest(ctx, i.logger, i.limiter, i.limits); !ok {
+			return ctx, errorStatus(codes.ResourceExhausted, handler.CodeRateLimited, msg, "")
+		}
+	}
+	return ctx, nil
+}
+
+// log records the outcome of every call.
+func (i *Interceptor) log(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []zap.Field{zap.String("grpc.code", code.String()), zap.Duration("duration", time.Since(start))}
+	logger := logging.From(ctx, i.logger)
+	switch code {
+	case codes.OK:
+		logger.Info("gRPC call", fields...)
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		logger.Error("gRPC call failed", append(fields, zap.Error(err))...)
+	default:
+		logger.Warn("gRPC call failed", append(fields, zap.Error(err))...)
+	}
+}
+
+// serverStream replaces the context of a stream with the authorized one.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func hasScope(key auth.Key, allowed []auth.Scope) bool {
+	for _, scope := range allowed {
+		if key.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+func apiToken(ctx context.Context) string {
+	if h := first(metadata.ValueFromIncomingContext(ctx, "authorization")); h != "" {
+		parts := strings.SplitN(h, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return strings.TrimSpace(parts[1])
+		}
+		return ""
+	}
+	return strings.TrimSpace(first(metadata.ValueFromIncomingContext(ctx, "x-api-key")))
+}
+
+// clientCert returns the verified client certificate of the connection.
+func clientCert(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
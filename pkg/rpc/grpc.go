@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/nikhil-github/sms-app/pkg/rpc/smsv1"
+)
+
+// NewGRPCServer creates the gRPC server of the SmsService with the health and reflection services.
+// The health server reports serving until it is shut down.
+func NewGRPCServer(srv *Server, interceptor *Interceptor, opts ...grpc.ServerOption) (*grpc.Server, *health.Server) {
+	opts = append(opts, grpc.ChainUnaryInterceptor(interceptor.Unary()), grpc.ChainStreamInterceptor(interceptor.Stream()))
+	s := grpc.NewServer(opts...)
+	smsv1.RegisterSmsServiceServer(s, srv)
+
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus(smsv1.SmsService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthSrv)
+	reflection.Register(s)
+	return s, healthSrv
+}
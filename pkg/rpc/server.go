@@ -0,0 +1,349 @@
+// Package rpc serves the gRPC API, backed by the same sender and formatter as the REST API.
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/logging"
+	"github.com/nikhil-github/sms-app/pkg/rpc/smsv1"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+const (
+	// MaxBulkMessages is the most messages accepted by one BulkSend call.
+	MaxBulkMessages = 100
+	errorDomain     = "sms-app"
+)
+
+// Server implements the SmsService.
+type Server struct {
+	smsv1.UnimplementedSmsServiceServer
+
+	logger    *zap.Logger
+	sender    handler.Sender
+	formatter handler.Formatter
+	limiter   handler.Limiter
+	limits    handler.LimitSource
+	auditor   handler.Auditor
+	publisher handler.Publisher
+	recorder  handler.HistoryRecorder
+	tracker   *Tracker
+	bulk      sync.WaitGroup
+}
+
+// NewServer creates the SmsService, keeping the status of sent messages in tracker.
+// Message events are published to publisher and sent messages kept by recorder, both may be nil.
+func NewServer(logger *zap.Logger, sender handler.Sender, formatter handler.Formatter, limiter handler.Limiter, limits handler.LimitSource, auditor handler.Auditor, publisher handler.Publisher, recorder handler.HistoryRecorder, tracker *Tracker) *Server {
+	return &Server{
+		logger:    logger,
+		sender:    sender,
+		formatter: formatter,
+		limiter:   limiter,
+		limits:    limits,
+		auditor:   auditor,
+		publisher: publisher,
+		recorder:  recorder,
+		tracker:   tracker,
+	}
+}
+
+// Send sends texts to a phone number like POST /api/v2/sms/send.
+func (s *Server) Send(ctx context.Context, req *smsv1.SendRequest) (*smsv1.SendResponse, error) {
+	if err := validateSend(ctx, req); err != nil {
+		return nil, err
+	}
+	logger := logging.From(ctx, s.logger)
+	number, err := s.format(ctx, req.GetPhoneNumber(), "phone_number")
+	if err != nil {
+		return nil, err
+	}
+	if msg, ok := handler.AllowMessages(ctx, logger, s.limiter, s.limits, number, countTexts(req.GetTexts())); !ok {
+		return nil, errorStatus(codes.ResourceExhausted, handler.CodeRateLimited, msg, "")
+	}
+
+	ids := handler.QueueTexts(ctx, s.publisher, req.GetTexts())
+	res := &smsv1.SendResponse{Results: make([]*smsv1.MessageStatus, len(req.GetTexts()))}
+	for i, text := range req.GetTexts() {
+		if len(text) == 0 {
+			res.Results[i] = &smsv1.MessageStatus{Index: int32(i), Status: smsv1.Status_STATUS_SKIPPED}
+			continue
+		}
+		res.Results[i] = messageStatus(handler.SendText(ctx, logger, s.sender, s.auditor, s.publisher, s.recorder, number, i, ids[i], text))
+		s.tracker.Update(tenantID(ctx), res.Results[i])
+	}
+	return res, nil
+}
+
+// BulkSend queues the messages and sends them in the background, one at a time.
+// Messages failing number validation or the rate limits are failed without being sent.
+func (s *Server) BulkSend(ctx context.Context, req *smsv1.BulkSendRequest) (*smsv1.BulkSendResponse, error) {
+	if err := validateBulk(req); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(req.GetMessages()))
+	res := &smsv1.BulkSendResponse{Messages: make([]*smsv1.MessageStatus, len(ids))}
+	for i := range ids {
+		ids[i] = logging.NewID()
+		res.Messages[i] = &smsv1.MessageStatus{Index: int32(i), MessageId: ids[i], Status: smsv1.Status_STATUS_QUEUED}
+		s.tracker.Update(tenantID(ctx), res.Messages[i])
+		handler.Publish(ctx, s.publisher, events.TypeQueued, handler.TextResult{Index: i, MessageID: ids[i]})
+	}
+	logging.From(ctx, s.logger).Info("Bulk send queued", zap.Int("messages", len(ids)))
+
+	// The sends outlive the call, keeping its logger, API key and tenant.
+	bulkCtx := context.WithoutCancel(ctx)
+	s.bulk.Add(1)
+	go func() {
+		defer s.bulk.Done()
+		for i, m := range req.GetMessages() {
+			s.tracker.Update(tenantID(bulkCtx), s.sendBulk(bulkCtx, i, ids[i], m))
+		}
+	}()
+	return res, nil
+}
+
+// sendBulk sends one message of a bulk send.
+func (s *Server) sendBulk(ctx context.Context, i int, messageID string, m *smsv1.BulkMessage) *smsv1.MessageStatus {
+	logger := logging.From(ctx, s.logger).With(zap.String("message_id", messageID))
+	fail := func(code string, providerCode string) *smsv1.MessageStatus {
+		tr := handler.TextResult{Index: i, MessageID: messageID, Status: handler.StatusFailed, ErrorCode: code, ProviderCode: providerCode}
+		handler.Publish(ctx, s.publisher, events.TypeFailed, tr)
+		return messageStatus(tr)
+	}
+	number, ok, err := s.formatter.Format(ctx, m.GetPhoneNumber())
+	if err != nil {
+		logger.Warn("Unable to validate number", zap.Error(err))
+		return fail(handler.ErrorCode(err))
+	}
+	if !ok {
+		logger.Info("Invalid phone number")
+		return fail(handler.CodeInvalidPhoneNumber, "")
+	}
+	if _, ok := handler.AllowMessages(ctx, logger, s.limiter, s.limits, number, 1); !ok {
+		return fail(handler.CodeRateLimited, "")
+	}
+	return messageStatus(handler.SendText(ctx, logger, s.sender, s.auditor, s.publisher, s.recorder, number, i, messageID, m.GetText()))
+}
+
+// Drain waits for queued bulk sends to finish, or for ctx to be done.
+func (s *Server) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.bulk.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetMessageStatus returns the status of a message of the caller's tenant.
+func (s *Server) GetMessageStatus(ctx context.Context, req *smsv1.GetMessageStatusRequest) (*smsv1.MessageStatus, error) {
+	m, ok := s.tracker.Get(tenantID(ctx), req.GetMessageId())
+	if !ok {
+		return nil, errorStatus(codes.NotFound, handler.CodeNotFound, "message not found", "")
+	}
+	return m, nil
+}
+
+// WatchMessageStatus streams the status of the messages until each is final.
+func (s *Server) WatchMessageStatus(req *smsv1.WatchMessageStatusRequest, stream grpc.ServerStreamingServer[smsv1.MessageStatus]) error {
+	ids := unique(req.GetMessageIds())
+	if len(ids) == 0 {
+		return invalidArgument(handler.CodeInvalidRequest, "message_ids", "message_ids must not be empty")
+	}
+	ctx := stream.Context()
+	current, updates, stop, ok := s.tracker.Watch(tenantID(ctx), ids)
+	if !ok {
+		return errorStatus(codes.NotFound, handler.CodeNotFound, "message not found", "")
+	}
+	defer stop()
+
+	pending := map[string]bool{}
+	for _, m := range current {
+		if err := stream.Send(m); err != nil {
+			return err
+		}
+		if !Final(m) {
+			pending[m.GetMessageId()] = true
+		}
+	}
+	for len(pending) > 0 {
+		select {
+		case m := <-updates:
+			if !pending[m.GetMessageId()] {
+				continue
+			}
+			if err := stream.Send(m); err != nil {
+				return err
+			}
+			if Final(m) {
+				delete(pending, m.GetMessageId())
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return nil
+}
+
+// format validates the phone number with the provider, mapping its errors like the REST API.
+func (s *Server) format(ctx context.Context, phoneNumber string, field string) (int64, error) {
+	logger := logging.From(ctx, s.logger)
+	number, ok, err := s.formatter.Format(ctx, phoneNumber)
+	if err != nil {
+		return 0, formatError(logger, err, field)
+	}
+	if !ok {
+		logger.Info("Invalid phone number")
+		return 0, invalidArgument(handler.CodeInvalidPhoneNumber, field, "invalid phone number")
+	}
+	return number, nil
+}
+
+// formatError answers InvalidArgument when the provider refused the number, Unavailable while it
+// is unavailable or throttling us, FailedPrecondition when out of credit and Internal otherwise.
+func formatError(logger *zap.Logger, err error, field string) error {
+	code, providerCode := handler.ErrorCode(err)
+	switch code {
+	case handler.CodeInvalidPhoneNumber:
+		logger.Info("Sms provider refused phone number", zap.Error(err))
+		return invalidArgument(code, field, "invalid phone number")
+	case handler.CodeProviderUnavailable, handler.CodeProviderRateLimited:
+		logger.Warn("Sms provider unavailable", zap.Error(err))
+		return errorStatus(codes.Unavailable, code, "sms provider unavailable", providerCode)
+	case handler.CodeProviderBalance:
+		logger.Error("Sms provider account out of credit", zap.Error(err))
+		return errorStatus(codes.FailedPrecondition, code, "sms provider account out of credit", providerCode)
+	case handler.CodeProviderAuth:
+		logger.Error("Sms provider refused our credentials", zap.Error(err))
+		return errorStatus(codes.Internal, code, "sms provider refused our credentials", providerCode)
+	case handler.CodeProviderRejected:
+		logger.Error("Sms provider rejected number validation", zap.Error(err))
+		return errorStatus(codes.Internal, code, "sms provider rejected number validation", providerCode)
+	default:
+		logger.Error("Unable to validate number", zap.Error(err))
+		return errorStatus(codes.Internal, handler.CodeInternal, "unable to validate number", "")
+	}
+}
+
+func validateSend(ctx context.Context, req *smsv1.SendRequest) error {
+	m := handler.Message{PhoneNumber: req.GetPhoneNumber(), Texts: req.GetTexts()}
+	if errs := handler.ValidateMessage(m, handler.MaxTexts(ctx)); len(errs) > 0 {
+		return invalidArgument(errs[0].Code, errs[0].Field, errs[0].Detail)
+	}
+	return nil
+}
+
+func validateBulk(req *smsv1.BulkSendRequest) error {
+	if len(req.GetMessages()) == 0 {
+		return invalidArgument(handler.CodeInvalidRequest, "messages", "messages must not be empty")
+	}
+	if len(req.GetMessages()) > MaxBulkMessages {
+		return invalidArgument(handler.CodeInvalidRequest, "messages", fmt.Sprintf("max allowed message count is %d", MaxBulkMessages))
+	}
+	for i, m := range req.GetMessages() {
+		field := fmt.Sprintf("messages[%d]", i)
+		// Each bulk message has one text, which unlike the texts of a send must not be empty.
+		errs := handler.ValidateMessage(handler.Message{PhoneNumber: m.GetPhoneNumber(), Texts: []string{m.GetText()}}, 1)
+		if m.GetText() == "" {
+			errs = append(errs, handler.FieldError{Field: "texts[0]", Code: handler.CodeMissingTexts, Detail: "texts[0] must not be empty"})
+		}
+		if len(errs) > 0 {
+			name := field + "." + strings.Replace(errs[0].Field, "texts[0]", "text", 1)
+			return invalidArgument(errs[0].Code, name, field+"."+strings.Replace(errs[0].Detail, "texts[0]", "text", 1))
+		}
+	}
+	return nil
+}
+
+// messageStatus converts the result of a sent text.
+func messageStatus(tr handler.TextResult) *smsv1.MessageStatus {
+	m := &smsv1.MessageStatus{
+		Index:             int32(tr.Index),
+		MessageId:         tr.MessageID,
+		Status:            smsv1.Status_STATUS_SENT,
+		Segments:          int32(tr.Segments),
+		Encoding:          tr.Encoding,
+		Provider:          tr.Provider,
+		ProviderMessageId: tr.ProviderMessageID,
+		ErrorCode:         tr.ErrorCode,
+		ProviderCode:      tr.ProviderCode,
+	}
+	if tr.Status == handler.StatusFailed {
+		m.Status = smsv1.Status_STATUS_FAILED
+	}
+	for _, l := range tr.Links {
+		m.Links = append(m.Links, &smsv1.Link{Url: l.URL, Short: l.Short})
+	}
+	return m
+}
+
+// errorStatus carries the problem code of the REST API as the reason of an ErrorInfo detail.
+func errorStatus(c codes.Code, code string, message string, providerCode string) error {
+	info := &errdetails.ErrorInfo{Reason: code, Domain: errorDomain}
+	if providerCode != "" {
+		info.Metadata = map[string]string{"provider_code": providerCode}
+	}
+	st, err := status.New(c, message).WithDetails(info)
+	if err != nil {
+		return status.Error(c, message)
+	}
+	return st.Err()
+}
+
+// invalidArgument reports the field with a BadRequest detail next to the ErrorInfo.
+func invalidArgument(code string, field string, message string) error {
+	st, err := status.New(codes.InvalidArgument, message).WithDetails(
+		&errdetails.ErrorInfo{Reason: code, Domain: errorDomain},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: message}}},
+	)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, message)
+	}
+	return st.Err()
+}
+
+func countTexts(texts []string) int {
+	n := 0
+	for _, t := range texts {
+		if len(t) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func unique(ids []string) []string {
+	seen := map[string]bool{}
+	var list []string
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+func tenantID(ctx context.Context) string {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return t.ID
+}
@@ -0,0 +1,303 @@
+package rpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/rpc"
+	"github.com/nikhil-github/sms-app/pkg/rpc/smsv1"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+func TestSend(t *testing.T) {
+	type args struct {
+		Request *smsv1.SendRequest
+		Key     string
+	}
+	type fields struct {
+		MockExpectations func(m *mockFormatter, s *mockSender)
+	}
+	type want struct {
+		Code     codes.Code
+		Reason   string
+		Response *smsv1.SendResponse
+	}
+	testTable := []struct {
+		Name   string
+		Args   args
+		Fields fields
+		Want   want
+	}{
+		{
+			Name: "Success - texts sent in order, empty texts skipped",
+			Args: args{Request: &smsv1.SendRequest{PhoneNumber: "0400000000", Texts: []string{"see http://www.google.com", "", "fails"}}, Key: "send"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+				s.OnSend(int64(61400000000), "see http://www.google.com").Return(service.Receipt{
+					Provider:  service.Provider,
+					MessageID: "2937421",
+					Text:      "see http://bit.ly/xyz",
+					Links:     []service.Link{{URL: "http://www.google.com", Short: "http://bit.ly/xyz"}},
+				}, nil)
+				s.OnSend(int64(61400000000), "fails").Return(service.Receipt{Provider: service.Provider}, &service.ProviderError{StatusCode: 400, Code: "RECIPIENTS_ERROR"})
+			}},
+			Want: want{Code: codes.OK, Response: &smsv1.SendResponse{Results: []*smsv1.MessageStatus{
+				{Index: 0, Status: smsv1.Status_STATUS_SENT, Segments: 1, Encoding: "gsm7", Provider: service.Provider, ProviderMessageId: "2937421", Links: []*smsv1.Link{{Url: "http://www.google.com", Short: "http://bit.ly/xyz"}}},
+				{Index: 1, Status: smsv1.Status_STATUS_SKIPPED},
+				{Index: 2, Status: smsv1.Status_STATUS_FAILED, Segments: 1, Encoding: "gsm7", Provider: service.Provider, ErrorCode: handler.CodeInvalidPhoneNumber, ProviderCode: "RECIPIENTS_ERROR"},
+			}}},
+		},
+		{
+			Name:   "Failure - missing api key",
+			Args:   args{Request: &smsv1.SendRequest{PhoneNumber: "0400000000", Texts: []string{"text"}}},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Code: codes.Unauthenticated, Reason: handler.CodeUnauthenticated},
+		},
+		{
+			Name:   "Failure - api key without send scope",
+			Args:   args{Request: &smsv1.SendRequest{PhoneNumber: "0400000000", Texts: []string{"text"}}, Key: "read"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Code: codes.PermissionDenied, Reason: handler.CodeForbidden},
+		},
+		{
+			Name:   "Failure - missing phone number",
+			Args:   args{Request: &smsv1.SendRequest{Texts: []string{"text"}}, Key: "send"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Code: codes.InvalidArgument, Reason: handler.CodeMissingPhoneNumber},
+		},
+		{
+			Name:   "Failure - text too long in bytes",
+			Args:   args{Request: &smsv1.SendRequest{PhoneNumber: "0400000000", Texts: []string{strings.Repeat("é", 81)}}, Key: "send"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Code: codes.InvalidArgument, Reason: handler.CodeTextTooLong},
+		},
+		{
+			Name:   "Failure - texts count greater than tenant limit",
+			Args:   args{Request: &smsv1.SendRequest{PhoneNumber: "0400000000", Texts: []string{"text1", "text2"}}, Key: "small"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {}},
+			Want:   want{Code: codes.InvalidArgument, Reason: handler.CodeTooManyTexts},
+		},
+		{
+			Name: "Failure - invalid phone number",
+			Args: args{Request: &smsv1.SendRequest{PhoneNumber: "123", Texts: []string{"text"}}, Key: "send"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("123").Return(int64(0), false, nil)
+			}},
+			Want: want{Code: codes.InvalidArgument, Reason: handler.CodeInvalidPhoneNumber},
+		},
+		{
+			Name: "Failure - provider throttling",
+			Args: args{Request: &smsv1.SendRequest{PhoneNumber: "0400000000", Texts: []string{"text"}}, Key: "send"},
+			Fields: fields{MockExpectations: func(m *mockFormatter, s *mockSender) {
+				m.OnFormat("0400000000").Return(int64(0), false, &service.ProviderError{StatusCode: 429, Code: "OVER_LIMIT"})
+			}},
+			Want: want{Code: codes.Unavailable, Reason: handler.CodeProviderRateLimited},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var m mockFormatter
+			var s mockSender
+			tt.Fields.MockExpectations(&m, &s)
+			env := newEnv(t, &m, &s)
+
+			res, err := env.client.Send(env.as(tt.Args.Key), tt.Args.Request)
+			assert.Equal(t, tt.Want.Code, status.Code(err), "code: %v", err)
+			if tt.Want.Code != codes.OK {
+				assert.Equal(t, tt.Want.Reason, reason(err), "reason")
+				return
+			}
+			for _, r := range res.GetResults() {
+				if r.GetStatus() != smsv1.Status_STATUS_SKIPPED {
+					assert.NotEmpty(t, r.GetMessageId(), "message id of text %d", r.GetIndex())
+				}
+				r.MessageId = ""
+			}
+			assert.True(t, proto.Equal(tt.Want.Response, res), "response: %v", res)
+			m.AssertExpectations(t)
+			s.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBulkSend(t *testing.T) {
+	var m mockFormatter
+	var s mockSender
+	m.OnFormat("0400000000").Return(int64(61400000000), true, nil)
+	m.OnFormat("123").Return(int64(0), false, nil)
+	s.OnSend(int64(61400000000), "hello").Return(service.Receipt{Provider: service.Provider, MessageID: "1", Text: "hello"}, nil)
+	env := newEnv(t, &m, &s)
+
+	res, err := env.client.BulkSend(env.as("send"), &smsv1.BulkSendRequest{Messages: []*smsv1.BulkMessage{
+		{PhoneNumber: "0400000000", Text: "hello"},
+		{PhoneNumber: "123", Text: "hello"},
+	}})
+	require.NoError(t, err, "bulk send")
+	require.Len(t, res.GetMessages(), 2, "queued messages")
+	ids := []string{res.GetMessages()[0].GetMessageId(), res.GetMessages()[1].GetMessageId()}
+	for _, q := range res.GetMessages() {
+		assert.Equal(t, smsv1.Status_STATUS_QUEUED, q.GetStatus(), "queued status")
+	}
+
+	stream, err := env.client.WatchMessageStatus(env.as("read"), &smsv1.WatchMessageStatusRequest{MessageIds: ids})
+	require.NoError(t, err, "watch")
+	final := map[string]*smsv1.MessageStatus{}
+	for {
+		st, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "receive")
+		if rpc.Final(st) {
+			final[st.GetMessageId()] = st
+		}
+	}
+	require.Len(t, final, 2, "every message ends in a final status")
+	assert.Equal(t, smsv1.Status_STATUS_SENT, final[ids[0]].GetStatus(), "valid number sent")
+	assert.Equal(t, smsv1.Status_STATUS_FAILED, final[ids[1]].GetStatus(), "invalid number failed")
+	assert.Equal(t, handler.CodeInvalidPhoneNumber, final[ids[1]].GetErrorCode(), "invalid number error code")
+
+	got, err := env.client.GetMessageStatus(env.as("read"), &smsv1.GetMessageStatusRequest{MessageId: ids[0]})
+	require.NoError(t, err, "get status")
+	assert.Equal(t, smsv1.Status_STATUS_SENT, got.GetStatus(), "status")
+
+	_, err = env.client.GetMessageStatus(env.as("other"), &smsv1.GetMessageStatusRequest{MessageId: ids[0]})
+	assert.Equal(t, codes.NotFound, status.Code(err), "message of another tenant")
+	other, err := env.client.WatchMessageStatus(env.as("other"), &smsv1.WatchMessageStatusRequest{MessageIds: ids})
+	require.NoError(t, err, "watch of another tenant")
+	_, err = other.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err), "watch of another tenant")
+
+	_, err = env.client.BulkSend(env.as("send"), &smsv1.BulkSendRequest{Messages: []*smsv1.BulkMessage{{PhoneNumber: "0400000000"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "message without text")
+	_, err = env.client.BulkSend(env.as("send"), &smsv1.BulkSendRequest{Messages: []*smsv1.BulkMessage{{PhoneNumber: "0400000000", Text: strings.Repeat("é", 81)}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "text too long in bytes")
+	assert.Equal(t, "messages[0].text must be at most 160 bytes", status.Convert(err).Message(), "message")
+	m.AssertExpectations(t)
+	s.AssertExpectations(t)
+}
+
+func TestHealth(t *testing.T) {
+	env := newEnv(t, &mockFormatter{}, &mockSender{})
+	res, err := healthpb.NewHealthClient(env.conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "sms.v1.SmsService"})
+	require.NoError(t, err, "health check without api key")
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus(), "status")
+}
+
+type env struct {
+	conn   *grpc.ClientConn
+	client smsv1.SmsServiceClient
+	tokens map[string]string
+}
+
+// as returns a context calling with the token of the named key, without a token when name is empty.
+func (e *env) as(name string) context.Context {
+	ctx := context.Background()
+	if name == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+e.tokens[name])
+}
+
+func newEnv(t *testing.T, m *mockFormatter, s *mockSender) *env {
+	keys := auth.NewKeys(auth.NewMemoryStore())
+	tokens := map[string]string{}
+	for _, k := range []struct {
+		Name   string
+		Tenant string
+		Scope  auth.Scope
+	}{
+		{Name: "send", Scope: auth.ScopeSend},
+		{Name: "read", Scope: auth.ScopeRead},
+		{Name: "small", Tenant: "small", Scope: auth.ScopeSend},
+		{Name: "other", Tenant: "other", Scope: auth.ScopeRead},
+	} {
+		_, token, err := keys.Issue(context.Background(), k.Name, k.Tenant, []auth.Scope{k.Scope})
+		require.NoError(t, err, "issue key")
+		tokens[k.Name] = token
+	}
+	tenants := tenant.NewStore([]tenant.Tenant{
+		{ID: tenant.DefaultID},
+		{ID: "small", Limits: tenant.Limits{MaxTextsPerRequest: 1}},
+		{ID: "other"},
+	})
+	limiter := ratelimit.New(ratelimit.NewMemoryStore())
+	limits := handler.RateLimits{}
+
+	srv := rpc.NewServer(zap.NewNop(), s, m, limiter, limits, audit.NewLog(audit.NewMemoryStore(), []byte("audit-key")), nil, nil, rpc.NewTracker(100))
+	grpcServer, _ := rpc.NewGRPCServer(srv, rpc.NewInterceptor(zap.NewNop(), keys, nil, tenants, limiter, limits))
+	lis := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "dial")
+	t.Cleanup(func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Drain(ctx)
+		grpcServer.Stop()
+	})
+	return &env{conn: conn, client: smsv1.NewSmsServiceClient(conn), tokens: tokens}
+}
+
+// reason returns the problem code carried by the error.
+func reason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+type mockFormatter struct {
+	mock.Mock
+}
+
+func (m *mockFormatter) Format(ctx context.Context, phoneNumber string) (int64, bool, error) {
+	args := m.Called(ctx, phoneNumber)
+	return args.Get(0).(int64), args.Get(1).(bool), args.Error(2)
+}
+
+func (m *mockFormatter) OnFormat(phoneNumber string) *mock.Call {
+	return m.On("Format", mock.Anything, phoneNumber)
+}
+
+type mockSender struct {
+	mock.Mock
+}
+
+func (m *mockSender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
+	args := m.Called(ctx, phoneNumber, text)
+	return args.Get(0).(service.Receipt), args.Error(1)
+}
+
+func (m *mockSender) OnSend(phoneNumber int64, text string) *mock.Call {
+	return m.On("Send", mock.Anything, phoneNumber, text)
+}
@@ -0,0 +1,702 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: sms/v1/sms.proto
+
+package smsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Status int32
+
+const (
+	Status_STATUS_UNSPECIFIED Status = 0
+	Status_STATUS_QUEUED      Status = 1
+	Status_STATUS_SENT        Status = 2
+	Status_STATUS_FAILED      Status = 3
+	Status_STATUS_SKIPPED     Status = 4
+)
+
+// Enum value maps for Status.
+var (
+	Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "STATUS_QUEUED",
+		2: "STATUS_SENT",
+		3: "STATUS_FAILED",
+		4: "STATUS_SKIPPED",
+	}
+	Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED": 0,
+		"STATUS_QUEUED":      1,
+		"STATUS_SENT":        2,
+		"STATUS_FAILED":      3,
+		"STATUS_SKIPPED":     4,
+	}
+)
+
+func (x Status) Enum() *Status {
+	p := new(Status)
+	*p = x
+	return p
+}
+
+func (x Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_sms_v1_sms_proto_enumTypes[0].Descriptor()
+}
+
+func (Status) Type() protoreflect.EnumType {
+	return &file_sms_v1_sms_proto_enumTypes[0]
+}
+
+func (x Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Status.Descriptor instead.
+func (Status) EnumDescriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{0}
+}
+
+type SendRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Phone number in national or international format.
+	PhoneNumber string `protobuf:"bytes,1,opt,name=phone_number,json=phoneNumber,proto3" json:"phone_number,omitempty"`
+	// Texts sent as separate messages, empty texts are skipped.
+	Texts         []string `protobuf:"bytes,2,rep,name=texts,proto3" json:"texts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRequest) Reset() {
+	*x = SendRequest{}
+	mi := &file_sms_v1_sms_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRequest) ProtoMessage() {}
+
+func (x *SendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRequest.ProtoReflect.Descriptor instead.
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SendRequest) GetPhoneNumber() string {
+	if x != nil {
+		return x.PhoneNumber
+	}
+	return ""
+}
+
+func (x *SendRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+type SendResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One result per input text, in the order of texts.
+	Results       []*MessageStatus `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendResponse) Reset() {
+	*x = SendResponse{}
+	mi := &file_sms_v1_sms_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendResponse) ProtoMessage() {}
+
+func (x *SendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendResponse.ProtoReflect.Descriptor instead.
+func (*SendResponse) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SendResponse) GetResults() []*MessageStatus {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BulkSendRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*BulkMessage         `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkSendRequest) Reset() {
+	*x = BulkSendRequest{}
+	mi := &file_sms_v1_sms_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkSendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkSendRequest) ProtoMessage() {}
+
+func (x *BulkSendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkSendRequest.ProtoReflect.Descriptor instead.
+func (*BulkSendRequest) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BulkSendRequest) GetMessages() []*BulkMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type BulkMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PhoneNumber   string                 `protobuf:"bytes,1,opt,name=phone_number,json=phoneNumber,proto3" json:"phone_number,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkMessage) Reset() {
+	*x = BulkMessage{}
+	mi := &file_sms_v1_sms_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkMessage) ProtoMessage() {}
+
+func (x *BulkMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkMessage.ProtoReflect.Descriptor instead.
+func (*BulkMessage) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BulkMessage) GetPhoneNumber() string {
+	if x != nil {
+		return x.PhoneNumber
+	}
+	return ""
+}
+
+func (x *BulkMessage) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type BulkSendResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One queued message per input message, in the order of messages.
+	Messages      []*MessageStatus `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkSendResponse) Reset() {
+	*x = BulkSendResponse{}
+	mi := &file_sms_v1_sms_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkSendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkSendResponse) ProtoMessage() {}
+
+func (x *BulkSendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkSendResponse.ProtoReflect.Descriptor instead.
+func (*BulkSendResponse) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BulkSendResponse) GetMessages() []*MessageStatus {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type GetMessageStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMessageStatusRequest) Reset() {
+	*x = GetMessageStatusRequest{}
+	mi := &file_sms_v1_sms_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMessageStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMessageStatusRequest) ProtoMessage() {}
+
+func (x *GetMessageStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMessageStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetMessageStatusRequest) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetMessageStatusRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+type WatchMessageStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageIds    []string               `protobuf:"bytes,1,rep,name=message_ids,json=messageIds,proto3" json:"message_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchMessageStatusRequest) Reset() {
+	*x = WatchMessageStatusRequest{}
+	mi := &file_sms_v1_sms_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMessageStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMessageStatusRequest) ProtoMessage() {}
+
+func (x *WatchMessageStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchMessageStatusRequest.ProtoReflect.Descriptor instead.
+func (*WatchMessageStatusRequest) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchMessageStatusRequest) GetMessageIds() []string {
+	if x != nil {
+		return x.MessageIds
+	}
+	return nil
+}
+
+type MessageStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Position of the text or message in the request.
+	Index int32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// Our ID of the message, in logs and the audit log. Empty for skipped texts.
+	MessageId string `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Status    Status `protobuf:"varint,3,opt,name=status,proto3,enum=sms.v1.Status" json:"status,omitempty"`
+	// Segments and encoding of the text as sent, after links were shortened.
+	Segments          int32   `protobuf:"varint,4,opt,name=segments,proto3" json:"segments,omitempty"`
+	Encoding          string  `protobuf:"bytes,5,opt,name=encoding,proto3" json:"encoding,omitempty"`
+	Provider          string  `protobuf:"bytes,6,opt,name=provider,proto3" json:"provider,omitempty"`
+	ProviderMessageId string  `protobuf:"bytes,7,opt,name=provider_message_id,json=providerMessageId,proto3" json:"provider_message_id,omitempty"`
+	Links             []*Link `protobuf:"bytes,8,rep,name=links,proto3" json:"links,omitempty"`
+	// Error code of a failed message, the codes of the REST API.
+	ErrorCode     string `protobuf:"bytes,9,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	ProviderCode  string `protobuf:"bytes,10,opt,name=provider_code,json=providerCode,proto3" json:"provider_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageStatus) Reset() {
+	*x = MessageStatus{}
+	mi := &file_sms_v1_sms_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageStatus) ProtoMessage() {}
+
+func (x *MessageStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageStatus.ProtoReflect.Descriptor instead.
+func (*MessageStatus) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MessageStatus) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *MessageStatus) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageStatus) GetStatus() Status {
+	if x != nil {
+		return x.Status
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *MessageStatus) GetSegments() int32 {
+	if x != nil {
+		return x.Segments
+	}
+	return 0
+}
+
+func (x *MessageStatus) GetEncoding() string {
+	if x != nil {
+		return x.Encoding
+	}
+	return ""
+}
+
+func (x *MessageStatus) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *MessageStatus) GetProviderMessageId() string {
+	if x != nil {
+		return x.ProviderMessageId
+	}
+	return ""
+}
+
+func (x *MessageStatus) GetLinks() []*Link {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *MessageStatus) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *MessageStatus) GetProviderCode() string {
+	if x != nil {
+		return x.ProviderCode
+	}
+	return ""
+}
+
+type Link struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Short         string                 `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Link) Reset() {
+	*x = Link{}
+	mi := &file_sms_v1_sms_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Link) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Link) ProtoMessage() {}
+
+func (x *Link) ProtoReflect() protoreflect.Message {
+	mi := &file_sms_v1_sms_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Link.ProtoReflect.Descriptor instead.
+func (*Link) Descriptor() ([]byte, []int) {
+	return file_sms_v1_sms_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Link) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Link) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+var File_sms_v1_sms_proto protoreflect.FileDescriptor
+
+const file_sms_v1_sms_proto_rawDesc = "" +
+	"\n" +
+	"\x10sms/v1/sms.proto\x12\x06sms.v1\"F\n" +
+	"\vSendRequest\x12!\n" +
+	"\fphone_number\x18\x01 \x01(\tR\vphoneNumber\x12\x14\n" +
+	"\x05texts\x18\x02 \x03(\tR\x05texts\"?\n" +
+	"\fSendResponse\x12/\n" +
+	"\aresults\x18\x01 \x03(\v2\x15.sms.v1.MessageStatusR\aresults\"B\n" +
+	"\x0fBulkSendRequest\x12/\n" +
+	"\bmessages\x18\x01 \x03(\v2\x13.sms.v1.BulkMessageR\bmessages\"D\n" +
+	"\vBulkMessage\x12!\n" +
+	"\fphone_number\x18\x01 \x01(\tR\vphoneNumber\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"E\n" +
+	"\x10BulkSendResponse\x121\n" +
+	"\bmessages\x18\x01 \x03(\v2\x15.sms.v1.MessageStatusR\bmessages\"8\n" +
+	"\x17GetMessageStatusRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\"<\n" +
+	"\x19WatchMessageStatusRequest\x12\x1f\n" +
+	"\vmessage_ids\x18\x01 \x03(\tR\n" +
+	"messageIds\"\xd8\x02\n" +
+	"\rMessageStatus\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\x12&\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x0e.sms.v1.StatusR\x06status\x12\x1a\n" +
+	"\bsegments\x18\x04 \x01(\x05R\bsegments\x12\x1a\n" +
+	"\bencoding\x18\x05 \x01(\tR\bencoding\x12\x1a\n" +
+	"\bprovider\x18\x06 \x01(\tR\bprovider\x12.\n" +
+	"\x13provider_message_id\x18\a \x01(\tR\x11providerMessageId\x12\"\n" +
+	"\x05links\x18\b \x03(\v2\f.sms.v1.LinkR\x05links\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\t \x01(\tR\terrorCode\x12#\n" +
+	"\rprovider_code\x18\n" +
+	" \x01(\tR\fproviderCode\".\n" +
+	"\x04Link\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05short\x18\x02 \x01(\tR\x05short*k\n" +
+	"\x06Status\x12\x16\n" +
+	"\x12STATUS_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rSTATUS_QUEUED\x10\x01\x12\x0f\n" +
+	"\vSTATUS_SENT\x10\x02\x12\x11\n" +
+	"\rSTATUS_FAILED\x10\x03\x12\x12\n" +
+	"\x0eSTATUS_SKIPPED\x10\x042\x9c\x02\n" +
+	"\n" +
+	"SmsService\x121\n" +
+	"\x04Send\x12\x13.sms.v1.SendRequest\x1a\x14.sms.v1.SendResponse\x12=\n" +
+	"\bBulkSend\x12\x17.sms.v1.BulkSendRequest\x1a\x18.sms.v1.BulkSendResponse\x12J\n" +
+	"\x10GetMessageStatus\x12\x1f.sms.v1.GetMessageStatusRequest\x1a\x15.sms.v1.MessageStatus\x12P\n" +
+	"\x12WatchMessageStatus\x12!.sms.v1.WatchMessageStatusRequest\x1a\x15.sms.v1.MessageStatus0\x01B6Z4github.com/nikhil-github/sms-app/pkg/rpc/smsv1;smsv1b\x06proto3"
+
+var (
+	file_sms_v1_sms_proto_rawDescOnce sync.Once
+	file_sms_v1_sms_proto_rawDescData []byte
+)
+
+func file_sms_v1_sms_proto_rawDescGZIP() []byte {
+	file_sms_v1_sms_proto_rawDescOnce.Do(func() {
+		file_sms_v1_sms_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sms_v1_sms_proto_rawDesc), len(file_sms_v1_sms_proto_rawDesc)))
+	})
+	return file_sms_v1_sms_proto_rawDescData
+}
+
+var file_sms_v1_sms_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_sms_v1_sms_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_sms_v1_sms_proto_goTypes = []any{
+	(Status)(0),                       // 0: sms.v1.Status
+	(*SendRequest)(nil),               // 1: sms.v1.SendRequest
+	(*SendResponse)(nil),              // 2: sms.v1.SendResponse
+	(*BulkSendRequest)(nil),           // 3: sms.v1.BulkSendRequest
+	(*BulkMessage)(nil),               // 4: sms.v1.BulkMessage
+	(*BulkSendResponse)(nil),          // 5: sms.v1.BulkSendResponse
+	(*GetMessageStatusRequest)(nil),   // 6: sms.v1.GetMessageStatusRequest
+	(*WatchMessageStatusRequest)(nil), // 7: sms.v1.WatchMessageStatusRequest
+	(*MessageStatus)(nil),             // 8: sms.v1.MessageStatus
+	(*Link)(nil),                      // 9: sms.v1.Link
+}
+var file_sms_v1_sms_proto_depIdxs = []int32{
+	8, // 0: sms.v1.SendResponse.results:type_name -> sms.v1.MessageStatus
+	4, // 1: sms.v1.BulkSendRequest.messages:type_name -> sms.v1.BulkMessage
+	8, // 2: sms.v1.BulkSendResponse.messages:type_name -> sms.v1.MessageStatus
+	0, // 3: sms.v1.MessageStatus.status:type_name -> sms.v1.Status
+	9, // 4: sms.v1.MessageStatus.links:type_name -> sms.v1.Link
+	1, // 5: sms.v1.SmsService.Send:input_type -> sms.v1.SendRequest
+	3, // 6: sms.v1.SmsService.BulkSend:input_type -> sms.v1.BulkSendRequest
+	6, // 7: sms.v1.SmsService.GetMessageStatus:input_type -> sms.v1.GetMessageStatusRequest
+	7, // 8: sms.v1.SmsService.WatchMessageStatus:input_type -> sms.v1.WatchMessageStatusRequest
+	2, // 9: sms.v1.SmsService.Send:output_type -> sms.v1.SendResponse
+	5, // 10: sms.v1.SmsService.BulkSend:output_type -> sms.v1.BulkSendResponse
+	8, // 11: sms.v1.SmsService.GetMessageStatus:output_type -> sms.v1.MessageStatus
+	8, // 12: sms.v1.SmsService.WatchMessageStatus:output_type -> sms.v1.MessageStatus
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_sms_v1_sms_proto_init() }
+func file_sms_v1_sms_proto_init() {
+	if File_sms_v1_sms_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sms_v1_sms_proto_rawDesc), len(file_sms_v1_sms_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sms_v1_sms_proto_goTypes,
+		DependencyIndexes: file_sms_v1_sms_proto_depIdxs,
+		EnumInfos:         file_sms_v1_sms_proto_enumTypes,
+		MessageInfos:      file_sms_v1_sms_proto_msgTypes,
+	}.Build()
+	File_sms_v1_sms_proto = out.File
+	file_sms_v1_sms_proto_goTypes = nil
+	file_sms_v1_sms_proto_depIdxs = nil
+}
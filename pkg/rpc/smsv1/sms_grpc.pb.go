@@ -0,0 +1,263 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sms/v1/sms.proto
+
+package smsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SmsService_Send_FullMethodName               = "/sms.v1.SmsService/Send"
+	SmsService_BulkSend_FullMethodName           = "/sms.v1.SmsService/BulkSend"
+	SmsService_GetMessageStatus_FullMethodName   = "/sms.v1.SmsService/GetMessageStatus"
+	SmsService_WatchMessageStatus_FullMethodName = "/sms.v1.SmsService/WatchMessageStatus"
+)
+
+// SmsServiceClient is the client API for SmsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SmsService sends sms through the same providers as the REST API.
+// Calls are authenticated with an API key in the authorization metadata as
+// "Bearer <token>" or in x-api-key, or with a client certificate when the server requires mTLS.
+type SmsServiceClient interface {
+	// Send sends texts to a phone number and waits for the provider.
+	// Needs the send scope.
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	// BulkSend queues messages to many phone numbers and returns without waiting.
+	// Progress is read with GetMessageStatus or WatchMessageStatus. Needs the send scope.
+	BulkSend(ctx context.Context, in *BulkSendRequest, opts ...grpc.CallOption) (*BulkSendResponse, error)
+	// GetMessageStatus returns the status of a message sent by the caller's tenant.
+	// Needs the send or read scope.
+	GetMessageStatus(ctx context.Context, in *GetMessageStatusRequest, opts ...grpc.CallOption) (*MessageStatus, error)
+	// WatchMessageStatus streams the current status of each message, then every change,
+	// and ends once every message reached a final status. Needs the send or read scope.
+	WatchMessageStatus(ctx context.Context, in *WatchMessageStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MessageStatus], error)
+}
+
+type smsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSmsServiceClient(cc grpc.ClientConnInterface) SmsServiceClient {
+	return &smsServiceClient{cc}
+}
+
+func (c *smsServiceClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendResponse)
+	err := c.cc.Invoke(ctx, SmsService_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *smsServiceClient) BulkSend(ctx context.Context, in *BulkSendRequest, opts ...grpc.CallOption) (*BulkSendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BulkSendResponse)
+	err := c.cc.Invoke(ctx, SmsService_BulkSend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *smsServiceClient) GetMessageStatus(ctx context.Context, in *GetMessageStatusRequest, opts ...grpc.CallOption) (*MessageStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageStatus)
+	err := c.cc.Invoke(ctx, SmsService_GetMessageStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *smsServiceClient) WatchMessageStatus(ctx context.Context, in *WatchMessageStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MessageStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SmsService_ServiceDesc.Streams[0], SmsService_WatchMessageStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchMessageStatusRequest, MessageStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SmsService_WatchMessageStatusClient = grpc.ServerStreamingClient[MessageStatus]
+
+// SmsServiceServer is the server API for SmsService service.
+// All implementations must embed UnimplementedSmsServiceServer
+// for forward compatibility.
+//
+// SmsService sends sms through the same providers as the REST API.
+// Calls are authenticated with an API key in the authorization metadata as
+// "Bearer <token>" or in x-api-key, or with a client certificate when the server requires mTLS.
+type SmsServiceServer interface {
+	// Send sends texts to a phone number and waits for the provider.
+	// Needs the send scope.
+	Send(context.Context, *SendRequest) (*SendResponse, error)
+	// BulkSend queues messages to many phone numbers and returns without waiting.
+	// Progress is read with GetMessageStatus or WatchMessageStatus. Needs the send scope.
+	BulkSend(context.Context, *BulkSendRequest) (*BulkSendResponse, error)
+	// GetMessageStatus returns the status of a message sent by the caller's tenant.
+	// Needs the send or read scope.
+	GetMessageStatus(context.Context, *GetMessageStatusRequest) (*MessageStatus, error)
+	// WatchMessageStatus streams the current status of each message, then every change,
+	// and ends once every message reached a final status. Needs the send or read scope.
+	WatchMessageStatus(*WatchMessageStatusRequest, grpc.ServerStreamingServer[MessageStatus]) error
+	mustEmbedUnimplementedSmsServiceServer()
+}
+
+// UnimplementedSmsServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSmsServiceServer struct{}
+
+func (UnimplementedSmsServiceServer) Send(context.Context, *SendRequest) (*SendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedSmsServiceServer) BulkSend(context.Context, *BulkSendRequest) (*BulkSendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BulkSend not implemented")
+}
+func (UnimplementedSmsServiceServer) GetMessageStatus(context.Context, *GetMessageStatusRequest) (*MessageStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessageStatus not implemented")
+}
+func (UnimplementedSmsServiceServer) WatchMessageStatus(*WatchMessageStatusRequest, grpc.ServerStreamingServer[MessageStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchMessageStatus not implemented")
+}
+func (UnimplementedSmsServiceServer) mustEmbedUnimplementedSmsServiceServer() {}
+func (UnimplementedSmsServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeSmsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SmsServiceServer will
+// result in compilation errors.
+type UnsafeSmsServiceServer interface {
+	mustEmbedUnimplementedSmsServiceServer()
+}
+
+func RegisterSmsServiceServer(s grpc.ServiceRegistrar, srv SmsServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSmsServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SmsService_ServiceDesc, srv)
+}
+
+func _SmsService_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmsServiceServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmsService_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmsServiceServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SmsService_BulkSend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BulkSendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmsServiceServer).BulkSend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmsService_BulkSend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmsServiceServer).BulkSend(ctx, req.(*BulkSendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SmsService_GetMessageStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmsServiceServer).GetMessageStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmsService_GetMessageStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmsServiceServer).GetMessageStatus(ctx, req.(*GetMessageStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SmsService_WatchMessageStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMessageStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SmsServiceServer).WatchMessageStatus(m, &grpc.GenericServerStream[WatchMessageStatusRequest, MessageStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SmsService_WatchMessageStatusServer = grpc.ServerStreamingServer[MessageStatus]
+
+// SmsService_ServiceDesc is the grpc.ServiceDesc for SmsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SmsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sms.v1.SmsService",
+	HandlerType: (*SmsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _SmsService_Send_Handler,
+		},
+		{
+			MethodName: "BulkSend",
+			Handler:    _SmsService_BulkSend_Handler,
+		},
+		{
+			MethodName: "GetMessageStatus",
+			Handler:    _SmsService_GetMessageStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMessageStatus",
+			Handler:       _SmsService_WatchMessageStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sms/v1/sms.proto",
+}
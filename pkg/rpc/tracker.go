@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nikhil-github/sms-app/pkg/rpc/smsv1"
+)
+
+// Tracker keeps the status of the latest messages of each tenant in memory,
+// forgetting the oldest once capacity is reached.
+type Tracker struct {
+	mu       sync.Mutex
+	capacity int
+	messages map[messageKey]*smsv1.MessageStatus
+	order    []messageKey
+	watchers map[messageKey][]chan *smsv1.MessageStatus
+}
+
+type messageKey struct {
+	tenantID  string
+	messageID string
+}
+
+// NewTracker creates a tracker keeping up to capacity messages.
+func NewTracker(capacity int) *Tracker {
+	return &Tracker{
+		capacity: capacity,
+		messages: map[messageKey]*smsv1.MessageStatus{},
+		watchers: map[messageKey][]chan *smsv1.MessageStatus{},
+	}
+}
+
+// Update stores the status of a message and notifies its watchers.
+func (t *Tracker) Update(tenantID string, status *smsv1.MessageStatus) {
+	key := messageKey{tenantID: tenantID, messageID: status.GetMessageId()}
+	status = proto.Clone(status).(*smsv1.MessageStatus)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.messages[key]; !ok {
+		t.order = append(t.order, key)
+		if len(t.order) > t.capacity {
+			delete(t.messages, t.order[0])
+			t.order = t.order[1:]
+		}
+	}
+	t.messages[key] = status
+	for _, ch := range t.watchers[key] {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
+
+// Get returns the status of a message of the tenant.
+func (t *Tracker) Get(tenantID string, messageID string) (*smsv1.MessageStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status, ok := t.messages[messageKey{tenantID: tenantID, messageID: messageID}]
+	return status, ok
+}
+
+// Watch returns the current status of the tenant's messages and a channel receiving their updates
+// until stop is called. ok is false when one of the messages is unknown.
+func (t *Tracker) Watch(tenantID string, messageIDs []string) (current []*smsv1.MessageStatus, updates <-chan *smsv1.MessageStatus, stop func(), ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]messageKey, len(messageIDs))
+	current = make([]*smsv1.MessageStatus, len(messageIDs))
+	for i, id := range messageIDs {
+		keys[i] = messageKey{tenantID: tenantID, messageID: id}
+		if current[i], ok = t.messages[keys[i]]; !ok {
+			return nil, nil, nil, false
+		}
+	}
+	// Each message is updated at most once per status, so the buffer holds every update.
+	ch := make(chan *smsv1.MessageStatus, 2*len(keys))
+	for _, key := range keys {
+		t.watchers[key] = append(t.watchers[key], ch)
+	}
+	stop = func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for _, key := range keys {
+			t.watchers[key] = without(t.watchers[key], ch)
+			if len(t.watchers[key]) == 0 {
+				delete(t.watchers, key)
+			}
+		}
+	}
+	return current, ch, stop, true
+}
+
+// Final reports whether a message will not change status anymore.
+func Final(status *smsv1.MessageStatus) bool {
+	switch status.GetStatus() {
+	case smsv1.Status_STATUS_SENT, smsv1.Status_STATUS_FAILED, smsv1.Status_STATUS_SKIPPED:
+		return true
+	}
+	return false
+}
+
+func without(chans []chan *smsv1.MessageStatus, ch chan *smsv1.MessageStatus) []chan *smsv1.MessageStatus {
+	kept := chans[:0]
+	for _, c := range chans {
+		if c != ch {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
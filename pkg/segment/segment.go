@@ -0,0 +1,80 @@
+package segment
+
+import "strings"
+
+// Encodings of an sms.
+const (
+	// GSM7 packs characters of the GSM 03.38 alphabet in 7 bits.
+	GSM7 = "gsm7"
+	// UCS2 is used as soon as a character is outside the GSM alphabet.
+	UCS2 = "ucs2"
+)
+
+// gsmBasic is the GSM 03.38 default alphabet, each character takes one septet.
+const gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsmExtension characters take two septets, an escape and the character.
+const gsmExtension = "\f^{}\\[~]|€"
+
+// Single and concatenated segment capacities, concatenated segments lose room to the header.
+const (
+	gsmSingle  = 160
+	gsmMulti   = 153
+	ucs2Single = 70
+	ucs2Multi  = 67
+)
+
+// Count returns the encoding of text and the number of segments it is sent in.
+// A character is never split across segments, so the count matches what handsets reassemble.
+func Count(text string) (string, int) {
+	encoding := GSM7
+	for _, r := range text {
+		if !strings.ContainsRune(gsmBasic, r) && !strings.ContainsRune(gsmExtension, r) {
+			encoding = UCS2
+			break
+		}
+	}
+
+	var sizes []int
+	total := 0
+	for _, r := range text {
+		n := units(encoding, r)
+		sizes = append(sizes, n)
+		total += n
+	}
+	single, multi := gsmSingle, gsmMulti
+	if encoding == UCS2 {
+		single, multi = ucs2Single, ucs2Multi
+	}
+	switch {
+	case total == 0:
+		return encoding, 0
+	case total <= single:
+		return encoding, 1
+	}
+
+	segments, used := 1, 0
+	for _, n := range sizes {
+		if used+n > multi {
+			segments++
+			used = 0
+		}
+		used += n
+	}
+	return encoding, segments
+}
+
+// units returns the septets or UTF-16 code units taken by r.
+func units(encoding string, r rune) int {
+	if encoding == GSM7 {
+		if strings.ContainsRune(gsmExtension, r) {
+			return 2
+		}
+		return 1
+	}
+	if r > 0xFFFF {
+		return 2
+	}
+	return 1
+}
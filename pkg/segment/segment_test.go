@@ -0,0 +1,45 @@
+package segment_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nikhil-github/sms-app/pkg/segment"
+)
+
+func TestCount(t *testing.T) {
+	type want struct {
+		Encoding string
+		Segments int
+	}
+	testTable := []struct {
+		Name string
+		Text string
+		Want want
+	}{
+		{Name: "Empty", Text: "", Want: want{Encoding: segment.GSM7, Segments: 0}},
+		{Name: "GSM single", Text: "Your code is 1234", Want: want{Encoding: segment.GSM7, Segments: 1}},
+		{Name: "GSM full single", Text: strings.Repeat("a", 160), Want: want{Encoding: segment.GSM7, Segments: 1}},
+		{Name: "GSM two segments", Text: strings.Repeat("a", 161), Want: want{Encoding: segment.GSM7, Segments: 2}},
+		{Name: "GSM three segments", Text: strings.Repeat("a", 307), Want: want{Encoding: segment.GSM7, Segments: 3}},
+		{Name: "GSM extension counts twice", Text: strings.Repeat("€", 80), Want: want{Encoding: segment.GSM7, Segments: 1}},
+		{Name: "GSM extension over single", Text: strings.Repeat("€", 81), Want: want{Encoding: segment.GSM7, Segments: 2}},
+		{Name: "GSM extension not split", Text: strings.Repeat("a", 152) + "€" + strings.Repeat("a", 10), Want: want{Encoding: segment.GSM7, Segments: 2}},
+		{Name: "GSM accented letters", Text: "Café à Zürich", Want: want{Encoding: segment.GSM7, Segments: 1}},
+		{Name: "UCS2 single", Text: "Привет", Want: want{Encoding: segment.UCS2, Segments: 1}},
+		{Name: "UCS2 full single", Text: strings.Repeat("ж", 70), Want: want{Encoding: segment.UCS2, Segments: 1}},
+		{Name: "UCS2 two segments", Text: strings.Repeat("ж", 71), Want: want{Encoding: segment.UCS2, Segments: 2}},
+		{Name: "UCS2 forced by one character", Text: strings.Repeat("a", 100) + "ç", Want: want{Encoding: segment.UCS2, Segments: 2}},
+		{Name: "Emoji takes two units", Text: strings.Repeat("😀", 35), Want: want{Encoding: segment.UCS2, Segments: 1}},
+		{Name: "Emoji over single", Text: strings.Repeat("😀", 36), Want: want{Encoding: segment.UCS2, Segments: 2}},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			encoding, segments := segment.Count(tt.Text)
+			assert.Equal(t, tt.Want.Encoding, encoding, "encoding")
+			assert.Equal(t, tt.Want.Segments, segments, "segments")
+		})
+	}
+}
@@ -1,28 +1,72 @@
 package service
 
 import (
-	"github.com/zpnk/go-bitly"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/logging"
 )
 
-// Bitly wraps bitly client dependencies.
+// BitlyURL is the base URL of the bitly API.
+const BitlyURL = "https://api-ssl.bitly.com"
+
+const shortenURL = BitlyURL + "/v4/shorten"
+
+// Bitly shortens links with the bitly v4 API.
 type Bitly struct {
-	client *bitly.Client
-	logger *zap.Logger
+	mu         sync.RWMutex
+	token      string
+	httpClient HTTPClient
+	logger     *zap.Logger
 }
 
-// NewBitly wraps dependencies for URL shortening.
-func NewBitly(client *bitly.Client, logger *zap.Logger) *Bitly {
-	return &Bitly{client: client, logger: logger}
+// NewBitly creates a Bitly authenticating with token.
+func NewBitly(httpClient HTTPClient, token string, logger *zap.Logger) *Bitly {
+	return &Bitly{httpClient: httpClient, token: token, logger: logger}
 }
 
 // ShortURL shorten long URL
-// / github.com/zpnk/go-bitly go bitly client
-func (b *Bitly) ShortURL(longURL string) (string, error) {
-	short, err := b.client.Links.Shorten(longURL)
+func (b *Bitly) ShortURL(ctx context.Context, longURL string) (string, error) {
+	b.mu.RLock()
+	token := b.token
+	b.mu.RUnlock()
+
+	body, _ := json.Marshal(map[string]string{"long_url": longURL})
+	req, err := http.NewRequest("POST", shortenURL, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create shorten request")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := b.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		b.logger.Error("shorten-link-error", zap.Error(err))
-		return "", err
+		logging.From(ctx, b.logger).Error("shorten-link-error", zap.Error(err))
+		return "", errors.Wrap(err, "failed to shorten link")
+	}
+	defer res.Body.Close()
+	// bitly answers 201 for a new link and 200 for one shortened before.
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		logging.From(ctx, b.logger).Error("shorten-link-error", zap.Int("status code", res.StatusCode))
+		return "", errors.Errorf("bitly answered status %d", res.StatusCode)
+	}
+	var link struct {
+		Link string `json:"link"`
 	}
-	return short.URL, nil
+	if err := json.NewDecoder(res.Body).Decode(&link); err != nil || link.Link == "" {
+		return "", errors.New("failed to decode shortened link")
+	}
+	return link.Link, nil
+}
+
+// SetToken replaces the token, such as when it is rotated.
+func (b *Bitly) SetToken(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.token = token
 }
@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+func TestShortURL(t *testing.T) {
+	type want struct {
+		Err   string
+		Short string
+	}
+	testTable := []struct {
+		Name     string
+		Response *http.Response
+		Err      error
+		Want     want
+	}{
+		{
+			Name:     "Success : new link",
+			Response: mockResponse(http.StatusCreated, []byte(`{"link":"https://bit.ly/xyz","long_url":"http://www.google.com/"}`)),
+			Want:     want{Short: "https://bit.ly/xyz"},
+		},
+		{
+			Name:     "Success : link shortened before",
+			Response: mockResponse(http.StatusOK, []byte(`{"link":"https://bit.ly/xyz"}`)),
+			Want:     want{Short: "https://bit.ly/xyz"},
+		},
+		{
+			Name:     "Failure : refused token",
+			Response: mockResponse(http.StatusForbidden, []byte(`{"message":"FORBIDDEN"}`)),
+			Want:     want{Err: "bitly answered status 403"},
+		},
+		{
+			Name:     "Failure : no link",
+			Response: mockResponse(http.StatusOK, []byte(`{}`)),
+			Want:     want{Err: "failed to decode shortened link"},
+		},
+		{
+			Name: "Failure : unreachable",
+			Err:  errors.New("timeout"),
+			Want: want{Err: "failed to shorten link: timeout"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var c httpClient
+			c.On("Do", mock.MatchedBy(func(r *http.Request) bool {
+				body, _ := ioutil.ReadAll(r.Body)
+				return r.Method == "POST" && r.URL.String() == "https://api-ssl.bitly.com/v4/shorten" &&
+					r.Header.Get("Authorization") == "Bearer rotated" && string(body) == `{"long_url":"http://www.google.com"}`
+			})).Return(tt.Response, tt.Err).Once()
+			b := service.NewBitly(&c, "token", zap.NewNop())
+			b.SetToken("rotated")
+
+			short, err := b.ShortURL(context.Background(), "http://www.google.com")
+			c.AssertExpectations(t)
+			if tt.Want.Err != "" {
+				assert.EqualError(t, err, tt.Want.Err, "error message")
+				return
+			}
+			assert.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Short, short, "short link")
+		})
+	}
+}
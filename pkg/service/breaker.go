@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+)
+
+// BreakerClient fails fast while the provider is failing.
+// Transport errors and 5xx responses count as failures.
+type BreakerClient struct {
+	client  HTTPClient
+	breaker *breaker.Breaker
+}
+
+// NewBreakerClient wraps client with the breaker.
+func NewBreakerClient(client HTTPClient, b *breaker.Breaker) *BreakerClient {
+	return &BreakerClient{client: client, breaker: b}
+}
+
+// Do sends the request unless the breaker is open.
+func (c *BreakerClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	res, err := c.client.Do(req)
+	switch {
+	case err != nil && neutral(req.Context(), err):
+		c.breaker.Cancel()
+	case err != nil || res.StatusCode >= http.StatusInternalServerError:
+		c.breaker.Failure()
+	default:
+		c.breaker.Success()
+	}
+	return res, err
+}
+
+// BreakerShorter fails fast while the URL shortener is failing.
+// Errors of calls cancelled or timed out by the caller do not count.
+type BreakerShorter struct {
+	shorter Shorter
+	breaker *breaker.Breaker
+}
+
+// NewBreakerShorter wraps shorter with the breaker.
+func NewBreakerShorter(shorter Shorter, b *breaker.Breaker) *BreakerShorter {
+	return &BreakerShorter{shorter: shorter, breaker: b}
+}
+
+// ShortURL shortens the link unless the breaker is open.
+func (s *BreakerShorter) ShortURL(ctx context.Context, longURL string) (string, error) {
+	if err := s.breaker.Allow(); err != nil {
+		return "", err
+	}
+	short, err := s.shorter.ShortURL(ctx, longURL)
+	switch {
+	case err != nil && neutral(ctx, err):
+		s.breaker.Cancel()
+	case err != nil:
+		s.breaker.Failure()
+	default:
+		s.breaker.Success()
+	}
+	if err != nil {
+		return "", err
+	}
+	return short, nil
+}
+
+// neutral reports whether the error is caused by the caller rather than the provider.
+func neutral(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || err == ratelimit.ErrBackpressure
+}
@@ -0,0 +1,21 @@
+package service
+
+import "context"
+
+type callbacksKey struct{}
+
+// Callbacks are the URLs transmit calls with the delivery receipt of a message and with replies to it.
+type Callbacks struct {
+	Delivery string
+	Reply    string
+}
+
+// WithCallbacks sets the callbacks of the message sent with ctx.
+func WithCallbacks(ctx context.Context, c Callbacks) context.Context {
+	return context.WithValue(ctx, callbacksKey{}, c)
+}
+
+func callbacksFromContext(ctx context.Context) (Callbacks, bool) {
+	c, ok := ctx.Value(callbacksKey{}).(Callbacks)
+	return c, ok
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+)
+
+type senderKey struct{}
+
+// withSender stores the sender number used by the request for per sender pacing.
+func withSender(ctx context.Context, senderID string) context.Context {
+	return context.WithValue(ctx, senderKey{}, senderID)
+}
+
+func senderFromContext(ctx context.Context) (string, bool) {
+	s, ok := ctx.Value(senderKey{}).(string)
+	return s, ok
+}
+
+// PacedClient paces calls to the provider to stay under its throughput limits.
+// All calls share the provider pacer, sends are also paced per sender number.
+type PacedClient struct {
+	client    HTTPClient
+	provider  *ratelimit.Pacer
+	perSender ratelimit.Limit
+	maxDelay  time.Duration
+
+	mu      sync.Mutex
+	senders map[string]*ratelimit.Pacer
+}
+
+// NewPacedClient wraps client with provider and per sender number limits.
+// Calls that would wait longer than maxDelay fail with ratelimit.ErrBackpressure.
+func NewPacedClient(client HTTPClient, provider ratelimit.Limit, perSender ratelimit.Limit, maxDelay time.Duration) *PacedClient {
+	return &PacedClient{
+		client:    client,
+		provider:  ratelimit.NewPacer(provider, maxDelay),
+		perSender: perSender,
+		maxDelay:  maxDelay,
+		senders:   make(map[string]*ratelimit.Pacer),
+	}
+}
+
+// Do waits for a slot then sends the request. The provider's slot is reserved once the sender's
+// slot is reached, so calls from other senders are never spaced closer than the provider allows.
+// When the provider's slot is unavailable or the wait is cancelled the sender's slot is given back.
+func (p *PacedClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	var sender *ratelimit.Reservation
+	if id, ok := senderFromContext(ctx); ok {
+		var err error
+		if sender, err = p.sender(id).Reserve(); err != nil {
+			return nil, err
+		}
+		if err := sender.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.provider.Wait(ctx); err != nil {
+		if sender != nil {
+			sender.Cancel()
+		}
+		return nil, err
+	}
+	return p.client.Do(req)
+}
+
+// SetLimits replaces the provider and per sender number limits.
+func (p *PacedClient) SetLimits(provider ratelimit.Limit, perSender ratelimit.Limit, maxDelay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.provider.SetLimit(provider, maxDelay)
+	p.perSender = perSender
+	p.maxDelay = maxDelay
+	for _, s := range p.senders {
+		s.SetLimit(perSender, maxDelay)
+	}
+}
+
+// Waiting returns the number of calls waiting for a slot.
+func (p *PacedClient) Waiting() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.provider.Waiting()
+	for _, s := range p.senders {
+		n += s.Waiting()
+	}
+	return n
+}
+
+func (p *PacedClient) sender(id string) *ratelimit.Pacer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.senders[id]
+	if !ok {
+		s = ratelimit.NewPacer(p.perSender, p.maxDelay)
+		p.senders[id] = s
+	}
+	return s
+}
@@ -10,17 +10,44 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
-	"mvdan.cc/xurls"
+	"mvdan.cc/xurls/v2"
+
+	"github.com/nikhil-github/sms-app/pkg/logging"
 )
 
 // SenderService wraps dependencies to send sms.
 type SenderService struct {
-	apiKey     string
+	account    Account
 	bitly      Shorter
 	httpClient HTTPClient
 	logger     *zap.Logger
-	secret     string
+}
+
+// Account represent the provider credentials and defaults used to send sms.
+type Account struct {
+	APIKey      string
+	Secret      string
+	SenderID    string
+	CountryCode string
+}
+
+// Receipt describes how a sms was sent.
+type Receipt struct {
+	Provider string
+	// MessageID is the provider's ID of the message, empty when it was not sent.
+	MessageID string
+	// Text is the text as sent, with links replaced.
+	Text  string
+	Links []Link
+}
+
+// Link represent a link of the text replaced by its short form.
+type Link struct {
+	URL   string `json:"url"`
+	Short string `json:"short"`
 }
 
 // HTTPClient an interface for HTTP requests.
@@ -30,12 +57,20 @@ type HTTPClient interface {
 
 // Shorter provide method for shorten URL.
 type Shorter interface {
-	ShortURL(longURL string) (string, error)
+	ShortURL(ctx context.Context, longURL string) (string, error)
 }
 
-// New creates a new SenderService.
+// New creates a new SenderService formatting numbers for AU.
 func New(apiKey string, secret string, httpClient HTTPClient, l *zap.Logger, bitly Shorter) *SenderService {
-	return &SenderService{apiKey: apiKey, secret: secret, httpClient: httpClient, logger: l, bitly: bitly}
+	return NewForAccount(Account{APIKey: apiKey, Secret: secret, CountryCode: defaultCountryCode}, httpClient, l, bitly)
+}
+
+// NewForAccount creates a new SenderService using the given account.
+func NewForAccount(account Account, httpClient HTTPClient, l *zap.Logger, bitly Shorter) *SenderService {
+	if account.CountryCode == "" {
+		account.CountryCode = defaultCountryCode
+	}
+	return &SenderService{account: account, httpClient: httpClient, logger: l, bitly: bitly}
 }
 
 // Format method validates and format the given phone number
@@ -43,9 +78,9 @@ func (s *SenderService) Format(ctx context.Context, phoneNumber string) (int64,
 
 	data := url.Values{}
 	data.Set("msisdn", phoneNumber)
-	data.Set("countrycode", "AU")
+	data.Set("countrycode", s.account.CountryCode)
 
-	req, err := s.request("POST", formatNumber, data.Encode())
+	req, err := s.request(ctx, "POST", formatNumber, data.Encode())
 	if err != nil {
 		return 0, false, err
 	}
@@ -59,7 +94,7 @@ func (s *SenderService) Format(ctx context.Context, phoneNumber string) (int64,
 	var response Format
 	if res.StatusCode == http.StatusOK {
 		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-			s.logger.Error("error", zap.Error(err))
+			s.log(ctx).Error("error", zap.Error(err))
 			return 0, false, fmt.Errorf("error decoding")
 		}
 		if response.Number.IsValid {
@@ -68,75 +103,96 @@ func (s *SenderService) Format(ctx context.Context, phoneNumber string) (int64,
 		return 0, false, nil
 	}
 
-	s.logger.Error("Format number error", zap.Int("status code", res.StatusCode))
-	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-		s.logger.Error("error", zap.Error(err))
-		return 0, false, fmt.Errorf("error decoding")
-	}
-
-	s.logger.Error("Error code", zap.String("code", response.Error.Code))
-	s.logger.Error("Error description", zap.String("description", response.Error.Description))
-	return 0, false, nil
-
+	s.log(ctx).Error("Format number error", zap.Int("status code", res.StatusCode))
+	return 0, false, s.providerError(ctx, res)
 }
 
 // Send transmit sms message to the given number
 // links are searched and replaced with short bitly links
-func (s *SenderService) Send(ctx context.Context, phoneNumber int64, text string) error {
+func (s *SenderService) Send(ctx context.Context, phoneNumber int64, text string) (Receipt, error) {
 
-	text, err := s.replaceLinks(text)
+	receipt := Receipt{Provider: Provider}
+	text, links, err := s.replaceLinks(ctx, text)
 	if err != nil {
-		return err
+		return receipt, err
 	}
+	receipt.Text, receipt.Links = text, links
 	data := url.Values{}
 	data.Set("message", text)
 	data.Set("to", strconv.FormatInt(phoneNumber, 10))
+	if s.account.SenderID != "" {
+		data.Set("from", s.account.SenderID)
+	}
+	if c, ok := callbacksFromContext(ctx); ok {
+		data.Set("dlr_callback", c.Delivery)
+		data.Set("reply_callback", c.Reply)
+	}
 
-	req, err := s.request("POST", sendSMS, data.Encode())
+	req, err := s.request(ctx, "POST", sendSMS, data.Encode())
 	if err != nil {
-		return err
+		return receipt, err
 	}
-	res, err := s.httpClient.Do(req.WithContext(ctx))
+	res, err := s.httpClient.Do(req.WithContext(withSender(ctx, s.account.SenderID)))
 	if err != nil {
-		return errors.Wrap(err, "failed to send sms")
+		return receipt, errors.Wrap(err, "failed to send sms")
 	}
 
 	defer res.Body.Close()
 	if res.StatusCode == http.StatusOK {
-		return nil
+		var response Response
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			// The sms is sent, only its provider ID is unknown.
+			s.log(ctx).Warn("Unable to decode send sms response", zap.Error(err))
+		}
+		if response.MessageID != 0 {
+			receipt.MessageID = strconv.FormatInt(response.MessageID, 10)
+		}
+		return receipt, nil
 	}
 
-	s.logger.Error("Send SMS error", zap.Int("status code", res.StatusCode))
+	s.log(ctx).Error("Send SMS error", zap.Int("status code", res.StatusCode))
+	return receipt, s.providerError(ctx, res)
+}
+
+// providerError reads the transmit error from a response with an error status.
+func (s *SenderService) providerError(ctx context.Context, res *http.Response) error {
 	var response Response
-	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
-		s.logger.Error("error", zap.Error(err))
-		return err
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		s.log(ctx).Error("error", zap.Error(err))
+		return &ProviderError{StatusCode: res.StatusCode}
 	}
-
-	s.logger.Error("Error code", zap.String("code", response.Error.Code))
-	s.logger.Error("Error description", zap.String("description", response.Error.Description))
-	return nil
+	s.log(ctx).Error("Error code", zap.String("code", response.Error.Code))
+	s.log(ctx).Error("Error description", zap.String("description", response.Error.Description))
+	return &ProviderError{StatusCode: res.StatusCode, Code: response.Error.Code, Description: response.Error.Description}
 }
 
 // replaceLinks find links in text and replace them with bitly links
 // mvdan.cc/xurls find all links in a string
-func (s *SenderService) replaceLinks(text string) (string, error) {
+func (s *SenderService) replaceLinks(ctx context.Context, text string) (string, []Link, error) {
+	var replaced []Link
 	links := xurls.Strict().FindAllString(text, -1)
 	for _, link := range links {
-		short, err := s.bitly.ShortURL(link)
+		short, err := s.bitly.ShortURL(ctx, link)
 		if err != nil {
-			s.logger.Error("shorten-link-error", zap.String("long-link", link), zap.Error(err))
-			return "", err
+			s.log(ctx).Error("shorten-link-error", zap.String("long-link", link), zap.Error(err))
+			return "", nil, err
 		}
 		if len(short) == 0 {
-			return "", fmt.Errorf("failed to shorten link %s", link)
+			return "", nil, fmt.Errorf("failed to shorten link %s", link)
 		}
 		text = strings.Replace(text, link, short, 1)
+		replaced = append(replaced, Link{URL: link, Short: short})
 	}
-	return text, nil
+	return text, replaced, nil
+}
+
+// log returns the request scoped logger of ctx, falling back to the service logger.
+func (s *SenderService) log(ctx context.Context) *zap.Logger {
+	return logging.From(ctx, s.logger)
 }
 
-func (s *SenderService) request(method string, resource string, data string) (*http.Request, error) {
+// request builds a transmit API request carrying the trace context of ctx.
+func (s *SenderService) request(ctx context.Context, method string, resource string, data string) (*http.Request, error) {
 	u, err := url.ParseRequestURI(baseURL)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse uri")
@@ -146,8 +202,9 @@ func (s *SenderService) request(method string, resource string, data string) (*h
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create request")
 	}
-	req.SetBasicAuth(s.apiKey, s.secret)
+	req.SetBasicAuth(s.account.APIKey, s.account.Secret)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Content-Length", strconv.Itoa(len(data)))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 	return req, nil
 }
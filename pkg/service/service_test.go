@@ -6,17 +6,26 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
 	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
 )
 
 func TestFormat(t *testing.T) {
@@ -82,6 +91,21 @@ func TestFormat(t *testing.T) {
 			}},
 			Want: want{Err: "failed to format number: failed"},
 		},
+		{
+			Name: "Error : rejected by provider",
+			Args: args{Number: "12345678901"},
+			Fields: fields{MockOperations: func(c *httpClient) {
+				data := url.Values{}
+				data.Set("msisdn", "12345678901")
+				data.Set("countrycode", "AU")
+				req, err := http.NewRequest("POST", "https://api.transmitsms.com/format-number.json", strings.NewReader(data.Encode()))
+				if err != nil {
+					panic(err)
+				}
+				c.OnDo(req).Return(mockResponse(http.StatusUnauthorized, []byte(`{"error":{"code":"AUTH_FAILED","description":"Invalid api key"}}`)), nil).Once()
+			}},
+			Want: want{Err: "transmit returned status 401: AUTH_FAILED Invalid api key"},
+		},
 	}
 	for _, tt := range testTable {
 		t.Run(tt.Name, func(t *testing.T) {
@@ -111,7 +135,8 @@ func TestSend(t *testing.T) {
 		MockOperations func(m *httpClient, b *mockBitly)
 	}
 	type want struct {
-		Err string
+		Err     string
+		Receipt service.Receipt
 	}
 	testTable := []struct {
 		Name   string
@@ -130,9 +155,15 @@ func TestSend(t *testing.T) {
 				if err != nil {
 					panic(err)
 				}
-				c.OnDo(req).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil).Once()
+				c.OnDo(req).Return(mockResponse(http.StatusOK, []byte(`{"message_id":2937421,"error":{"code":"SUCCESS","description":"OK"}}`)), nil).Once()
 				b.On("ShortURL", "http://www.google.com").Return("http://bit.ly/xyz", nil)
 			}},
+			Want: want{Receipt: service.Receipt{
+				Provider:  "transmit",
+				MessageID: "2937421",
+				Text:      "text http://bit.ly/xyz",
+				Links:     []service.Link{{URL: "http://www.google.com", Short: "http://bit.ly/xyz"}},
+			}},
 		},
 		{
 			Name: "Error : send sms",
@@ -148,6 +179,36 @@ func TestSend(t *testing.T) {
 				c.OnDo(req).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"FIELD_INVALID","description":"is not a valid number."}}`)), errors.New("fail")).Once()
 			}},
 			Want: want{Err: "failed to send sms: fail"},
+		},
+		{
+			Name: "Error : rejected by provider",
+			Args: args{Number: int64(1234567890), Text: "text-wrong"},
+			Fields: fields{MockOperations: func(c *httpClient, b *mockBitly) {
+				data := url.Values{}
+				data.Set("message", "text-wrong")
+				data.Set("to", strconv.FormatInt(int64(1234567890), 10))
+				req, err := http.NewRequest("POST", "https://api.transmitsms.com/send-sms.json", strings.NewReader(data.Encode()))
+				if err != nil {
+					panic(err)
+				}
+				c.OnDo(req).Return(mockResponse(http.StatusBadRequest, []byte(`{"error":{"code":"FIELD_INVALID","description":"is not a valid number."}}`)), nil).Once()
+			}},
+			Want: want{Err: "transmit returned status 400: FIELD_INVALID is not a valid number."},
+		},
+		{
+			Name: "Error : undecodable provider error",
+			Args: args{Number: int64(1234567890), Text: "text-wrong"},
+			Fields: fields{MockOperations: func(c *httpClient, b *mockBitly) {
+				data := url.Values{}
+				data.Set("message", "text-wrong")
+				data.Set("to", strconv.FormatInt(int64(1234567890), 10))
+				req, err := http.NewRequest("POST", "https://api.transmitsms.com/send-sms.json", strings.NewReader(data.Encode()))
+				if err != nil {
+					panic(err)
+				}
+				c.OnDo(req).Return(mockResponse(http.StatusBadGateway, []byte(`<html>`)), nil).Once()
+			}},
+			Want: want{Err: "transmit returned status 502"},
 		}}
 	for _, tt := range testTable {
 		t.Run(tt.Name, func(t *testing.T) {
@@ -155,13 +216,14 @@ func TestSend(t *testing.T) {
 			var m mockBitly
 			tt.Fields.MockOperations(&client, &m)
 			s := service.New("key", "secret", &client, zap.NewNop(), &m)
-			err := s.Send(context.Background(), tt.Args.Number, tt.Args.Text)
+			receipt, err := s.Send(context.Background(), tt.Args.Number, tt.Args.Text)
 			client.AssertExpectations(t)
 			if tt.Want.Err != "" {
 				assert.EqualError(t, err, tt.Want.Err, "error message")
 				return
 			}
 			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Receipt, receipt, "receipt")
 		})
 	}
 }
@@ -199,7 +261,335 @@ type mockBitly struct {
 	mock.Mock
 }
 
-func (m *mockBitly) ShortURL(longURL string) (string, error) {
+func (m *mockBitly) ShortURL(ctx context.Context, longURL string) (string, error) {
 	args := m.Called(longURL)
 	return args.Get(0).(string), args.Error(1)
 }
+
+func TestTenantService(t *testing.T) {
+	type args struct {
+		Tenant *tenant.Tenant
+	}
+	type want struct {
+		Err      string
+		User     string
+		Password string
+		Body     url.Values
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : tenant credentials and sender id",
+			Args: args{Tenant: &tenant.Tenant{ID: "retail", Transmit: tenant.Transmit{APIKey: "retail-key", Secret: "retail-secret"}, SenderID: "Retail"}},
+			Want: want{User: "retail-key", Password: "retail-secret", Body: url.Values{"message": {"text"}, "to": {"61400000000"}, "from": {"Retail"}}},
+		},
+		{
+			Name: "Success : tenant without sender id",
+			Args: args{Tenant: &tenant.Tenant{ID: "loans", Transmit: tenant.Transmit{APIKey: "loans-key", Secret: "loans-secret"}}},
+			Want: want{User: "loans-key", Password: "loans-secret", Body: url.Values{"message": {"text"}, "to": {"61400000000"}}},
+		},
+		{
+			Name: "Error : no tenant",
+			Want: want{Err: "no tenant in context"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var client httpClient
+			var m mockBitly
+			var req *http.Request
+			client.On("Do", mock.Anything).Run(func(args mock.Arguments) {
+				req = args.Get(0).(*http.Request)
+			}).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil).Maybe()
+			s := service.NewTenantService(&client, zap.NewNop(), &m)
+			ctx := context.Background()
+			if tt.Args.Tenant != nil {
+				ctx = tenant.WithTenant(ctx, *tt.Args.Tenant)
+			}
+			_, err := s.Send(ctx, int64(61400000000), "text")
+			if tt.Want.Err != "" {
+				assert.EqualError(t, err, tt.Want.Err, "error message")
+				return
+			}
+			require.NoError(t, err, "error")
+			user, password, ok := req.BasicAuth()
+			assert.True(t, ok, "basic auth")
+			assert.Equal(t, tt.Want.User, user, "user")
+			assert.Equal(t, tt.Want.Password, password, "password")
+			require.NoError(t, req.ParseForm(), "parse form")
+			assert.Equal(t, tt.Want.Body, req.PostForm, "body")
+		})
+	}
+}
+
+func TestSendPropagatesTrace(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var client httpClient
+	var req *http.Request
+	client.On("Do", mock.Anything).Run(func(args mock.Arguments) {
+		req = args.Get(0).(*http.Request)
+	}).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+
+	s := service.New("key", "secret", &client, zap.NewNop(), &mockBitly{})
+	_, err := s.Send(ctx, int64(61400000000), "text")
+	require.NoError(t, err, "send")
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", req.Header.Get("traceparent"), "traceparent")
+}
+
+func TestSendCallbacks(t *testing.T) {
+	var client httpClient
+	var req *http.Request
+	client.On("Do", mock.Anything).Run(func(args mock.Arguments) {
+		req = args.Get(0).(*http.Request)
+	}).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil)
+	ctx := service.WithCallbacks(context.Background(), service.Callbacks{
+		Delivery: "https://sms.example.com/api/v1/webhooks/transmit/delivery?message=m1",
+		Reply:    "https://sms.example.com/api/v1/webhooks/transmit/reply?message=m1",
+	})
+
+	s := service.New("key", "secret", &client, zap.NewNop(), &mockBitly{})
+	_, err := s.Send(ctx, int64(61400000000), "text")
+	require.NoError(t, err, "send")
+	require.NoError(t, req.ParseForm(), "form")
+	assert.Equal(t, "https://sms.example.com/api/v1/webhooks/transmit/delivery?message=m1", req.PostForm.Get("dlr_callback"), "delivery callback")
+	assert.Equal(t, "https://sms.example.com/api/v1/webhooks/transmit/reply?message=m1", req.PostForm.Get("reply_callback"), "reply callback")
+}
+
+func TestPacedClient(t *testing.T) {
+	var client httpClient
+	client.On("Do", mock.Anything).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil)
+	paced := service.NewPacedClient(&client, ratelimit.Limit{}, ratelimit.Limit{Count: 1, Period: time.Hour}, time.Millisecond)
+	retail := service.NewForAccount(service.Account{APIKey: "key", Secret: "secret", SenderID: "Retail"}, paced, zap.NewNop(), &mockBitly{})
+	loans := service.NewForAccount(service.Account{APIKey: "key", Secret: "secret", SenderID: "Loans"}, paced, zap.NewNop(), &mockBitly{})
+
+	_, err := retail.Send(context.Background(), int64(61400000000), "text")
+	require.NoError(t, err, "first send")
+	_, err = retail.Send(context.Background(), int64(61400000000), "text")
+	assert.EqualError(t, err, "failed to send sms: outbound rate limit reached, try again later", "second send")
+	_, err = loans.Send(context.Background(), int64(61400000000), "text")
+	require.NoError(t, err, "other sender")
+	client.AssertNumberOfCalls(t, "Do", 2)
+}
+
+func TestPacedClientProviderBusy(t *testing.T) {
+	var client httpClient
+	client.On("Do", mock.Anything).Return(mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil)
+	limit := ratelimit.Limit{Count: 1, Period: time.Hour}
+	paced := service.NewPacedClient(&client, limit, limit, time.Millisecond)
+	retail := service.NewForAccount(service.Account{APIKey: "key", Secret: "secret", SenderID: "Retail"}, paced, zap.NewNop(), &mockBitly{})
+	loans := service.NewForAccount(service.Account{APIKey: "key", Secret: "secret", SenderID: "Loans"}, paced, zap.NewNop(), &mockBitly{})
+
+	_, err := retail.Send(context.Background(), int64(61400000000), "text")
+	require.NoError(t, err, "first send")
+	_, err = loans.Send(context.Background(), int64(61400000000), "text")
+	assert.EqualError(t, err, "failed to send sms: outbound rate limit reached, try again later", "provider busy")
+
+	paced.SetLimits(ratelimit.Limit{}, limit, time.Millisecond)
+	_, err = loans.Send(context.Background(), int64(61400000000), "text")
+	require.NoError(t, err, "sender slot kept")
+	assert.Equal(t, 0, paced.Waiting(), "waiting")
+	client.AssertNumberOfCalls(t, "Do", 2)
+}
+
+func TestPacedClientSpacesSenders(t *testing.T) {
+	var client timedClient
+	interval := 50 * time.Millisecond
+	paced := service.NewPacedClient(&client, ratelimit.Limit{Count: 1, Period: interval}, ratelimit.Limit{Count: 1, Period: 4 * interval}, time.Second)
+	retail := service.NewForAccount(service.Account{APIKey: "key", Secret: "secret", SenderID: "Retail"}, paced, zap.NewNop(), &mockBitly{})
+	loans := service.NewForAccount(service.Account{APIKey: "key", Secret: "secret", SenderID: "Loans"}, paced, zap.NewNop(), &mockBitly{})
+
+	// Retail's second send waits for its sender slot, loans sends when that slot is reached.
+	var wg sync.WaitGroup
+	send := func(s *service.SenderService, after time.Duration) {
+		defer wg.Done()
+		time.Sleep(after)
+		_, err := s.Send(context.Background(), int64(61400000000), "text")
+		assert.NoError(t, err, "send")
+	}
+	wg.Add(3)
+	go send(retail, 0)
+	go send(retail, interval/5)
+	go send(loans, 4*interval)
+	wg.Wait()
+
+	calls := client.calls
+	require.Len(t, calls, 3, "calls")
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Before(calls[j]) })
+	for i := 1; i < len(calls); i++ {
+		assert.True(t, calls[i].Sub(calls[i-1]) >= interval-5*time.Millisecond, "call %d spaced %s after the previous one", i, calls[i].Sub(calls[i-1]))
+	}
+}
+
+// timedClient records when each call is made.
+type timedClient struct {
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (c *timedClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, time.Now())
+	return mockResponse(http.StatusOK, []byte(`{"error":{"code":"SUCCESS","description":"OK"}}`)), nil
+}
+
+func TestProviderErrorCategory(t *testing.T) {
+	type args struct {
+		Status int
+		Body   string
+	}
+	type want struct {
+		Category service.Category
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "AUTH_FAILED_NO_DATA",
+			Args: args{Status: http.StatusUnauthorized, Body: `{"error":{"code":"AUTH_FAILED_NO_DATA","description":"No api key"}}`},
+			Want: want{Category: service.CategoryAuth},
+		},
+		{
+			Name: "AUTH_FAILED",
+			Args: args{Status: http.StatusUnauthorized, Body: `{"error":{"code":"AUTH_FAILED","description":"Invalid api key"}}`},
+			Want: want{Category: service.CategoryAuth},
+		},
+		{
+			Name: "NO_ACCESS",
+			Args: args{Status: http.StatusForbidden, Body: `{"error":{"code":"NO_ACCESS","description":"No access"}}`},
+			Want: want{Category: service.CategoryAuth},
+		},
+		{
+			Name: "LEDGER_ERROR",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"LEDGER_ERROR","description":"Not enough credit"}}`},
+			Want: want{Category: service.CategoryBalance},
+		},
+		{
+			Name: "RECIPIENTS_ERROR",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"RECIPIENTS_ERROR","description":"No valid recipients"}}`},
+			Want: want{Category: service.CategoryInvalidRecipient},
+		},
+		{
+			Name: "FIELD_EMPTY",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_EMPTY","description":"to is empty"}}`},
+			Want: want{Category: service.CategoryInvalidRecipient},
+		},
+		{
+			Name: "FIELD_INVALID",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_INVALID","description":"to is invalid"}}`},
+			Want: want{Category: service.CategoryInvalidRecipient},
+		},
+		{
+			Name: "FIELD_INVALID : quoted number",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_INVALID","description":"'msisdn' is not a valid number"}}`},
+			Want: want{Category: service.CategoryInvalidRecipient},
+		},
+		{
+			Name: "FIELD_EMPTY : message",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_EMPTY","description":"message is empty"}}`},
+			Want: want{Category: service.CategoryUnknown},
+		},
+		{
+			Name: "FIELD_INVALID : sender",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_INVALID","description":"from is invalid"}}`},
+			Want: want{Category: service.CategoryUnknown},
+		},
+		{
+			Name: "FIELD_INVALID : no description",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_INVALID"}}`},
+			Want: want{Category: service.CategoryUnknown},
+		},
+		{
+			Name: "OVER_LIMIT",
+			Args: args{Status: http.StatusTooManyRequests, Body: `{"error":{"code":"OVER_LIMIT","description":"Too many requests"}}`},
+			Want: want{Category: service.CategoryRateLimited},
+		},
+		{
+			Name: "INTERNAL_ERROR",
+			Args: args{Status: http.StatusInternalServerError, Body: `{"error":{"code":"INTERNAL_ERROR","description":"Try again"}}`},
+			Want: want{Category: service.CategoryServer},
+		},
+		{
+			Name: "Unknown code",
+			Args: args{Status: http.StatusBadRequest, Body: `{"error":{"code":"FIELD_UNSAFE","description":"Unsafe"}}`},
+			Want: want{Category: service.CategoryUnknown},
+		},
+		{
+			Name: "No body : unauthorized",
+			Args: args{Status: http.StatusUnauthorized},
+			Want: want{Category: service.CategoryAuth},
+		},
+		{
+			Name: "No body : payment required",
+			Args: args{Status: http.StatusPaymentRequired},
+			Want: want{Category: service.CategoryBalance},
+		},
+		{
+			Name: "No body : bad gateway",
+			Args: args{Status: http.StatusBadGateway},
+			Want: want{Category: service.CategoryServer},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			var client httpClient
+			client.On("Do", mock.Anything).Return(mockResponse(tt.Args.Status, []byte(tt.Args.Body)), nil).Once()
+			s := service.New("key", "secret", &client, zap.NewNop(), nil)
+			_, err := s.Send(context.Background(), int64(61400000000), "text")
+			require.Error(t, err, "error")
+			pe, ok := errors.Cause(err).(*service.ProviderError)
+			require.True(t, ok, "provider error")
+			assert.Equal(t, tt.Want.Category, pe.Category(), "category")
+		})
+	}
+}
+
+func TestBreakerClient(t *testing.T) {
+	var client httpClient
+	for i := 0; i < 2; i++ {
+		client.On("Do", mock.Anything).Return(mockResponse(http.StatusServiceUnavailable, []byte(`{"error":{"code":"INTERNAL_ERROR","description":"down"}}`)), nil).Once()
+	}
+	b := breaker.New("transmit", 2, time.Minute)
+	s := service.New("key", "secret", service.NewBreakerClient(&client, b), zap.NewNop(), nil)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := s.Format(context.Background(), "0400000000")
+		assert.IsType(t, &service.ProviderError{}, errors.Cause(err), "provider error")
+	}
+	_, _, err := s.Format(context.Background(), "0400000000")
+	assert.EqualError(t, err, "failed to format number: transmit: circuit breaker open", "fail fast")
+	assert.Equal(t, breaker.Open, b.State(), "state")
+	client.AssertNumberOfCalls(t, "Do", 2)
+}
+
+func TestBreakerShorter(t *testing.T) {
+	var bitly mockBitly
+	bitly.On("ShortURL", "http://www.google.com").Return("", context.Canceled).Once()
+	bitly.On("ShortURL", "http://www.google.com").Return("", errors.New("bitly is down")).Once()
+	b := breaker.New("bitly", 1, time.Minute)
+	s := service.NewBreakerShorter(&bitly, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.ShortURL(ctx, "http://www.google.com")
+	assert.Equal(t, context.Canceled, err, "cancelled")
+	assert.Equal(t, breaker.Closed, b.State(), "cancellation is not a failure")
+
+	_, err = s.ShortURL(context.Background(), "http://www.google.com")
+	assert.EqualError(t, err, "bitly is down", "failure")
+	assert.Equal(t, breaker.Open, b.State(), "state")
+
+	_, err = s.ShortURL(context.Background(), "http://www.google.com")
+	assert.EqualError(t, err, "bitly: circuit breaker open", "fail fast")
+	bitly.AssertNumberOfCalls(t, "ShortURL", 2)
+}
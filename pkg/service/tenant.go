@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+// ErrNoTenant returned when the request context does not carry a tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+// TenantService sends sms using the account of the tenant in the request context.
+type TenantService struct {
+	bitly      Shorter
+	httpClient HTTPClient
+	logger     *zap.Logger
+}
+
+// NewTenantService creates a new TenantService.
+func NewTenantService(httpClient HTTPClient, l *zap.Logger, bitly Shorter) *TenantService {
+	return &TenantService{httpClient: httpClient, logger: l, bitly: bitly}
+}
+
+// Format validates and formats the phone number with the tenant's account.
+func (t *TenantService) Format(ctx context.Context, phoneNumber string) (int64, bool, error) {
+	svc, err := t.service(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return svc.Format(ctx, phoneNumber)
+}
+
+// Send transmits the sms with the tenant's account.
+func (t *TenantService) Send(ctx context.Context, phoneNumber int64, text string) (Receipt, error) {
+	svc, err := t.service(ctx)
+	if err != nil {
+		return Receipt{}, err
+	}
+	return svc.Send(ctx, phoneNumber, text)
+}
+
+// service builds a SenderService for the tenant of the request.
+func (t *TenantService) service(ctx context.Context) (*SenderService, error) {
+	tn, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	account := Account{
+		APIKey:      tn.Transmit.APIKey,
+		Secret:      tn.Transmit.Secret,
+		SenderID:    tn.SenderID,
+		CountryCode: tn.CountryCode,
+	}
+	return NewForAccount(account, t.httpClient, t.logger.With(zap.String("tenant_id", tn.ID)), t.bitly), nil
+}
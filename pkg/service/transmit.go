@@ -1,9 +1,23 @@
 package service
 
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TransmitURL is the base URL of the transmit API.
+const TransmitURL = baseURL
+
+// Provider is the name of the transmit provider reported in receipts.
+const Provider = "transmit"
+
 const (
 	baseURL      = "https://api.transmitsms.com"
 	sendSMS      = "/send-sms.json"
 	formatNumber = "/format-number.json"
+
+	defaultCountryCode = "AU"
 )
 
 // Format represent the format API response.
@@ -20,7 +34,8 @@ type Number struct {
 
 // Response represents send sms response.
 type Response struct {
-	Error Error `json:"error"`
+	MessageID int64 `json:"message_id"`
+	Error     Error `json:"error"`
 }
 
 // Error represent transmit API error.
@@ -28,3 +43,98 @@ type Error struct {
 	Code        string `json:"code"`
 	Description string `json:"description"`
 }
+
+// Category groups transmit errors by what callers can do about them.
+type Category string
+
+// Categories of transmit errors.
+const (
+	// CategoryAuth means our transmit credentials are wrong or lack access.
+	CategoryAuth Category = "auth"
+	// CategoryBalance means the transmit account is out of credit.
+	CategoryBalance Category = "balance"
+	// CategoryInvalidRecipient means transmit refused the phone number.
+	CategoryInvalidRecipient Category = "invalid_recipient"
+	// CategoryRateLimited means transmit throttled the account, the call may be retried later.
+	CategoryRateLimited Category = "rate_limited"
+	// CategoryServer means transmit failed, the call may be retried.
+	CategoryServer Category = "server"
+	// CategoryUnknown is any other rejection.
+	CategoryUnknown Category = "unknown"
+)
+
+// categories maps transmit error codes to their category.
+var categories = map[string]Category{
+	"AUTH_FAILED_NO_DATA": CategoryAuth,
+	"AUTH_FAILED":         CategoryAuth,
+	"NO_ACCESS":           CategoryAuth,
+	"LEDGER_ERROR":        CategoryBalance,
+	"RECIPIENTS_ERROR":    CategoryInvalidRecipient,
+	"OVER_LIMIT":          CategoryRateLimited,
+	"INTERNAL_ERROR":      CategoryServer,
+}
+
+// fieldCategories maps the parameters named by FIELD_EMPTY and FIELD_INVALID errors to their
+// category, errors about other parameters are unknown.
+var fieldCategories = map[string]Category{
+	"to":     CategoryInvalidRecipient,
+	"msisdn": CategoryInvalidRecipient,
+}
+
+// ProviderError returned when transmit answers with an error status.
+type ProviderError struct {
+	StatusCode int
+	// Code and Description are the transmit error, empty when the body could not be decoded.
+	Code        string
+	Description string
+}
+
+func (e *ProviderError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("transmit returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("transmit returned status %d: %s %s", e.StatusCode, e.Code, e.Description)
+}
+
+// ProviderCode returns the transmit error code.
+func (e *ProviderError) ProviderCode() string {
+	return e.Code
+}
+
+// Field returns the parameter a FIELD_EMPTY or FIELD_INVALID error is about, which transmit
+// names first in the description, such as "to is invalid".
+func (e *ProviderError) Field() string {
+	if e.Code != "FIELD_EMPTY" && e.Code != "FIELD_INVALID" {
+		return ""
+	}
+	words := strings.Fields(e.Description)
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToLower(strings.Trim(words[0], `"'`))
+}
+
+// Category classifies the error by its transmit code, or by its status when the code is unknown.
+// Field errors are classified by the field that failed.
+func (e *ProviderError) Category() Category {
+	if field := e.Field(); field != "" {
+		if c, ok := fieldCategories[field]; ok {
+			return c
+		}
+		return CategoryUnknown
+	}
+	if c, ok := categories[e.Code]; ok {
+		return c
+	}
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return CategoryAuth
+	case e.StatusCode == http.StatusPaymentRequired:
+		return CategoryBalance
+	case e.StatusCode == http.StatusTooManyRequests:
+		return CategoryRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return CategoryServer
+	}
+	return CategoryUnknown
+}
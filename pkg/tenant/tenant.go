@@ -0,0 +1,155 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikhil-github/sms-app/pkg/history"
+)
+
+// DefaultID is the tenant used by API keys not assigned to a tenant.
+const DefaultID = "default"
+
+// ErrNotFound returned when the tenant does not exist.
+var ErrNotFound = errors.New("tenant not found")
+
+// Tenant represent a business unit with its own provider account.
+type Tenant struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Transmit    Transmit  `json:"transmit"`
+	SenderID    string    `json:"sender_id"`
+	CountryCode string    `json:"country_code"`
+	Limits      Limits    `json:"limits"`
+	Retention   Retention `json:"retention"`
+}
+
+// Transmit represent the tenant's transmit credentials.
+type Transmit struct {
+	APIKey string `json:"api_key"`
+	Secret string `json:"secret"`
+}
+
+// Limits represent the tenant's sending limits. Zero means the service default.
+type Limits struct {
+	MaxTextsPerRequest int `json:"max_texts_per_request"`
+	MessagesPerMinute  int `json:"messages_per_minute"`
+}
+
+// Retention represent how long the tenant's sent messages are kept. Zero means the service default.
+type Retention struct {
+	Days int `json:"days"`
+	// Action is delete or anonymise, which keeps messages without their text and recipient.
+	Action string `json:"action"`
+}
+
+// Validate checks the tenant has what is required to send sms.
+func (t Tenant) Validate() error {
+	if t.ID == "" {
+		return errors.New("tenant id missing")
+	}
+	if t.Transmit.APIKey == "" || t.Transmit.Secret == "" {
+		return errors.Errorf("tenant %s: transmit credentials missing", t.ID)
+	}
+	if t.Limits.MaxTextsPerRequest < 0 || t.Limits.MessagesPerMinute < 0 {
+		return errors.Errorf("tenant %s: limits must not be negative", t.ID)
+	}
+	if t.Retention.Days < 0 {
+		return errors.Errorf("tenant %s: retention days must not be negative", t.ID)
+	}
+	if _, ok := history.ParseAction(t.Retention.Action); t.Retention.Action != "" && !ok {
+		return errors.Errorf("tenant %s: retention action must be delete or anonymise", t.ID)
+	}
+	return nil
+}
+
+// LoadFile reads tenants from a JSON file holding an array of tenants.
+func LoadFile(path string) ([]Tenant, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open tenants file")
+	}
+	defer f.Close()
+	var tenants []Tenant
+	if err := json.NewDecoder(f).Decode(&tenants); err != nil {
+		return nil, errors.Wrap(err, "failed to decode tenants file")
+	}
+	seen := make(map[string]bool)
+	for _, t := range tenants {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[t.ID] {
+			return nil, errors.Errorf("tenant %s defined more than once", t.ID)
+		}
+		seen[t.ID] = true
+	}
+	return tenants, nil
+}
+
+// Store keeps tenants in memory.
+type Store struct {
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+}
+
+// NewStore creates a Store holding the given tenants.
+func NewStore(tenants []Tenant) *Store {
+	s := &Store{tenants: make(map[string]Tenant)}
+	for _, t := range tenants {
+		s.tenants[t.ID] = t
+	}
+	return s
+}
+
+// Replace swaps all tenants at once, such as when the tenants file is reloaded.
+func (s *Store) Replace(tenants []Tenant) {
+	m := make(map[string]Tenant, len(tenants))
+	for _, t := range tenants {
+		m[t.ID] = t
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants = m
+}
+
+// Get returns the tenant by id.
+func (s *Store) Get(ctx context.Context, id string) (Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tenants[id]
+	if !ok {
+		return Tenant{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// List returns all tenants ordered by id.
+func (s *Store) List(ctx context.Context) ([]Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tenants := make([]Tenant, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+type contextKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant.
+func WithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant stored in ctx.
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(Tenant)
+	return t, ok
+}
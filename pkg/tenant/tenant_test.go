@@ -0,0 +1,79 @@
+package tenant_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+func TestLoadFile(t *testing.T) {
+	type args struct {
+		Content string
+	}
+	type want struct {
+		Err     string
+		Tenants []tenant.Tenant
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : tenants",
+			Args: args{Content: `[{"id":"retail","name":"Retail","transmit":{"api_key":"k","secret":"s"},"sender_id":"Retail","country_code":"NZ","limits":{"max_texts_per_request":1,"messages_per_minute":60},"retention":{"days":30,"action":"delete"}}]`},
+			Want: want{Tenants: []tenant.Tenant{{
+				ID:          "retail",
+				Name:        "Retail",
+				Transmit:    tenant.Transmit{APIKey: "k", Secret: "s"},
+				SenderID:    "Retail",
+				CountryCode: "NZ",
+				Limits:      tenant.Limits{MaxTextsPerRequest: 1, MessagesPerMinute: 60},
+				Retention:   tenant.Retention{Days: 30, Action: "delete"},
+			}}},
+		},
+		{
+			Name: "Failure : missing credentials",
+			Args: args{Content: `[{"id":"retail"}]`},
+			Want: want{Err: "tenant retail: transmit credentials missing"},
+		},
+		{
+			Name: "Failure : unknown retention action",
+			Args: args{Content: `[{"id":"retail","transmit":{"api_key":"k","secret":"s"},"retention":{"days":7,"action":"archive"}}]`},
+			Want: want{Err: "tenant retail: retention action must be delete or anonymise"},
+		},
+		{
+			Name: "Failure : duplicate tenant",
+			Args: args{Content: `[{"id":"a","transmit":{"api_key":"k","secret":"s"}},{"id":"a","transmit":{"api_key":"k","secret":"s"}}]`},
+			Want: want{Err: "tenant a defined more than once"},
+		},
+		{
+			Name: "Failure : invalid json",
+			Args: args{Content: `{`},
+			Want: want{Err: "failed to decode tenants file: unexpected EOF"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tenants")
+			require.NoError(t, err, "temp dir")
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "tenants.json")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.Args.Content), 0600), "write file")
+
+			tenants, err := tenant.LoadFile(path)
+			if tt.Want.Err != "" {
+				assert.EqualError(t, err, tt.Want.Err, "error message")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Tenants, tenants, "tenants")
+		})
+	}
+}
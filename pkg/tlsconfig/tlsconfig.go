@@ -0,0 +1,166 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Client certificate modes.
+const (
+	// ClientAuthNone does not ask for client certificates.
+	ClientAuthNone = "none"
+	// ClientAuthOptional verifies client certificates when presented, callers may use API keys instead.
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects connections without a client certificate signed by the client CA.
+	ClientAuthRequire = "require"
+)
+
+// Config represent the TLS settings of the server.
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	ClientAuth   string
+	// CheckInterval is how often the files are checked for changes during handshakes.
+	CheckInterval time.Duration
+}
+
+// Validate checks the settings are consistent.
+func (c Config) Validate() error {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("certificate and key files are both required")
+	}
+	switch c.ClientAuth {
+	case ClientAuthNone:
+		return nil
+	case ClientAuthOptional, ClientAuthRequire:
+		if c.ClientCAFile == "" {
+			return errors.Errorf("client auth %s needs a client CA file", c.ClientAuth)
+		}
+		return nil
+	}
+	return errors.Errorf("unknown client auth %q, use none, optional or require", c.ClientAuth)
+}
+
+// Reloader serves the certificate and client CA read from files, reading them
+// again when they change so renewed certificates are used without a restart.
+type Reloader struct {
+	cfg    Config
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	checked   time.Time
+}
+
+// New reads the files, failing when they are unusable.
+func New(cfg Config, logger *zap.Logger) (*Reloader, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Reloader{cfg: cfg, logger: logger, now: time.Now}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns the server config, each handshake uses the latest certificate and client CA.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.checkFiles()
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			// The config replaces the server's, so it offers HTTP/2 like the server would.
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientCAs:    r.clientCAs,
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			switch r.cfg.ClientAuth {
+			case ClientAuthOptional:
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			case ClientAuthRequire:
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// Reload reads the files, the current certificate is kept when they are unusable.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load tls certificate")
+	}
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read client CA file")
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificate found in client CA file")
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = r.stat()
+	return nil
+}
+
+// checkFiles reloads the files when they changed, at most once per check interval.
+func (r *Reloader) checkFiles() {
+	r.mu.Lock()
+	now := r.now()
+	if now.Sub(r.checked) < r.cfg.CheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = now
+	changed := false
+	for file, mod := range r.stat() {
+		if !mod.Equal(r.modTimes[file]) {
+			changed = true
+		}
+	}
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+	if err := r.Reload(); err != nil {
+		r.logger.Error("Unable to reload tls certificate, keeping the current one", zap.Error(err))
+		return
+	}
+	r.logger.Info("Tls certificate reloaded")
+}
+
+func (r *Reloader) stat() map[string]time.Time {
+	times := map[string]time.Time{}
+	for _, f := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil {
+			times[f] = info.ModTime()
+		}
+	}
+	return times
+}
@@ -0,0 +1,297 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/tlsconfig"
+)
+
+func TestValidate(t *testing.T) {
+	testTable := []struct {
+		Name string
+		Args tlsconfig.Config
+		Want string
+	}{
+		{
+			Name: "Success : server certificate only",
+			Args: tlsconfig.Config{CertFile: "cert.pem", KeyFile: "key.pem", ClientAuth: tlsconfig.ClientAuthNone},
+		},
+		{
+			Name: "Success : client certificates required",
+			Args: tlsconfig.Config{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: "ca.pem", ClientAuth: tlsconfig.ClientAuthRequire},
+		},
+		{
+			Name: "Failure : key missing",
+			Args: tlsconfig.Config{CertFile: "cert.pem", ClientAuth: tlsconfig.ClientAuthNone},
+			Want: "certificate and key files are both required",
+		},
+		{
+			Name: "Failure : client CA missing",
+			Args: tlsconfig.Config{CertFile: "cert.pem", KeyFile: "key.pem", ClientAuth: tlsconfig.ClientAuthOptional},
+			Want: "client auth optional needs a client CA file",
+		},
+		{
+			Name: "Failure : unknown client auth",
+			Args: tlsconfig.Config{CertFile: "cert.pem", KeyFile: "key.pem", ClientAuth: "always"},
+			Want: `unknown client auth "always"`,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Args.Validate()
+			if tt.Want == "" {
+				assert.NoError(t, err, "error")
+				return
+			}
+			require.Error(t, err, "error")
+			assert.Contains(t, err.Error(), tt.Want, "error")
+		})
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	type args struct {
+		ClientAuth string
+		ClientCert bool
+	}
+	type want struct {
+		Err     bool
+		Subject string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : no client certificate asked",
+			Args: args{ClientAuth: tlsconfig.ClientAuthNone, ClientCert: true},
+		},
+		{
+			Name: "Success : optional without certificate",
+			Args: args{ClientAuth: tlsconfig.ClientAuthOptional},
+		},
+		{
+			Name: "Success : optional with certificate",
+			Args: args{ClientAuth: tlsconfig.ClientAuthOptional, ClientCert: true},
+			Want: want{Subject: "CN=billing"},
+		},
+		{
+			Name: "Success : required with certificate",
+			Args: args{ClientAuth: tlsconfig.ClientAuthRequire, ClientCert: true},
+			Want: want{Subject: "CN=billing"},
+		},
+		{
+			Name: "Failure : required without certificate",
+			Args: args{ClientAuth: tlsconfig.ClientAuthRequire},
+			Want: want{Err: true},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			ca := newCA(t, "test-ca")
+			server := ca.issue(t, "localhost")
+			cfg := tlsconfig.Config{
+				CertFile:     filepath.Join(dir, "cert.pem"),
+				KeyFile:      filepath.Join(dir, "key.pem"),
+				ClientAuth:   tt.Args.ClientAuth,
+				ClientCAFile: filepath.Join(dir, "ca.pem"),
+			}
+			if tt.Args.ClientAuth == tlsconfig.ClientAuthNone {
+				cfg.ClientCAFile = ""
+			}
+			server.write(t, cfg.CertFile, cfg.KeyFile)
+			writeFile(t, filepath.Join(dir, "ca.pem"), ca.certPEM)
+			reloader, err := tlsconfig.New(cfg, zap.NewNop())
+			require.NoError(t, err, "new")
+
+			srv := serve(reloader)
+			defer srv.Close()
+			client := &tls.Config{RootCAs: ca.pool()}
+			if tt.Args.ClientCert {
+				client.Certificates = []tls.Certificate{ca.issue(t, "billing").cert}
+			}
+			subject, err := get(srv.URL, client)
+			if tt.Want.Err {
+				assert.Error(t, err, "error")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Subject, subject, "client subject")
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := tlsconfig.Config{
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		ClientAuth: tlsconfig.ClientAuthNone,
+	}
+	first := newCA(t, "first-ca")
+	first.issue(t, "localhost").write(t, cfg.CertFile, cfg.KeyFile)
+	reloader, err := tlsconfig.New(cfg, zap.NewNop())
+	require.NoError(t, err, "new")
+	srv := serve(reloader)
+	defer srv.Close()
+
+	_, err = get(srv.URL, &tls.Config{RootCAs: first.pool()})
+	require.NoError(t, err, "first certificate")
+
+	writeFile(t, cfg.CertFile, []byte("not a certificate"))
+	assert.Error(t, reloader.Reload(), "invalid certificate")
+	_, err = get(srv.URL, &tls.Config{RootCAs: first.pool()})
+	require.NoError(t, err, "certificate kept after failed reload")
+
+	second := newCA(t, "second-ca")
+	renewed := second.issue(t, "localhost")
+	renewed.write(t, cfg.CertFile, cfg.KeyFile)
+	// Modification times may not change within the file system resolution.
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(cfg.CertFile, future, future), "touch")
+
+	_, err = get(srv.URL, &tls.Config{RootCAs: second.pool()})
+	require.NoError(t, err, "renewed certificate picked up on handshake")
+	_, err = get(srv.URL, &tls.Config{RootCAs: first.pool()})
+	assert.Error(t, err, "previous certificate no longer served")
+}
+
+func TestHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	cfg := tlsconfig.Config{
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		ClientAuth: tlsconfig.ClientAuthNone,
+	}
+	ca := newCA(t, "test-ca")
+	ca.issue(t, "localhost").write(t, cfg.CertFile, cfg.KeyFile)
+	reloader, err := tlsconfig.New(cfg, zap.NewNop())
+	require.NoError(t, err, "new")
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.TLS = reloader.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.pool()}, ForceAttemptHTTP2: true}}
+	res, err := client.Get(srv.URL)
+	require.NoError(t, err, "get")
+	res.Body.Close()
+	assert.Equal(t, 2, res.ProtoMajor, "negotiated HTTP/2")
+}
+
+type authority struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+type keyPair struct {
+	cert    tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newCA(t *testing.T, name string) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "ca key")
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err, "ca certificate")
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "parse ca")
+	return &authority{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a certificate usable by servers for localhost and by clients.
+func (a *authority) issue(t *testing.T, name string) keyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "key")
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err, "certificate")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "marshal key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err, "key pair")
+	return keyPair{cert: cert, certPEM: certPEM, keyPEM: keyPEM}
+}
+
+func (a *authority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.cert)
+	return pool
+}
+
+func (k keyPair) write(t *testing.T, certFile string, keyFile string) {
+	writeFile(t, certFile, k.certPEM)
+	writeFile(t, keyFile, k.keyPEM)
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	require.NoError(t, ioutil.WriteFile(path, data, 0600), "write %s", path)
+}
+
+// serve starts a server answering with the subject of the verified client certificate.
+func serve(reloader *tlsconfig.Reloader) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.String()))
+		}
+	}))
+	srv.TLS = reloader.TLSConfig()
+	srv.StartTLS()
+	return srv
+}
+
+// get calls the server on a new connection so each call makes a handshake.
+func get(url string, cfg *tls.Config) (string, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+	res, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	return string(body), err
+}
@@ -0,0 +1,93 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tracing"
+)
+
+type sender struct {
+	err error
+}
+
+func (s sender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
+	return service.Receipt{Provider: service.Provider}, s.err
+}
+
+func setup(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	_, err := tracing.Setup(context.Background(), tracing.Config{})
+	require.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func TestSender(t *testing.T) {
+	type Args struct {
+		Err error
+	}
+	type Want struct {
+		Code codes.Code
+	}
+	tests := []struct {
+		Name string
+		Args Args
+		Want Want
+	}{
+		{
+			Name: "successful send",
+			Want: Want{Code: codes.Unset},
+		},
+		{
+			Name: "failed send",
+			Args: Args{Err: errors.New("boom")},
+			Want: Want{Code: codes.Error},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			recorder := setup(t)
+			_, err := tracing.Sender(sender{err: test.Args.Err}, "transmit").Send(context.Background(), 61411111111, "hello")
+			assert.Equal(t, test.Args.Err, err)
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "Sender.Send", spans[0].Name())
+			assert.Equal(t, test.Want.Code, spans[0].Status().Code)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	setup(t)
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var got trace.SpanContext
+	h := tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	}))
+	req := httptest.NewRequest("POST", "/api/v1/sms/send", nil)
+	req.Header.Set("traceparent", parent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceID().String())
+	assert.True(t, got.IsRemote())
+
+	out := http.Header{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), got), propagation.HeaderCarrier(out))
+	assert.Equal(t, parent, out.Get("traceparent"))
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/service"
+)
+
+const instrumentation = "github.com/nikhil-github/sms-app/pkg/tracing"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// end records err on the span and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type formatter struct {
+	next     handler.Formatter
+	provider string
+}
+
+// Formatter traces Format calls.
+func Formatter(next handler.Formatter, provider string) handler.Formatter {
+	return &formatter{next: next, provider: provider}
+}
+
+func (f *formatter) Format(ctx context.Context, phoneNumber string) (int64, bool, error) {
+	ctx, span := tracer().Start(ctx, "Formatter.Format", trace.WithAttributes(attribute.String("sms.provider", f.provider)))
+	number, valid, err := f.next.Format(ctx, phoneNumber)
+	span.SetAttributes(attribute.Bool("sms.number_valid", valid))
+	end(span, err)
+	return number, valid, err
+}
+
+type sender struct {
+	next     handler.Sender
+	provider string
+}
+
+// Sender traces Send calls.
+func Sender(next handler.Sender, provider string) handler.Sender {
+	return &sender{next: next, provider: provider}
+}
+
+func (s *sender) Send(ctx context.Context, phoneNumber int64, text string) (service.Receipt, error) {
+	ctx, span := tracer().Start(ctx, "Sender.Send", trace.WithAttributes(
+		attribute.String("sms.provider", s.provider),
+		attribute.Int("sms.text_length", len(text)),
+	))
+	receipt, err := s.next.Send(ctx, phoneNumber, text)
+	span.SetAttributes(attribute.Int("sms.links", len(receipt.Links)))
+	end(span, err)
+	return receipt, err
+}
+
+type shorter struct {
+	next     service.Shorter
+	provider string
+}
+
+// Shorter traces ShortURL calls.
+func Shorter(next service.Shorter, provider string) service.Shorter {
+	return &shorter{next: next, provider: provider}
+}
+
+func (s *shorter) ShortURL(ctx context.Context, longURL string) (string, error) {
+	ctx, span := tracer().Start(ctx, "Shorter.ShortURL", trace.WithAttributes(attribute.String("sms.provider", s.provider)))
+	short, err := s.next.ShortURL(ctx, longURL)
+	end(span, err)
+	return short, err
+}
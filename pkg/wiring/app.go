@@ -1,53 +1,91 @@
 package wiring
 
 import (
+	"crypto/rand"
 	"log"
+	"os"
+	"time"
 
 	"github.com/joho/godotenv"
-	"github.com/vrischmann/envconfig"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/redact"
 )
 
-// App embeds config.
+// App embeds config and the build version.
 type App struct {
-	Config *Config
+	Config    *Config
+	Version   string
+	GitCommit string
 }
 
 // Run runs the app.
+// Settings are read from the environment, then .env, then the YAML or TOML file named by CONFIG_FILE.
 func (a App) Run() {
-	cfg := a.Config
 	err := godotenv.Load()
 	if err == nil {
 		log.Println("Loaded .env file")
 	}
 
-	err = envconfig.Init(&cfg)
+	loader := &configLoader{path: os.Getenv("CONFIG_FILE")}
+	cfg, err := loader.Load()
 	if err != nil {
-		log.Fatal("Error loading config", err)
+		log.Fatalf("Invalid config: %s", err)
 	}
+	a.Config = cfg
 
-	logger, err := configureLogger(cfg.LOG.Level)
+	logger, level, err := configureLogger(cfg.LOG.Level, cfg.LOG.Encoding, cfg.redactPolicy())
 	if err != nil {
 		log.Fatalf("Failed to create zap logger: %s", err.Error())
 	}
 
-	if err := Start(cfg, logger); err != nil {
+	build := handler.Build{Version: a.Version, GitCommit: a.GitCommit, StartedAt: time.Now().UTC()}
+	err = Start(cfg, loader, logger, level, build)
+	if shutdownErr, ok := err.(*ShutdownError); ok {
+		logger.Error("Shutdown incomplete", zap.Error(shutdownErr.Err))
+		logger.Sync()
+		os.Exit(1)
+	}
+	if err != nil {
 		logger.Fatal("Failed to start server", zap.Error(err))
 	}
+	logger.Sync()
 }
 
-func configureLogger(logLevel string) (*zap.Logger, error) {
+// parseLevel returns the zap level named by LOG_LEVEL, such as DEBUG or warn.
+func parseLevel(logLevel string) (zapcore.Level, error) {
 	var level zapcore.Level
-	if logLevel == "INFO" {
-		level = zapcore.InfoLevel
-	} else {
-		level = zapcore.ErrorLevel
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return level, errors.Errorf("unknown log level %q", logLevel)
+	}
+	return level, nil
+}
+
+// configureLogger builds a json or console logger, phone numbers, message texts and URLs are redacted according to policy.
+// The returned level changes the level of the running logger.
+func configureLogger(logLevel string, encoding string, policy redact.Policy) (*zap.Logger, zap.AtomicLevel, error) {
+	l, err := parseLevel(logLevel)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+	level := zap.NewAtomicLevelAt(l)
+	if err := policy.Validate(); err != nil {
+		return nil, level, errors.Wrap(err, "invalid redaction policy")
+	}
+	randomKey := policy.Body == redact.BodyHash && len(policy.Key) == 0
+	if randomKey {
+		policy.Key = make([]byte, 32)
+		if _, err := rand.Read(policy.Key); err != nil {
+			return nil, level, errors.Wrap(err, "failed to generate redaction key")
+		}
 	}
 
 	cfg := zap.Config{
-		Encoding:         "json",
-		Level:            zap.NewAtomicLevelAt(level),
+		Encoding:         encoding,
+		Level:            level,
 		OutputPaths:      []string{"stdout"},
 		ErrorOutputPaths: []string{"stderr"},
 		EncoderConfig: zapcore.EncoderConfig{
@@ -60,10 +98,15 @@ func configureLogger(logLevel string) (*zap.Logger, error) {
 			EncodeCaller: zapcore.ShortCallerEncoder,
 		},
 	}
-	logger, err := cfg.Build()
+	logger, err := cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redact.Core(core, policy)
+	}))
 	if err != nil {
 		log.Fatalf("Unable to build zap logger: %s", err.Error())
 	}
-	logger.Info("info logging enabled")
-	return logger, nil
+	logger.Info("Logging enabled", zap.String("level", l.String()), zap.String("encoding", encoding))
+	if randomKey {
+		logger.Warn("REDACT_KEY is not set, hashed texts in logs cannot be matched across restarts")
+	}
+	return logger, level, nil
 }
@@ -1,18 +1,286 @@
 package wiring
 
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/redact"
+	"github.com/nikhil-github/sms-app/pkg/tlsconfig"
+)
+
 // Config wraps app configs.
 type Config struct {
 	HTTP struct {
 		Port int `envconfig:"default=3001"`
 	}
+	HEALTH struct {
+		// Port serves /healthz and /readyz in plain HTTP for probes, also when TLS is enabled. 0 disables it.
+		Port int `envconfig:"default=3003"`
+	}
+	GRPC struct {
+		// Port serves the gRPC API, with the TLS settings of the HTTP server.
+		Port int `envconfig:"default=3002"`
+		// Statuses is the number of message statuses kept for GetMessageStatus and WatchMessageStatus.
+		Statuses int `envconfig:"default=10000"`
+	}
+	TLS struct {
+		// CertFile and KeyFile enable HTTPS, the server listens on plain HTTP when both are empty.
+		CertFile string `envconfig:"optional"`
+		KeyFile  string `envconfig:"optional"`
+		// ClientCAFile verifies client certificates, ClientAuth is none, optional or require.
+		ClientCAFile string `envconfig:"optional"`
+		ClientAuth   string `envconfig:"default=none"`
+		// IdentityFile is a JSON file mapping client certificate subjects to names, tenants and scopes.
+		IdentityFile string `envconfig:"optional"`
+		// CheckInterval is how often the certificate files are checked for renewal.
+		CheckInterval time.Duration `envconfig:"default=1m"`
+	}
+	CORS struct {
+		// Origins may call the send API from browsers, separated by commas or spaces, * allows any origin.
+		Origins     string        `envconfig:"default=http://localhost:3000"`
+		Methods     string        `envconfig:"default=GET POST"`
+		Headers     string        `envconfig:"default=Accept Authorization Content-Type X-API-Key X-Request-ID"`
+		Credentials bool          `envconfig:"default=false"`
+		MaxAge      time.Duration `envconfig:"default=10m"`
+		// AdminOrigins may call the admin API from browsers, browsers cannot read its responses when empty.
+		AdminOrigins string `envconfig:"optional"`
+	}
 	LOG struct {
-		Level string
+		// Level is debug, info, warn, error, dpanic, panic or fatal, in any case.
+		Level string `envconfig:"default=INFO"`
+		// Encoding is json or console.
+		Encoding string `envconfig:"default=json"`
+	}
+	REDACT struct {
+		// PhoneDigits is the number of trailing digits of phone numbers kept in logs.
+		PhoneDigits int `envconfig:"default=4"`
+		// Body is hash, drop or keep for message texts in logs.
+		Body string `envconfig:"default=hash"`
+		// StripQuery removes query strings from logged URLs.
+		StripQuery bool `envconfig:"default=true"`
+		// Key is the HMAC key of hashed texts, a random key is used until restart when empty.
+		Key string `envconfig:"optional"`
 	}
 	BITLY struct {
-		Token string
+		Token string `envconfig:"optional"`
 	}
 	TRANSMIT struct {
-		Apikey string
-		Secret string
+		// Apikey and Secret are the default tenant's credentials, required unless TENANT.File is set.
+		Apikey string `envconfig:"optional"`
+		Secret string `envconfig:"optional"`
+	}
+	WEBHOOK struct {
+		// URL is the base URL transmit reaches the app at, such as https://sms.example.com. When set, sends
+		// ask transmit for delivery receipts and replies, published as message events.
+		URL string `envconfig:"optional"`
+		// Secret signs the callback URLs, required with URL.
+		Secret string `envconfig:"optional"`
+	}
+	TENANT struct {
+		// File is a JSON file listing tenants and their provider credentials.
+		File string `envconfig:"optional"`
+	}
+	RATELIMIT struct {
+		// Limits are count/period such as 60/m, 0 disables the limit.
+		Global    string `envconfig:"default=600/m"`
+		Key       string `envconfig:"default=60/m"`
+		Recipient string `envconfig:"default=5/m"`
+		// RedisURL shares buckets between instances, buckets are kept in memory when empty.
+		RedisURL string `envconfig:"optional"`
+	}
+	OUTBOUND struct {
+		// Transmit and Sender pace calls to transmit overall and per sender number, such as 10/s.
+		Transmit string `envconfig:"optional"`
+		Sender   string `envconfig:"optional"`
+		// MaxDelay is the longest a call waits for a slot before failing.
+		MaxDelay time.Duration `envconfig:"default=5s"`
+	}
+	BREAKER struct {
+		// Failures is the number of consecutive failures opening the breakers.
+		Failures int `envconfig:"default=5"`
+		// Cooldown is how long an open breaker fails fast before probing.
+		Cooldown time.Duration `envconfig:"default=30s"`
+	}
+	RELOAD struct {
+		// WatchInterval polls the config, secret and tenants files for changes, 0 reloads on SIGHUP only.
+		WatchInterval time.Duration `envconfig:"default=0s"`
+	}
+	EVENTS struct {
+		// Buffer is the number of message events kept for clients resuming with Last-Event-ID.
+		Buffer int `envconfig:"default=1000"`
+	}
+	SHUTDOWN struct {
+		// Timeout is how long in-flight requests are given to finish on SIGTERM or SIGINT.
+		Timeout time.Duration `envconfig:"default=20s"`
+	}
+	READY struct {
+		// CheckProviders adds transmit and bitly reachability to the readiness checks.
+		CheckProviders bool          `envconfig:"default=false"`
+		CacheTTL       time.Duration `envconfig:"default=30s"`
+	}
+	TRACING struct {
+		// Endpoint is the OTLP/HTTP collector such as otel-collector:4318, spans are not exported when empty.
+		Endpoint string `envconfig:"optional"`
+		Insecure bool   `envconfig:"default=false"`
+		// SampleRatio is the share of new traces sampled, incoming sampled traces are always kept.
+		SampleRatio float64 `envconfig:"default=1"`
+		ServiceName string  `envconfig:"default=sms-app"`
+	}
+	AUDIT struct {
+		// File is the append only JSON lines audit log, entries are kept in memory when empty.
+		File string `envconfig:"optional"`
+		// Key is the HMAC key of the hash chain and of pseudonyms, required with File.
+		Key string `envconfig:"optional"`
+	}
+	HISTORY struct {
+		// File is the JSON lines history of sent messages, messages are kept in memory when empty.
+		File string `envconfig:"optional"`
+	}
+	RETENTION struct {
+		// Days is how long sent messages are kept for tenants without their own retention.
+		Days int `envconfig:"default=30"`
+		// Action is delete or anonymise, which keeps messages without their text and recipient.
+		Action string `envconfig:"default=anonymise"`
+		// AuditDays is how long audit entries are kept.
+		AuditDays int `envconfig:"default=365"`
+		// Interval is how often messages and audit entries past their retention are purged.
+		Interval time.Duration `envconfig:"default=1h"`
+	}
+	AUTH struct {
+		// BootstrapKey is an admin token registered at startup to issue further keys.
+		BootstrapKey string `envconfig:"optional"`
+		// KeysFile is the JSON file of API keys, keys are kept in memory when empty.
+		KeysFile string `envconfig:"optional"`
+	}
+}
+
+// Validate checks the config is usable.
+func (c *Config) Validate() error {
+	if c.BITLY.Token == "" {
+		return errors.New("BITLY_TOKEN is missing: set BITLY_TOKEN or BITLY_TOKEN_FILE")
+	}
+	if c.HEALTH.Port > 0 && (c.HEALTH.Port == c.HTTP.Port || c.HEALTH.Port == c.GRPC.Port) {
+		return errors.Errorf("HEALTH_PORT %d is already used by HTTP_PORT or GRPC_PORT", c.HEALTH.Port)
+	}
+	if c.TRANSMIT.Apikey == "" && c.TRANSMIT.Secret == "" && c.TENANT.File == "" {
+		return errors.New("TRANSMIT_APIKEY is missing: set TRANSMIT_APIKEY and TRANSMIT_SECRET (or their _FILE variants), or TENANT_FILE")
+	}
+	if c.TRANSMIT.Apikey == "" && c.TRANSMIT.Secret != "" {
+		return errors.New("TRANSMIT_APIKEY is missing: TRANSMIT_SECRET is set without it")
+	}
+	if c.TRANSMIT.Apikey != "" && c.TRANSMIT.Secret == "" {
+		return errors.New("TRANSMIT_SECRET is missing: TRANSMIT_APIKEY is set without it")
+	}
+	for name, limit := range map[string]string{
+		"RATELIMIT_GLOBAL":    c.RATELIMIT.Global,
+		"RATELIMIT_KEY":       c.RATELIMIT.Key,
+		"RATELIMIT_RECIPIENT": c.RATELIMIT.Recipient,
+		"OUTBOUND_TRANSMIT":   c.OUTBOUND.Transmit,
+		"OUTBOUND_SENDER":     c.OUTBOUND.Sender,
+	} {
+		if _, err := ratelimit.ParseLimit(limit); err != nil {
+			return errors.Wrap(err, name)
+		}
 	}
+	if _, err := parseLevel(c.LOG.Level); err != nil {
+		return errors.Wrap(err, "LOG_LEVEL")
+	}
+	if c.LOG.Encoding != "json" && c.LOG.Encoding != "console" {
+		return errors.Errorf("LOG_ENCODING must be json or console, got %q", c.LOG.Encoding)
+	}
+	if err := c.redactPolicy().Validate(); err != nil {
+		return errors.Wrap(err, "REDACT")
+	}
+	if c.TLS.CertFile != "" || c.TLS.KeyFile != "" {
+		if err := c.tlsConfig().Validate(); err != nil {
+			return errors.Wrap(err, "TLS")
+		}
+	}
+	if c.TLS.IdentityFile != "" && c.TLS.ClientAuth == tlsconfig.ClientAuthNone {
+		return errors.New("TLS_IDENTITYFILE needs TLS_CLIENTAUTH optional or require")
+	}
+	if err := c.corsPolicy().Validate(); err != nil {
+		return errors.Wrap(err, "CORS")
+	}
+	if err := c.adminCORSPolicy().Validate(); err != nil {
+		return errors.Wrap(err, "CORS_ADMINORIGINS")
+	}
+	if c.BREAKER.Failures < 1 {
+		return errors.New("BREAKER_FAILURES must be at least 1")
+	}
+	if c.TRACING.SampleRatio < 0 || c.TRACING.SampleRatio > 1 {
+		return errors.New("TRACING_SAMPLERATIO must be between 0 and 1")
+	}
+	if c.SHUTDOWN.Timeout <= 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.WEBHOOK.URL != "" && c.WEBHOOK.Secret == "" {
+		return errors.New("WEBHOOK_SECRET is missing: callback URLs are signed when WEBHOOK_URL is set")
+	}
+	if c.AUDIT.File != "" && c.AUDIT.Key == "" {
+		return errors.New("AUDIT_KEY is missing: the audit log needs a key when AUDIT_FILE is set")
+	}
+	if c.RETENTION.Days < 1 {
+		return errors.New("RETENTION_DAYS must be at least 1")
+	}
+	if _, ok := history.ParseAction(c.RETENTION.Action); !ok {
+		return errors.Errorf("RETENTION_ACTION must be delete or anonymise, got %q", c.RETENTION.Action)
+	}
+	if c.RETENTION.AuditDays < 1 {
+		return errors.New("RETENTION_AUDITDAYS must be at least 1")
+	}
+	if c.RETENTION.Interval <= 0 {
+		return errors.New("RETENTION_INTERVAL must be positive")
+	}
+	return nil
+}
+
+// exposedHeaders are the response headers of the app readable by browser scripts.
+var exposedHeaders = []string{handler.RequestIDHeader, "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"}
+
+// corsPolicy returns the CORS policy of the send API.
+func (c *Config) corsPolicy() handler.CORSPolicy {
+	return handler.CORSPolicy{
+		Origins:     fields(c.CORS.Origins),
+		Methods:     fields(c.CORS.Methods),
+		Headers:     fields(c.CORS.Headers),
+		Expose:      exposedHeaders,
+		Credentials: c.CORS.Credentials,
+		MaxAge:      c.CORS.MaxAge,
+	}
+}
+
+// adminCORSPolicy returns the CORS policy of the admin API, sharing the headers of the send API.
+func (c *Config) adminCORSPolicy() handler.CORSPolicy {
+	policy := c.corsPolicy()
+	policy.Origins = fields(c.CORS.AdminOrigins)
+	policy.Methods = []string{"GET", "POST", "PUT", "DELETE"}
+	return policy
+}
+
+// fields splits a list separated by commas or spaces.
+func fields(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
+
+// tlsConfig returns the TLS settings of the server.
+func (c *Config) tlsConfig() tlsconfig.Config {
+	return tlsconfig.Config{
+		CertFile:      c.TLS.CertFile,
+		KeyFile:       c.TLS.KeyFile,
+		ClientCAFile:  c.TLS.ClientCAFile,
+		ClientAuth:    c.TLS.ClientAuth,
+		CheckInterval: c.TLS.CheckInterval,
+	}
+}
+
+// redactPolicy returns the redaction applied to log fields.
+func (c *Config) redactPolicy() redact.Policy {
+	return redact.Policy{PhoneDigits: c.REDACT.PhoneDigits, Body: c.REDACT.Body, StripQuery: c.REDACT.StripQuery, Key: []byte(c.REDACT.Key)}
 }
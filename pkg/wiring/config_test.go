@@ -0,0 +1,267 @@
+package wiring
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	type args struct {
+		File    string
+		Content string
+		Env     map[string]string
+		DotEnv  string
+		Secrets map[string]string
+	}
+	type want struct {
+		Env map[string]string
+		Err string
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success - yaml file",
+			Args: args{File: "config.yaml", Content: "http:\n  port: 4000\ntransmit:\n  apikey: file-key\nratelimit:\n  key: 10/m\n"},
+			Want: want{Env: map[string]string{"HTTP_PORT": "4000", "TRANSMIT_APIKEY": "file-key", "RATELIMIT_KEY": "10/m"}},
+		},
+		{
+			Name: "Success - toml file",
+			Args: args{File: "config.toml", Content: "[http]\nport = 4000\n\n[breaker]\ncooldown = \"1m\"\n"},
+			Want: want{Env: map[string]string{"HTTP_PORT": "4000", "BREAKER_COOLDOWN": "1m"}},
+		},
+		{
+			Name: "Success - environment overrides file",
+			Args: args{File: "config.yaml", Content: "http:\n  port: 4000\n", Env: map[string]string{"HTTP_PORT": "5000"}},
+			Want: want{Env: map[string]string{"HTTP_PORT": "5000"}},
+		},
+		{
+			Name: "Success - secret file from environment",
+			Args: args{Env: map[string]string{"BITLY_TOKEN_FILE": "{dir}/bitly"}, Secrets: map[string]string{"bitly": "token\n"}},
+			Want: want{Env: map[string]string{"BITLY_TOKEN": "token"}},
+		},
+		{
+			Name: "Success - secret file from config file",
+			Args: args{File: "config.yaml", Content: "transmit:\n  secret_file: {dir}/transmit\n", Secrets: map[string]string{"transmit": "secret"}},
+			Want: want{Env: map[string]string{"TRANSMIT_SECRET": "secret"}},
+		},
+		{
+			Name: "Success - environment secret file overrides config file",
+			Args: args{File: "config.yaml", Content: "bitly:\n  token: file-token\n", Env: map[string]string{"BITLY_TOKEN_FILE": "{dir}/bitly"}, Secrets: map[string]string{"bitly": "env-token"}},
+			Want: want{Env: map[string]string{"BITLY_TOKEN": "env-token"}},
+		},
+		{
+			Name: "Success - empty .env values count as unset",
+			Args: args{
+				File:    "config.yaml",
+				Content: "http:\n  port: 4000\ntransmit:\n  apikey: file-key\n",
+				DotEnv:  "HTTP_PORT=\nTRANSMIT_APIKEY=\nBITLY_TOKEN=\nBITLY_TOKEN_FILE={dir}/bitly\n",
+				Secrets: map[string]string{"bitly": "token"},
+			},
+			Want: want{Env: map[string]string{"HTTP_PORT": "4000", "TRANSMIT_APIKEY": "file-key", "BITLY_TOKEN": "token"}},
+		},
+		{
+			Name: "Failure - secret and secret file",
+			Args: args{Env: map[string]string{"BITLY_TOKEN": "token", "BITLY_TOKEN_FILE": "{dir}/bitly"}, Secrets: map[string]string{"bitly": "token"}},
+			Want: want{Err: "BITLY_TOKEN and BITLY_TOKEN_FILE are both set in environment, set only one"},
+		},
+		{
+			Name: "Failure - unsupported format",
+			Args: args{File: "config.json", Content: "{}"},
+			Want: want{Err: "unsupported config file"},
+		},
+		{
+			Name: "Failure - list value",
+			Args: args{File: "config.yaml", Content: "http:\n  port: [1, 2]\n"},
+			Want: want{Err: "unsupported value for HTTP_PORT"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"HTTP_PORT", "TRANSMIT_APIKEY", "TRANSMIT_SECRET", "TRANSMIT_SECRET_FILE", "RATELIMIT_KEY", "BREAKER_COOLDOWN", "BITLY_TOKEN", "BITLY_TOKEN_FILE"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for name, content := range tt.Args.Secrets {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600), "write secret")
+			}
+			for name, value := range tt.Args.Env {
+				os.Setenv(name, strings.Replace(value, "{dir}", dir, 1))
+			}
+			if tt.Args.DotEnv != "" {
+				dotEnv := filepath.Join(dir, ".env")
+				require.NoError(t, os.WriteFile(dotEnv, []byte(strings.Replace(tt.Args.DotEnv, "{dir}", dir, 1)), 0600), "write .env")
+				require.NoError(t, godotenv.Load(dotEnv), "load .env")
+			}
+			path := ""
+			if tt.Args.File != "" {
+				path = filepath.Join(dir, tt.Args.File)
+				content := strings.Replace(tt.Args.Content, "{dir}", dir, 1)
+				require.NoError(t, os.WriteFile(path, []byte(content), 0600), "write config")
+			}
+
+			_, _, err := loadConfig(path)
+			if tt.Want.Err != "" {
+				require.Error(t, err, "error")
+				assert.Contains(t, err.Error(), tt.Want.Err, "error message")
+				return
+			}
+			require.NoError(t, err, "load config")
+			for name, value := range tt.Want.Env {
+				assert.Equal(t, value, os.Getenv(name), name)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := &Config{}
+		cfg.LOG.Encoding = "json"
+		cfg.TLS.ClientAuth = "none"
+		cfg.BITLY.Token = "token"
+		cfg.TRANSMIT.Apikey = "key"
+		cfg.TRANSMIT.Secret = "secret"
+		cfg.RATELIMIT.Global = "600/m"
+		cfg.REDACT.Body = "hash"
+		cfg.BREAKER.Failures = 5
+		cfg.SHUTDOWN.Timeout = 1
+		cfg.RETENTION.Days = 30
+		cfg.RETENTION.Action = "anonymise"
+		cfg.RETENTION.AuditDays = 365
+		cfg.RETENTION.Interval = 1
+		return cfg
+	}
+	testTable := []struct {
+		Name   string
+		Config func(c *Config)
+		Err    string
+	}{
+		{
+			Name:   "Success - valid config",
+			Config: func(c *Config) {},
+		},
+		{
+			Name:   "Success - tenants file without default tenant",
+			Config: func(c *Config) { c.TRANSMIT.Apikey, c.TRANSMIT.Secret, c.TENANT.File = "", "", "tenants.json" },
+		},
+		{
+			Name:   "Success - lowercase log level",
+			Config: func(c *Config) { c.LOG.Level = "debug" },
+		},
+		{
+			Name:   "Failure - unknown log level",
+			Config: func(c *Config) { c.LOG.Level = "VERBOSE" },
+			Err:    `LOG_LEVEL: unknown log level "VERBOSE"`,
+		},
+		{
+			Name:   "Failure - unknown log encoding",
+			Config: func(c *Config) { c.LOG.Encoding = "text" },
+			Err:    `LOG_ENCODING must be json or console, got "text"`,
+		},
+		{
+			Name: "Success - client certificates",
+			Config: func(c *Config) {
+				c.TLS.CertFile, c.TLS.KeyFile, c.TLS.ClientCAFile, c.TLS.ClientAuth = "cert.pem", "key.pem", "ca.pem", "optional"
+				c.TLS.IdentityFile = "identities.json"
+			},
+		},
+		{
+			Name:   "Success - cors origins separated by commas and spaces",
+			Config: func(c *Config) { c.CORS.Origins = "https://a.example.com, https://b.example.com http://localhost:3000" },
+		},
+		{
+			Name:   "Failure - cors origin without scheme",
+			Config: func(c *Config) { c.CORS.Origins = "localhost:3000" },
+			Err:    `CORS: origin "localhost:3000" must be * or start with http:// or https://`,
+		},
+		{
+			Name:   "Failure - cors any admin origin with credentials",
+			Config: func(c *Config) { c.CORS.AdminOrigins, c.CORS.Credentials = "*", true },
+			Err:    "CORS_ADMINORIGINS: any origin cannot be allowed with credentials",
+		},
+		{
+			Name:   "Failure - tls key missing",
+			Config: func(c *Config) { c.TLS.CertFile = "cert.pem" },
+			Err:    "TLS: certificate and key files are both required",
+		},
+		{
+			Name: "Failure - identities without client certificates",
+			Config: func(c *Config) {
+				c.TLS.CertFile, c.TLS.KeyFile, c.TLS.IdentityFile = "cert.pem", "key.pem", "identities.json"
+			},
+			Err: "TLS_IDENTITYFILE needs TLS_CLIENTAUTH optional or require",
+		},
+		{
+			Name:   "Failure - health port shared with http",
+			Config: func(c *Config) { c.HTTP.Port, c.HEALTH.Port = 3001, 3001 },
+			Err:    "HEALTH_PORT 3001 is already used by HTTP_PORT or GRPC_PORT",
+		},
+		{
+			Name:   "Failure - audit file without key",
+			Config: func(c *Config) { c.AUDIT.File = "audit.jsonl" },
+			Err:    "AUDIT_KEY is missing: the audit log needs a key when AUDIT_FILE is set",
+		},
+		{
+			Name:   "Failure - webhook url without secret",
+			Config: func(c *Config) { c.WEBHOOK.URL = "https://sms.example.com" },
+			Err:    "WEBHOOK_SECRET is missing: callback URLs are signed when WEBHOOK_URL is set",
+		},
+		{
+			Name:   "Failure - retention days missing",
+			Config: func(c *Config) { c.RETENTION.Days = 0 },
+			Err:    "RETENTION_DAYS must be at least 1",
+		},
+		{
+			Name:   "Failure - audit retention days missing",
+			Config: func(c *Config) { c.RETENTION.AuditDays = 0 },
+			Err:    "RETENTION_AUDITDAYS must be at least 1",
+		},
+		{
+			Name:   "Failure - unknown retention action",
+			Config: func(c *Config) { c.RETENTION.Action = "archive" },
+			Err:    `RETENTION_ACTION must be delete or anonymise, got "archive"`,
+		},
+		{
+			Name:   "Failure - bitly token missing",
+			Config: func(c *Config) { c.BITLY.Token = "" },
+			Err:    "BITLY_TOKEN is missing: set BITLY_TOKEN or BITLY_TOKEN_FILE",
+		},
+		{
+			Name:   "Failure - transmit credentials missing",
+			Config: func(c *Config) { c.TRANSMIT.Apikey, c.TRANSMIT.Secret = "", "" },
+			Err:    "TRANSMIT_APIKEY is missing: set TRANSMIT_APIKEY and TRANSMIT_SECRET (or their _FILE variants), or TENANT_FILE",
+		},
+		{
+			Name:   "Failure - transmit api key missing",
+			Config: func(c *Config) { c.TRANSMIT.Apikey = "" },
+			Err:    "TRANSMIT_APIKEY is missing: TRANSMIT_SECRET is set without it",
+		},
+		{
+			Name:   "Failure - transmit secret missing",
+			Config: func(c *Config) { c.TRANSMIT.Secret = "" },
+			Err:    "TRANSMIT_SECRET is missing: TRANSMIT_APIKEY is set without it",
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			cfg := valid()
+			tt.Config(cfg)
+			err := cfg.Validate()
+			if tt.Err != "" {
+				assert.EqualError(t, err, tt.Err, "error")
+				return
+			}
+			assert.NoError(t, err, "validate")
+		})
+	}
+}
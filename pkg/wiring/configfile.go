@@ -0,0 +1,205 @@
+package wiring
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"github.com/vrischmann/envconfig"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// secretVars can be read from the file named by the same variable suffixed with _FILE,
+// such as a Docker or Kubernetes secret mounted at TRANSMIT_SECRET_FILE.
+var secretVars = []string{"TRANSMIT_APIKEY", "TRANSMIT_SECRET", "BITLY_TOKEN", "AUTH_BOOTSTRAPKEY", "RATELIMIT_REDISURL", "REDACT_KEY", "AUDIT_KEY", "WEBHOOK_SECRET"}
+
+// Loader reads the config, it is called again when the config is reloaded.
+type Loader interface {
+	Load() (*Config, error)
+	// Files returns the files the config was read from.
+	Files() []string
+}
+
+// configLoader reads the config from the environment, the config file and the secret files.
+// Variables it set are cleared before reading again so changed files are picked up.
+type configLoader struct {
+	path string
+
+	mu    sync.Mutex
+	set   []string
+	files []string
+}
+
+// Load reads and validates the config.
+func (l *configLoader) Load() (*Config, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, name := range l.set {
+		os.Unsetenv(name)
+	}
+	l.set = nil
+	set, files, err := loadConfig(l.path)
+	l.set = set
+	if err != nil {
+		return nil, err
+	}
+	var cfg *Config
+	if err := envconfig.Init(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.TENANT.File != "" {
+		files = append(files, cfg.TENANT.File)
+	}
+	if cfg.TLS.IdentityFile != "" {
+		files = append(files, cfg.TLS.IdentityFile)
+	}
+	l.files = files
+	return cfg, nil
+}
+
+// Files returns the config file, the secret files, the tenants file and the client identities file.
+func (l *configLoader) Files() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.files...)
+}
+
+// loadConfig sets the environment from the config file and the secret files.
+// Environment variables take precedence over the config file, empty ones count as
+// unset. It returns the variables it set and the files it read.
+func loadConfig(path string) ([]string, []string, error) {
+	var set, files []string
+	env := environ()
+	secrets, err := resolveSecrets(env, "environment")
+	if err != nil {
+		return nil, nil, err
+	}
+	files = append(files, secrets...)
+	file := map[string]string{}
+	if path != "" {
+		if file, err = readConfigFile(path); err != nil {
+			return nil, nil, err
+		}
+		if secrets, err = resolveSecrets(file, path); err != nil {
+			return nil, nil, err
+		}
+		files = append(append(files, path), secrets...)
+	}
+	for _, layer := range []map[string]string{env, file} {
+		for name, value := range layer {
+			if os.Getenv(name) != "" {
+				continue
+			}
+			if err := os.Setenv(name, value); err != nil {
+				return set, nil, errors.Wrapf(err, "failed to set %s", name)
+			}
+			set = append(set, name)
+		}
+	}
+	return set, files, nil
+}
+
+// readConfigFile reads a YAML or TOML file into environment variable names,
+// nested keys are joined with underscores so transmit.apikey sets TRANSMIT_APIKEY.
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw map[interface{}]interface{}
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return nil, errors.Wrapf(err, "invalid config file %s", path)
+		}
+		doc = stringKeys(raw)
+	case ".toml":
+		if err := toml.Unmarshal(b, &doc); err != nil {
+			return nil, errors.Wrapf(err, "invalid config file %s", path)
+		}
+	default:
+		return nil, errors.Errorf("unsupported config file %s, use .yaml, .yml or .toml", path)
+	}
+	vars := map[string]string{}
+	if err := flatten("", doc, vars); err != nil {
+		return nil, errors.Wrapf(err, "invalid config file %s", path)
+	}
+	return vars, nil
+}
+
+func flatten(prefix string, doc map[string]interface{}, vars map[string]string) error {
+	for key, value := range doc {
+		name := strings.ToUpper(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(name, v, vars); err != nil {
+				return err
+			}
+		case map[interface{}]interface{}:
+			if err := flatten(name, stringKeys(v), vars); err != nil {
+				return err
+			}
+		case []interface{}, nil:
+			return errors.Errorf("unsupported value for %s", name)
+		default:
+			vars[name] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+func stringKeys(m map[interface{}]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[fmt.Sprint(k)] = v
+	}
+	return out
+}
+
+// resolveSecrets replaces NAME_FILE entries of vars with NAME read from the file,
+// returning the files read.
+func resolveSecrets(vars map[string]string, source string) ([]string, error) {
+	var files []string
+	for _, name := range secretVars {
+		path, ok := vars[name+"_FILE"]
+		if !ok {
+			continue
+		}
+		if _, ok := vars[name]; ok {
+			return nil, errors.Errorf("%s and %s_FILE are both set in %s, set only one", name, name, source)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s_FILE", name)
+		}
+		vars[name] = strings.TrimRight(string(b), "\r\n")
+		delete(vars, name+"_FILE")
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// environ returns the environment variables which are not empty, such as the
+// placeholders of a .env file.
+func environ() map[string]string {
+	vars := map[string]string{}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && parts[1] != "" {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	return vars
+}
@@ -0,0 +1,53 @@
+package wiring
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/nikhil-github/sms-app/pkg/rpc"
+	"github.com/nikhil-github/sms-app/pkg/tlsconfig"
+)
+
+// serveGRPC serves the gRPC API on its own port, over TLS when tlsReloader is set.
+// The returned func stops the server, letting in-flight calls and queued bulk sends finish until ctx is done.
+func serveGRPC(port int, logger *zap.Logger, srv *rpc.Server, interceptor *rpc.Interceptor, tlsReloader *tlsconfig.Reloader, errs chan error) func(ctx context.Context) error {
+	addr := fmt.Sprintf(":%d", port)
+	var opts []grpc.ServerOption
+	if tlsReloader != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsReloader.TLSConfig())))
+	}
+	s, healthSrv := rpc.NewGRPCServer(srv, interceptor, opts...)
+
+	go func() {
+		logger.Info("Listening for gRPC calls .....", zap.String("grpc.address", addr), zap.Bool("tls", tlsReloader != nil))
+		ln, err := net.Listen("tcp", addr)
+		if err == nil {
+			err = s.Serve(ln)
+		}
+		if err != nil && err != grpc.ErrServerStopped {
+			errs <- errors.Wrapf(err, "error serving gRPC on address %s", addr)
+		}
+	}()
+
+	return func(ctx context.Context) error {
+		healthSrv.Shutdown()
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.Stop()
+			return errors.Wrap(ctx.Err(), "failed to drain in-flight gRPC calls")
+		}
+		return errors.Wrap(srv.Drain(ctx), "failed to finish bulk sends")
+	}
+}
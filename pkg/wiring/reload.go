@@ -0,0 +1,265 @@
+package wiring
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/tlsconfig"
+)
+
+// reloadable lists the config sections applied without a restart.
+var reloadable = map[string]bool{"LOG": true, "BITLY": true, "TRANSMIT": true, "TENANT": true, "RATELIMIT": true, "OUTBOUND": true, "RELOAD": true}
+
+// liveLimits holds the rate limits of send requests, replaced on reload.
+type liveLimits struct {
+	v atomic.Value
+}
+
+func newLiveLimits(limits handler.RateLimits) *liveLimits {
+	l := &liveLimits{}
+	l.v.Store(limits)
+	return l
+}
+
+// RateLimits returns the current limits.
+func (l *liveLimits) RateLimits() handler.RateLimits {
+	return l.v.Load().(handler.RateLimits)
+}
+
+func (l *liveLimits) set(limits handler.RateLimits) {
+	l.v.Store(limits)
+}
+
+// reloader applies a reloaded config to the running app.
+type reloader struct {
+	logger  *zap.Logger
+	loader  Loader
+	level   zap.AtomicLevel
+	limits  *liveLimits
+	paced   *service.PacedClient
+	tenants *tenant.Store
+	bitly   *service.Bitly
+	auditor handler.Auditor
+	// certs and tls are nil when the server does not use client certificates or TLS.
+	certs *auth.CertIdentities
+	tls   *tlsconfig.Reloader
+	// watcher is restarted when RELOAD_WATCHINTERVAL changes, nil when files are not watched.
+	watcher *fileWatcher
+
+	mu      sync.Mutex
+	current *Config
+}
+
+// settings are the parsed reloadable settings, prepared before any is applied.
+type settings struct {
+	limits    handler.RateLimits
+	transmit  ratelimit.Limit
+	perSender ratelimit.Limit
+	tenants   []tenant.Tenant
+	certs     []auth.CertIdentity
+}
+
+func prepare(cfg *Config) (settings, error) {
+	var s settings
+	var err error
+	if s.limits, err = rateLimits(cfg); err != nil {
+		return s, err
+	}
+	if s.transmit, err = ratelimit.ParseLimit(cfg.OUTBOUND.Transmit); err != nil {
+		return s, err
+	}
+	if s.perSender, err = ratelimit.ParseLimit(cfg.OUTBOUND.Sender); err != nil {
+		return s, err
+	}
+	if s.tenants, err = tenantList(cfg); err != nil {
+		return s, err
+	}
+	if cfg.TLS.IdentityFile != "" {
+		if s.certs, err = auth.LoadCertIdentities(cfg.TLS.IdentityFile); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// config returns the running config.
+func (r *reloader) config() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload reads the config and applies the reloadable settings. An invalid config
+// is rejected and the running settings are kept. Changes to other sections are
+// reported and take effect on restart.
+func (r *reloader) Reload(ctx context.Context, trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := audit.Entry{Action: audit.ActionConfigReload, Target: trigger, Details: map[string]string{"status": "rejected"}}
+	cfg, err := r.loader.Load()
+	var s settings
+	if err == nil {
+		s, err = prepare(cfg)
+	}
+	if err == nil && r.certs != nil {
+		err = r.certs.Replace(s.certs)
+	}
+	if err != nil {
+		r.logger.Error("Config reload rejected, keeping the running config", zap.String("trigger", trigger), zap.Error(err))
+		entry.Details["error"] = err.Error()
+		r.record(ctx, entry)
+		return err
+	}
+
+	if cfg.LOG.Level != r.current.LOG.Level {
+		// Unchanged levels are kept so a level set through the admin endpoint survives reloads.
+		level, _ := parseLevel(cfg.LOG.Level)
+		r.level.SetLevel(level)
+	}
+	r.limits.set(s.limits)
+	r.paced.SetLimits(s.transmit, s.perSender, cfg.OUTBOUND.MaxDelay)
+	r.tenants.Replace(s.tenants)
+	if cfg.BITLY.Token != r.current.BITLY.Token {
+		r.bitly.SetToken(cfg.BITLY.Token)
+	}
+	if r.watcher != nil && cfg.RELOAD.WatchInterval != r.current.RELOAD.WatchInterval {
+		r.watcher.restart(cfg.RELOAD.WatchInterval)
+	}
+	if r.tls != nil {
+		if err := r.tls.Reload(); err != nil {
+			r.logger.Error("Unable to reload tls certificate, keeping the current one", zap.Error(err))
+		}
+	}
+
+	changed := changedSections(r.current, cfg)
+	var restart []string
+	for _, section := range changed {
+		if !reloadable[section] {
+			restart = append(restart, section)
+		}
+	}
+	if r.current.LOG.Encoding != cfg.LOG.Encoding {
+		restart = append(restart, "LOG_ENCODING")
+	}
+	if r.current.RATELIMIT.RedisURL != cfg.RATELIMIT.RedisURL {
+		restart = append(restart, "RATELIMIT_REDISURL")
+	}
+	if len(restart) > 0 {
+		r.logger.Warn("Config changes need a restart to take effect", zap.Strings("sections", restart))
+	}
+	r.current = cfg
+	r.logger.Info("Config reloaded", zap.String("trigger", trigger), zap.Strings("changed", changed))
+
+	entry.Details["status"] = "applied"
+	entry.Details["changed"] = strings.Join(changed, ",")
+	if len(restart) > 0 {
+		entry.Details["restart_required"] = strings.Join(restart, ",")
+	}
+	r.record(ctx, entry)
+	return nil
+}
+
+func (r *reloader) record(ctx context.Context, e audit.Entry) {
+	if _, err := r.auditor.Record(ctx, e); err != nil {
+		r.logger.Error("Unable to record audit entry", zap.String("action", e.Action), zap.Error(err))
+	}
+}
+
+// run reloads on SIGHUP or when the watched files change, until ctx is done.
+func (r *reloader) run(ctx context.Context, hup <-chan os.Signal, changes <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload(ctx, "SIGHUP")
+		case <-changes:
+			r.Reload(ctx, "file change")
+		}
+	}
+}
+
+// changedSections lists the config sections whose values differ, values are not reported as they may be secrets.
+func changedSections(old *Config, cfg *Config) []string {
+	var changed []string
+	o, n := reflect.ValueOf(old).Elem(), reflect.ValueOf(cfg).Elem()
+	for i := 0; i < o.NumField(); i++ {
+		if !reflect.DeepEqual(o.Field(i).Interface(), n.Field(i).Interface()) {
+			changed = append(changed, o.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
+
+// fileWatcher runs watchFiles, restarted with a new interval on reload.
+type fileWatcher struct {
+	ctx     context.Context
+	files   func() []string
+	changes chan<- struct{}
+	stop    context.CancelFunc
+}
+
+// restart stops the running watcher and starts one polling every interval, none when interval is 0.
+func (w *fileWatcher) restart(interval time.Duration) {
+	if w.stop != nil {
+		w.stop()
+		w.stop = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	ctx, stop := context.WithCancel(w.ctx)
+	w.stop = stop
+	go watchFiles(ctx, interval, w.files, w.changes)
+}
+
+// watchFiles polls the files every interval and signals when one is modified, created or removed.
+// Mounted Kubernetes secrets and config maps are replaced through symlinks, which are followed.
+func watchFiles(ctx context.Context, interval time.Duration, files func() []string, changes chan<- struct{}) {
+	stat := func() map[string]string {
+		state := map[string]string{}
+		for _, f := range files() {
+			info, err := os.Stat(f)
+			if err != nil {
+				state[f] = "missing"
+				continue
+			}
+			state[f] = fmt.Sprintf("%s/%d", info.ModTime(), info.Size())
+		}
+		return state
+	}
+	last := stat()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			state := stat()
+			if reflect.DeepEqual(state, last) {
+				continue
+			}
+			last = state
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package wiring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+type fakeLoader struct {
+	cfg *Config
+	err error
+}
+
+func (l *fakeLoader) Load() (*Config, error) {
+	return l.cfg, l.err
+}
+
+func (l *fakeLoader) Files() []string {
+	return nil
+}
+
+func testConfig() *Config {
+	cfg := &Config{}
+	cfg.LOG.Level = "ERROR"
+	cfg.LOG.Encoding = "json"
+	cfg.BITLY.Token = "token"
+	cfg.TRANSMIT.Apikey = "key"
+	cfg.TRANSMIT.Secret = "secret"
+	cfg.RATELIMIT.Global = "600/m"
+	cfg.RATELIMIT.Key = "60/m"
+	cfg.RATELIMIT.Recipient = "5/m"
+	cfg.OUTBOUND.MaxDelay = time.Second
+	cfg.REDACT.Body = "hash"
+	cfg.BREAKER.Failures = 5
+	cfg.SHUTDOWN.Timeout = time.Second
+	return cfg
+}
+
+func TestReload(t *testing.T) {
+	type want struct {
+		Err     string
+		Level   zapcore.Level
+		Key     ratelimit.Limit
+		Secret  string
+		Status  string
+		Changed string
+		Restart string
+	}
+	testTable := []struct {
+		Name   string
+		Config func(c *Config)
+		Err    error
+		Want   want
+	}{
+		{
+			Name: "Success - reloadable settings applied",
+			Config: func(c *Config) {
+				c.LOG.Level = "INFO"
+				c.RATELIMIT.Key = "10/m"
+				c.TRANSMIT.Secret = "rotated"
+			},
+			Want: want{Level: zapcore.InfoLevel, Key: ratelimit.Limit{Count: 10, Period: time.Minute}, Secret: "rotated", Status: "applied", Changed: "LOG,TRANSMIT,RATELIMIT"},
+		},
+		{
+			Name:   "Success - listener change needs restart",
+			Config: func(c *Config) { c.HTTP.Port = 4000 },
+			Want:   want{Level: zapcore.ErrorLevel, Key: ratelimit.Limit{Count: 60, Period: time.Minute}, Secret: "secret", Status: "applied", Changed: "HTTP", Restart: "HTTP"},
+		},
+		{
+			Name: "Failure - invalid config rejected",
+			Err:  errors.New("BITLY_TOKEN is missing: set BITLY_TOKEN or BITLY_TOKEN_FILE"),
+			Want: want{Err: "BITLY_TOKEN is missing: set BITLY_TOKEN or BITLY_TOKEN_FILE", Level: zapcore.ErrorLevel, Key: ratelimit.Limit{Count: 60, Period: time.Minute}, Secret: "secret", Status: "rejected"},
+		},
+		{
+			Name: "Failure - invalid rate limit rejected",
+			Config: func(c *Config) {
+				c.LOG.Level = "INFO"
+				c.RATELIMIT.Key = "lots"
+			},
+			Want: want{Err: "invalid", Level: zapcore.ErrorLevel, Key: ratelimit.Limit{Count: 60, Period: time.Minute}, Secret: "secret", Status: "rejected"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			current := testConfig()
+			limits, err := rateLimits(current)
+			require.NoError(t, err, "limits")
+			tenants, err := tenantList(current)
+			require.NoError(t, err, "tenants")
+			auditLog := audit.NewLog(audit.NewMemoryStore(), []byte("audit-key"))
+			next := testConfig()
+			if tt.Config != nil {
+				tt.Config(next)
+			}
+			r := &reloader{
+				logger:  zap.NewNop(),
+				loader:  &fakeLoader{cfg: next, err: tt.Err},
+				level:   zap.NewAtomicLevelAt(zapcore.ErrorLevel),
+				limits:  newLiveLimits(limits),
+				paced:   service.NewPacedClient(nil, ratelimit.Limit{}, ratelimit.Limit{}, time.Second),
+				tenants: tenant.NewStore(tenants),
+				bitly:   service.NewBitly(http.DefaultClient, "token", zap.NewNop()),
+				auditor: auditLog,
+				current: current,
+			}
+
+			err = r.Reload(context.Background(), "SIGHUP")
+			if tt.Want.Err != "" {
+				require.Error(t, err, "reload")
+				assert.Contains(t, err.Error(), tt.Want.Err, "error")
+			} else {
+				require.NoError(t, err, "reload")
+			}
+			assert.Equal(t, tt.Want.Level, r.level.Level(), "level")
+			assert.Equal(t, tt.Want.Key, r.limits.RateLimits().PerKey, "key limit")
+			def, err := r.tenants.Get(context.Background(), tenant.DefaultID)
+			require.NoError(t, err, "default tenant")
+			assert.Equal(t, tt.Want.Secret, def.Transmit.Secret, "transmit secret")
+
+			entries, err := auditLog.Query(context.Background(), audit.Filter{Action: audit.ActionConfigReload})
+			require.NoError(t, err, "audit")
+			require.Len(t, entries, 1, "audit entries")
+			assert.Equal(t, "SIGHUP", entries[0].Target, "trigger")
+			assert.Equal(t, tt.Want.Status, entries[0].Details["status"], "status")
+			assert.Equal(t, tt.Want.Changed, entries[0].Details["changed"], "changed")
+			assert.Equal(t, tt.Want.Restart, entries[0].Details["restart_required"], "restart")
+		})
+	}
+}
+
+func TestWatchFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: ERROR\n"), 0600), "write config")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 1)
+	go watchFiles(ctx, 10*time.Millisecond, func() []string { return []string{path} }, changes)
+
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: INFO\n"), 0600), "update config")
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change not detected")
+	}
+}
+
+func TestFileWatcherRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: ERROR\n"), 0600), "write config")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 1)
+	w := &fileWatcher{ctx: ctx, files: func() []string { return []string{path} }, changes: changes}
+	w.restart(0)
+
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: INFO\n"), 0600), "update config")
+	select {
+	case <-changes:
+		t.Fatal("change detected while not watching")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w.restart(10 * time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: WARN\n"), 0600), "update config again")
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change not detected after restart")
+	}
+}
+
+func TestConfigLoaderRotatedSecret(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "transmit_secret")
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(secret, []byte("first"), 0600), "write secret")
+	require.NoError(t, os.WriteFile(path, []byte("bitly:\n  token: token\ntransmit:\n  apikey: key\n  secret_file: "+secret+"\n"), 0600), "write config")
+	// Empty placeholders such as those of .env must not hide the config file.
+	for _, name := range []string{"BITLY_TOKEN", "TRANSMIT_APIKEY", "TRANSMIT_SECRET"} {
+		t.Setenv(name, "")
+	}
+	loader := &configLoader{path: path}
+
+	cfg, err := loader.Load()
+	require.NoError(t, err, "load")
+	assert.Equal(t, "first", cfg.TRANSMIT.Secret, "secret")
+	assert.Contains(t, loader.Files(), secret, "secret file watched")
+
+	require.NoError(t, os.WriteFile(secret, []byte("rotated"), 0600), "rotate secret")
+	cfg, err = loader.Load()
+	require.NoError(t, err, "reload")
+	assert.Equal(t, "rotated", cfg.TRANSMIT.Secret, "rotated secret")
+}
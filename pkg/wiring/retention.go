@@ -0,0 +1,76 @@
+package wiring
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+// retentionPolicy returns the retention of a tenant, falling back to RETENTION_DAYS and
+// RETENTION_ACTION for what the tenant does not set and for tenants no longer configured.
+func retentionPolicy(cfg *Config, tenants *tenant.Store) func(tenantID string) history.Policy {
+	action, _ := history.ParseAction(cfg.RETENTION.Action)
+	return func(tenantID string) history.Policy {
+		p := history.Policy{Days: cfg.RETENTION.Days, Action: action}
+		t, err := tenants.Get(context.Background(), tenantID)
+		if err != nil {
+			return p
+		}
+		if t.Retention.Days > 0 {
+			p.Days = t.Retention.Days
+		}
+		if a, ok := history.ParseAction(t.Retention.Action); ok {
+			p.Action = a
+		}
+		return p
+	}
+}
+
+// purgeHistory applies the retention policies of messages.
+func purgeHistory(ctx context.Context, logger *zap.Logger, messages *history.History, policy func(tenantID string) history.Policy) {
+	res, err := messages.Purge(ctx, time.Now(), policy)
+	if err != nil {
+		logger.Error("Unable to purge message history", zap.Error(err))
+		return
+	}
+	if res.Deleted > 0 || res.Anonymised > 0 {
+		logger.Info("Message history purged", zap.Int("deleted", res.Deleted), zap.Int("anonymised", res.Anonymised))
+	}
+}
+
+// purgeAudit removes the audit entries older than days.
+func purgeAudit(ctx context.Context, logger *zap.Logger, log *audit.Log, days int) {
+	n, err := log.Purge(ctx, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		logger.Error("Unable to purge audit log", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		logger.Info("Audit log purged", zap.Int64("deleted", n))
+	}
+}
+
+// purgeEvery runs the purges at startup and then every interval until ctx is done.
+func purgeEvery(ctx context.Context, interval time.Duration, purges ...func(ctx context.Context)) {
+	purge := func() {
+		for _, p := range purges {
+			p(ctx)
+		}
+	}
+	purge()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			purge()
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package wiring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	cfg := &Config{}
+	cfg.RETENTION.Days = 30
+	cfg.RETENTION.Action = "anonymise"
+	tenants := tenant.NewStore([]tenant.Tenant{
+		{ID: "days", Retention: tenant.Retention{Days: 7}},
+		{ID: "delete", Retention: tenant.Retention{Days: 90, Action: "delete"}},
+	})
+	policy := retentionPolicy(cfg, tenants)
+
+	testTable := []struct {
+		Name   string
+		Tenant string
+		Want   history.Policy
+	}{
+		{Name: "Success : service default", Tenant: tenant.DefaultID, Want: history.Policy{Days: 30, Action: history.ActionAnonymise}},
+		{Name: "Success : tenant days", Tenant: "days", Want: history.Policy{Days: 7, Action: history.ActionAnonymise}},
+		{Name: "Success : tenant days and action", Tenant: "delete", Want: history.Policy{Days: 90, Action: history.ActionDelete}},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Want, policy(tt.Tenant), "policy")
+		})
+	}
+}
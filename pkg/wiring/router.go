@@ -1,22 +1,144 @@
 package wiring
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
+	"github.com/nikhil-github/sms-app/pkg/auth"
 	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/metrics"
+	"github.com/nikhil-github/sms-app/pkg/openapi"
+	"github.com/nikhil-github/sms-app/pkg/tracing"
 )
 
 // Params represent router params.
 type Params struct {
-	Logger    *zap.Logger
-	Formatter handler.Formatter
-	Sender    handler.Sender
+	Logger        *zap.Logger
+	Formatter     handler.Formatter
+	Sender        handler.Sender
+	Authenticator handler.Authenticator
+	Certs         handler.CertAuthenticator
+	Keys          handler.KeyManager
+	Tenants       handler.TenantFinder
+	Limiter       handler.Limiter
+	RateLimits    handler.LimitSource
+	Readiness     handler.Readiness
+	Breakers      []handler.Breaker
+	Build         handler.Build
+	Metrics       *metrics.Metrics
+	Auditor       handler.Auditor
+	Audit         handler.AuditReader
+	Publisher     handler.Publisher
+	Events        handler.EventSource
+	Recorder      handler.HistoryRecorder
+	History       handler.HistoryReader
+	Eraser        handler.Eraser
+	Level         handler.LevelSetter
+	// Webhooks receives delivery receipts and replies from transmit, nil when no callback URL is set.
+	Webhooks *handler.Webhooks
+	// CORS applies to the send API, AdminCORS to the admin API.
+	CORS      handler.CORSPolicy
+	AdminCORS handler.CORSPolicy
+
+	spec *openapi.Document
+}
+
+// NewHealthRouter configure the router of the health listener, liveness and readiness without TLS.
+func NewHealthRouter(params *Params) *mux.Router {
+	rtr := mux.NewRouter()
+	rtr.Handle("/healthz", handler.Liveness()).Methods("GET")
+	rtr.Handle("/readyz", handler.Ready(params.Readiness, params.Breakers)).Methods("GET")
+	return rtr
 }
 
 // NewRouter configure all router.
+// Authenticated requests are validated against the OpenAPI document served at /openapi.json.
 func NewRouter(params *Params) *mux.Router {
+	params.spec = openapi.MustSpec()
 	rtr := mux.NewRouter().StrictSlash(true)
-	rtr.Handle("/api/v1/sms/send", handler.Send(params.Logger, params.Sender, params.Formatter)).Methods("POST")
+	rtr.Use(handler.RequestID(params.Logger), tracing.Middleware)
+	// Middleware only runs for matched routes, so unmatched ones get their request ID here.
+	rtr.NotFoundHandler = handler.RequestID(params.Logger)(http.NotFoundHandler())
+	rtr.MethodNotAllowedHandler = handler.RequestID(params.Logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	rtr.Handle("/healthz", handler.Liveness()).Methods("GET")
+	rtr.Handle("/readyz", handler.Ready(params.Readiness, params.Breakers)).Methods("GET")
+	rtr.Handle("/status", handler.AppStatus(params.Build)).Methods("GET")
+	rtr.Handle("/openapi.json", handler.OpenAPI(openapi.JSON())).Methods("GET")
+	if params.Metrics != nil {
+		rtr.Handle("/metrics", params.Metrics.Handler()).Methods("GET")
+		rtr.Use(params.Metrics.Middleware)
+	}
+
+	api := corsGroup(rtr, "/api/v1/sms", params.CORS)
+	send := handler.Send(params.Logger, params.Sender, params.Formatter, params.Limiter, params.RateLimits, params.Auditor, params.Publisher, params.Recorder)
+	api.Handle("/send", params.requireTenant(auth.ScopeSend, params.rateLimit(send))).Methods("POST")
+	if params.History != nil {
+		api.Handle("", params.requireTenant(auth.ScopeRead, handler.History(params.Logger, params.Formatter, params.History))).Methods("GET")
+	}
+
+	v2 := corsGroup(rtr, "/api/v2/sms", params.CORS)
+	sendV2 := handler.SendV2(params.Logger, params.Sender, params.Formatter, params.Limiter, params.RateLimits, params.Auditor, params.Publisher, params.Recorder)
+	v2.Handle("/send", params.requireTenant(auth.ScopeSend, params.rateLimit(sendV2))).Methods("POST")
+
+	if params.Events != nil {
+		events := corsGroup(rtr, "/api/v1/events", params.CORS)
+		events.Handle("", params.requireTenant(auth.ScopeRead, handler.Events(params.Logger, params.Events))).Methods("GET")
+	}
+
+	if params.Webhooks != nil {
+		// Transmit authenticates with the signature of the callback URL rather than an API key.
+		rtr.Handle(handler.DeliveryWebhookPath, handler.TransmitDelivery(params.Logger, params.Webhooks, params.Publisher)).Methods("GET", "POST")
+		rtr.Handle(handler.ReplyWebhookPath, handler.TransmitReply(params.Logger, params.Webhooks, params.Publisher)).Methods("GET", "POST")
+	}
+
+	admin := corsGroup(rtr, "/api/v1/admin", params.AdminCORS)
+	admin.Handle("/keys", params.requireScope(auth.ScopeAdmin, handler.IssueKey(params.Logger, params.Keys, params.Tenants, params.Auditor))).Methods("POST")
+	admin.Handle("/keys", params.requireScope(auth.ScopeAdmin, handler.ListKeys(params.Logger, params.Keys))).Methods("GET")
+	admin.Handle("/keys/{id}/rotate", params.requireScope(auth.ScopeAdmin, handler.RotateKey(params.Logger, params.Keys, params.Auditor))).Methods("POST")
+	admin.Handle("/keys/{id}", params.requireScope(auth.ScopeAdmin, handler.RevokeKey(params.Logger, params.Keys, params.Auditor))).Methods("DELETE")
+
+	admin.Handle("/audit", params.requireScope(auth.ScopeAdmin, handler.AuditLog(params.Logger, params.Audit))).Methods("GET")
+	admin.Handle("/audit/export", params.requireScope(auth.ScopeAdmin, handler.ExportAudit(params.Logger, params.Audit))).Methods("GET")
+	admin.Handle("/audit/verify", params.requireScope(auth.ScopeAdmin, handler.VerifyAudit(params.Logger, params.Audit))).Methods("GET")
+
+	if params.Eraser != nil {
+		// The number is formatted with the account of the admin key's tenant.
+		subjects := corsGroup(rtr, "/api/v1/subjects", params.AdminCORS)
+		subjects.Handle("/{phone}", params.requireTenant(auth.ScopeAdmin, handler.EraseSubject(params.Logger, params.Formatter, params.Eraser, params.Auditor))).Methods("DELETE")
+	}
+
+	if params.Level != nil {
+		admin.Handle("/log/level", params.requireScope(auth.ScopeAdmin, handler.GetLogLevel(params.Level))).Methods("GET")
+		admin.Handle("/log/level", params.requireScope(auth.ScopeAdmin, handler.SetLogLevel(params.Logger, params.Level, params.Auditor))).Methods("PUT")
+	}
 	return rtr
 }
+
+// corsGroup creates the routes under prefix sharing a CORS policy.
+// OPTIONS is routed for every path of the group so preflight requests reach the policy.
+func corsGroup(rtr *mux.Router, prefix string, policy handler.CORSPolicy) *mux.Router {
+	group := rtr.PathPrefix(prefix).Subrouter()
+	group.Use(handler.CORS(policy))
+	group.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return group
+}
+
+// requireScope authenticates the request, then validates it so only callers learn about the spec.
+func (p *Params) requireScope(scope auth.Scope, h http.Handler) http.Handler {
+	return handler.Authenticate(p.Logger, p.Authenticator, p.Certs, scope)(handler.Validate(p.Logger, p.spec)(h))
+}
+
+func (p *Params) rateLimit(h http.Handler) http.Handler {
+	return handler.RateLimit(p.Logger, p.Limiter, p.RateLimits)(h)
+}
+
+// requireTenant authenticates the request and resolves the tenant of the API key.
+func (p *Params) requireTenant(scope auth.Scope, h http.Handler) http.Handler {
+	return p.requireScope(scope, handler.ResolveTenant(p.Logger, p.Tenants)(h))
+}
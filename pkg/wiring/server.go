@@ -2,58 +2,458 @@ package wiring
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/go-redis/redis"
 	"github.com/pkg/errors"
-	"github.com/zpnk/go-bitly"
 	"go.uber.org/zap"
 
+	"github.com/nikhil-github/sms-app/pkg/audit"
+	"github.com/nikhil-github/sms-app/pkg/auth"
+	"github.com/nikhil-github/sms-app/pkg/breaker"
+	"github.com/nikhil-github/sms-app/pkg/events"
+	"github.com/nikhil-github/sms-app/pkg/handler"
+	"github.com/nikhil-github/sms-app/pkg/health"
+	"github.com/nikhil-github/sms-app/pkg/history"
+	"github.com/nikhil-github/sms-app/pkg/metrics"
+	"github.com/nikhil-github/sms-app/pkg/ratelimit"
+	"github.com/nikhil-github/sms-app/pkg/rpc"
 	"github.com/nikhil-github/sms-app/pkg/service"
+	"github.com/nikhil-github/sms-app/pkg/tenant"
+	"github.com/nikhil-github/sms-app/pkg/tlsconfig"
+	"github.com/nikhil-github/sms-app/pkg/tracing"
 )
 
 // Start wires the dependencies and start the app.
-func Start(cfg *Config, logger *zap.Logger) error {
+// The config is read again from loader on SIGHUP or when its files change.
+func Start(cfg *Config, loader Loader, logger *zap.Logger, level zap.AtomicLevel, build handler.Build) error {
 
 	ctx := context.Background()
-	bitly := service.NewBitly(bitly.New(cfg.BITLY.Token), logger)
-	svc := service.New(cfg.TRANSMIT.Apikey, cfg.TRANSMIT.Secret, &http.Client{Timeout: time.Second * 5}, logger, bitly)
-	router := NewRouter(&Params{Logger: logger, Formatter: svc, Sender: svc})
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Endpoint:    cfg.TRACING.Endpoint,
+		Insecure:    cfg.TRACING.Insecure,
+		SampleRatio: cfg.TRACING.SampleRatio,
+		ServiceName: cfg.TRACING.ServiceName,
+		Version:     build.Version,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Warn("Unable to flush traces", zap.Error(err))
+		}
+	}()
 
-	errs := make(chan error)
-	serveHTTP(cfg.HTTP.Port, logger, router, errs)
+	m := metrics.New()
+	transmitBreaker := breaker.New("transmit", cfg.BREAKER.Failures, cfg.BREAKER.Cooldown)
+	bitlyBreaker := breaker.New("bitly", cfg.BREAKER.Failures, cfg.BREAKER.Cooldown)
+	m.Breakers(transmitBreaker, bitlyBreaker)
+	bitlyClient := service.NewBitly(&http.Client{Timeout: time.Second * 5}, cfg.BITLY.Token, logger)
+	shorter := m.Shorter(tracing.Shorter(service.NewBreakerShorter(bitlyClient, bitlyBreaker), "bitly"), "bitly")
+	paced, err := pacedClient(cfg, &http.Client{Timeout: time.Second * 5})
+	if err != nil {
+		return err
+	}
+	m.Gauge("sms_outbound_waiting", "Provider calls waiting for an outbound slot.", func() float64 { return float64(paced.Waiting()) })
+	svc := service.NewTenantService(service.NewBreakerClient(paced, transmitBreaker), logger, shorter)
+
+	tenantsList, err := tenantList(cfg)
+	if err != nil {
+		return err
+	}
+	tenants := tenant.NewStore(tenantsList)
+
+	keyStore, err := openKeys(cfg, logger)
+	if err != nil {
+		return err
+	}
+	keys := auth.NewKeys(keyStore)
+	if cfg.AUTH.BootstrapKey != "" {
+		// A stored bootstrap key is kept, so rotating or revoking it through the API lasts.
+		if _, err := keyStore.Get(ctx, "bootstrap"); err != auth.ErrNotFound {
+			if err != nil {
+				return errors.Wrap(err, "failed to read bootstrap api key")
+			}
+			logger.Info("Bootstrap api key already stored")
+		} else if _, err := keys.Import(ctx, "bootstrap", "bootstrap", cfg.AUTH.BootstrapKey, []auth.Scope{auth.ScopeAdmin}); err != nil {
+			return errors.Wrap(err, "failed to register bootstrap api key")
+		}
+	} else {
+		logger.Warn("No bootstrap api key configured, api keys cannot be issued")
+	}
+
+	auditLog, auditFile, err := openAudit(cfg, logger)
+	if err != nil {
+		return err
+	}
+	if auditFile != nil {
+		defer auditFile.Close()
+	}
+	messages, historyFile, err := openHistory(cfg, logger)
+	if err != nil {
+		return err
+	}
+	if historyFile != nil {
+		defer historyFile.Close()
+	}
+
+	limits, err := rateLimits(cfg)
+	if err != nil {
+		return err
+	}
+	liveLimits := newLiveLimits(limits)
+	limitStore, err := rateLimitStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	var tlsReloader *tlsconfig.Reloader
+	if cfg.TLS.CertFile != "" {
+		if tlsReloader, err = tlsconfig.New(cfg.tlsConfig(), logger); err != nil {
+			return err
+		}
+	}
+	var certs *auth.CertIdentities
+	if cfg.TLS.IdentityFile != "" {
+		identities, err := auth.LoadCertIdentities(cfg.TLS.IdentityFile)
+		if err != nil {
+			return err
+		}
+		if certs, err = auth.NewCertIdentities(identities); err != nil {
+			return err
+		}
+	}
+
+	r := &reloader{
+		logger:  logger,
+		loader:  loader,
+		level:   level,
+		limits:  liveLimits,
+		paced:   paced,
+		tenants: tenants,
+		bitly:   bitlyClient,
+		auditor: auditLog,
+		certs:   certs,
+		tls:     tlsReloader,
+		current: cfg,
+	}
+
+	checks := health.New()
+	checks.Add("config", func(ctx context.Context) error { return r.config().Validate() }, true)
+	if p, ok := limitStore.(pinger); ok {
+		checks.Add("ratelimit-store", p.Ping, true)
+	}
+	if auditFile != nil {
+		checks.Add("audit-file", auditFile.Ping, true)
+	}
+	if historyFile != nil {
+		checks.Add("history-file", historyFile.Ping, true)
+	}
+	if cfg.READY.CheckProviders {
+		client := &http.Client{Timeout: time.Second * 5}
+		checks.Add("transmit", health.Cached(health.Reachable(client, service.TransmitURL), cfg.READY.CacheTTL), false)
+		checks.Add("bitly", health.Cached(health.Reachable(client, service.BitlyURL), cfg.READY.CacheTTL), false)
+	}
+
+	var webhooks *handler.Webhooks
+	var transmit handler.Sender = svc
+	if cfg.WEBHOOK.URL != "" {
+		webhooks = handler.NewWebhooks(cfg.WEBHOOK.URL, cfg.WEBHOOK.Secret)
+		transmit = webhooks.Sender(svc)
+	}
+	formatter := m.Formatter(tracing.Formatter(svc, "transmit"), "transmit")
+	sender := m.Sender(tracing.Sender(transmit, "transmit"), "transmit")
+	limiter := m.Limiter(ratelimit.New(limitStore), "transmit")
+	broker := events.NewBroker(cfg.EVENTS.Buffer)
+	params := &Params{
+		Logger:        logger,
+		Formatter:     formatter,
+		Sender:        sender,
+		Authenticator: keys,
+		Certs:         certAuthenticator(certs),
+		Keys:          keys,
+		Tenants:       tenants,
+		Limiter:       limiter,
+		RateLimits:    liveLimits,
+		Readiness:     checks,
+		Breakers:      []handler.Breaker{transmitBreaker, bitlyBreaker},
+		Build:         build,
+		Metrics:       m,
+		Auditor:       auditLog,
+		Audit:         auditLog,
+		Publisher:     broker,
+		Events:        broker,
+		Recorder:      messages,
+		History:       messages,
+		Eraser:        messages,
+		Level:         level,
+		Webhooks:      webhooks,
+		CORS:          cfg.corsPolicy(),
+		AdminCORS:     cfg.adminCORSPolicy(),
+	}
+	router := NewRouter(params)
+
+	rpcServer := rpc.NewServer(logger, sender, formatter, limiter, liveLimits, auditLog, broker, messages, rpc.NewTracker(cfg.GRPC.Statuses))
+	interceptor := rpc.NewInterceptor(logger, keys, certAuthenticator(certs), tenants, limiter, liveLimits)
+
+	errs := make(chan error, 3)
+	srv := serveHTTP(cfg.HTTP.Port, logger, router, tlsReloader, errs)
+	stops := []func(ctx context.Context) error{}
+	if cfg.HEALTH.Port > 0 {
+		// Probes cannot present the client certificates TLS may require, health is also served in plain HTTP.
+		stops = append(stops, serveHTTP(cfg.HEALTH.Port, logger, NewHealthRouter(params), nil, errs).Shutdown)
+	}
+	// Event streams never finish on their own, they are ended so shutdown can drain.
+	srv.RegisterOnShutdown(broker.Close)
+	stopGRPC := serveGRPC(cfg.GRPC.Port, logger, rpcServer, interceptor, tlsReloader, errs)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	// The reloader and purger write to the audit and history stores, they are stopped
+	// and waited for before the deferred closes of the stores run.
+	var background sync.WaitGroup
+	defer background.Wait()
+	reloadCtx, stopReload := context.WithCancel(ctx)
+	defer stopReload()
+	changes := make(chan struct{}, 1)
+	r.watcher = &fileWatcher{ctx: reloadCtx, files: loader.Files, changes: changes}
+	r.watcher.restart(cfg.RELOAD.WatchInterval)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		r.run(reloadCtx, hup, changes)
+	}()
+
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	defer stopPurge()
+	policy := retentionPolicy(cfg, tenants)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		purgeEvery(purgeCtx, cfg.RETENTION.Interval,
+			func(ctx context.Context) { purgeHistory(ctx, logger, messages, policy) },
+			func(ctx context.Context) { purgeAudit(ctx, logger, auditLog, cfg.RETENTION.AuditDays) },
+		)
+	}()
+
+	return waitForShutdown(logger, srv, errs, signals, cfg.SHUTDOWN.Timeout, append([]func(ctx context.Context) error{stopGRPC}, stops...)...)
+}
 
+// ShutdownError is returned by Start when the app was asked to stop but did not stop cleanly,
+// such as when in-flight requests outlive the shutdown timeout.
+type ShutdownError struct {
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	return e.Err.Error()
+}
+
+// waitForShutdown blocks until a server fails or a signal is received, then
+// stops accepting connections and waits for in-flight requests up to timeout.
+// Other servers are stopped by stops within the same timeout. Errors stopping are ShutdownErrors.
+func waitForShutdown(logger *zap.Logger, srv *http.Server, errs <-chan error, signals <-chan os.Signal, timeout time.Duration, stops ...func(ctx context.Context) error) error {
 	select {
 	case err := <-errs:
 		return err
-	case <-ctx.Done():
+	case sig := <-signals:
+		logger.Info("Shutting down, draining in-flight requests", zap.String("signal", sig.String()), zap.Duration("timeout", timeout))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return &ShutdownError{Err: errors.Wrap(err, "failed to drain in-flight requests")}
+	}
+	for _, stop := range stops {
+		if err := stop(ctx); err != nil {
+			return &ShutdownError{Err: err}
+		}
+	}
+	logger.Info("Shutdown complete")
+	return nil
+}
+
+// tenantList reads tenants from the tenants file. The transmit credentials,
+// when set, define the default tenant used by keys not assigned to a tenant.
+func tenantList(cfg *Config) ([]tenant.Tenant, error) {
+	var tenants []tenant.Tenant
+	if cfg.TENANT.File != "" {
+		loaded, err := tenant.LoadFile(cfg.TENANT.File)
+		if err != nil {
+			return nil, err
+		}
+		tenants = loaded
+	}
+	if cfg.TRANSMIT.Apikey != "" || cfg.TRANSMIT.Secret != "" {
+		def := tenant.Tenant{
+			ID:       tenant.DefaultID,
+			Name:     "Default",
+			Transmit: tenant.Transmit{APIKey: cfg.TRANSMIT.Apikey, Secret: cfg.TRANSMIT.Secret},
+		}
+		if err := def.Validate(); err != nil {
+			return nil, err
+		}
+		for _, t := range tenants {
+			if t.ID == tenant.DefaultID {
+				return nil, errors.New("default tenant defined by both tenants file and transmit credentials")
+			}
+		}
+		tenants = append(tenants, def)
+	}
+	if len(tenants) == 0 {
+		return nil, errors.New("no tenants configured: set TRANSMIT_APIKEY and TRANSMIT_SECRET or TENANT_FILE")
+	}
+	return tenants, nil
+}
+
+// openAudit opens the audit log and checks its hash chain so tampering is reported at startup.
+// A broken chain is logged rather than stopping the app, new entries keep chaining from the last one.
+// The file store is nil when entries are kept in memory.
+func openAudit(cfg *Config, logger *zap.Logger) (*audit.Log, fileStore, error) {
+	if cfg.AUDIT.File == "" {
+		logger.Warn("No audit file configured, audit entries are lost on restart")
+		key := []byte(cfg.AUDIT.Key)
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to generate audit key")
+			}
+		}
+		return audit.NewLog(audit.NewMemoryStore(), key), nil, nil
+	}
+	store, err := audit.OpenFile(cfg.AUDIT.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	log := audit.NewLog(store, []byte(cfg.AUDIT.Key))
+	n, err := log.Verify(context.Background())
+	if err != nil {
+		logger.Error("Audit log verification failed", zap.Int64("verified_entries", n), zap.Error(err))
+	} else {
+		logger.Info("Audit log verified", zap.Int64("entries", n))
+	}
+	return log, store, nil
+}
+
+// openKeys opens the store of API keys.
+func openKeys(cfg *Config, logger *zap.Logger) (auth.Store, error) {
+	if cfg.AUTH.KeysFile == "" {
+		logger.Warn("No keys file configured, issued api keys are lost on restart")
+		return auth.NewMemoryStore(), nil
+	}
+	return auth.OpenFile(cfg.AUTH.KeysFile)
+}
+
+// openHistory opens the history of sent messages. The file store is nil when messages are kept in memory.
+func openHistory(cfg *Config, logger *zap.Logger) (*history.History, fileStore, error) {
+	if cfg.HISTORY.File == "" {
+		logger.Warn("No history file configured, sent messages are lost on restart")
+		return history.New(history.NewMemoryStore()), nil, nil
+	}
+	store, err := history.OpenFile(cfg.HISTORY.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	return history.New(store), store, nil
+}
+
+// pacedClient paces calls to transmit to stay under its throughput limits.
+func pacedClient(cfg *Config, client service.HTTPClient) (*service.PacedClient, error) {
+	provider, err := ratelimit.ParseLimit(cfg.OUTBOUND.Transmit)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := ratelimit.ParseLimit(cfg.OUTBOUND.Sender)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewPacedClient(client, provider, sender, cfg.OUTBOUND.MaxDelay), nil
+}
+
+// pinger is implemented by stores able to check their connectivity.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// fileStore is implemented by the audit and history file stores.
+type fileStore interface {
+	pinger
+	Close() error
+}
+
+// rateLimits parses the limits of send requests.
+func rateLimits(cfg *Config) (handler.RateLimits, error) {
+	var limits handler.RateLimits
+	var err error
+	if limits.Global, err = ratelimit.ParseLimit(cfg.RATELIMIT.Global); err != nil {
+		return limits, err
+	}
+	if limits.PerKey, err = ratelimit.ParseLimit(cfg.RATELIMIT.Key); err != nil {
+		return limits, err
+	}
+	if limits.PerRecipient, err = ratelimit.ParseLimit(cfg.RATELIMIT.Recipient); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+// rateLimitStore selects the bucket store.
+func rateLimitStore(cfg *Config) (ratelimit.Store, error) {
+	if cfg.RATELIMIT.RedisURL == "" {
+		return ratelimit.NewMemoryStore(), nil
+	}
+	opts, err := redis.ParseURL(cfg.RATELIMIT.RedisURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid rate limit redis url")
+	}
+	return ratelimit.NewRedisStore(redis.NewClient(opts), "sms-app:ratelimit:"), nil
+}
+
+// certAuthenticator avoids a non-nil interface holding a nil identities pointer.
+func certAuthenticator(certs *auth.CertIdentities) handler.CertAuthenticator {
+	if certs == nil {
 		return nil
 	}
+	return certs
 }
 
-func serveHTTP(port int, logger *zap.Logger, h http.Handler, errs chan error) {
+// serveHTTP listens on HTTPS when tlsReloader is set, on plain HTTP otherwise.
+func serveHTTP(port int, logger *zap.Logger, h http.Handler, tlsReloader *tlsconfig.Reloader, errs chan error) *http.Server {
 	addr := fmt.Sprintf(":%d", port)
-	s := &http.Server{Addr: addr, Handler: disableCors(h)}
+	s := &http.Server{Addr: addr, Handler: h}
+	if tlsReloader != nil {
+		s.TLSConfig = tlsReloader.TLSConfig()
+	}
 
 	go func() {
-		logger.Info("Listening for requests .....", zap.String("http.address", addr))
-		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Listening for requests .....", zap.String("http.address", addr), zap.Bool("tls", tlsReloader != nil))
+		var err error
+		if tlsReloader != nil {
+			err = s.ListenAndServeTLS("", "")
+		} else {
+			err = s.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errs <- errors.Wrapf(err, "error serving HTTP on address %s", addr)
 		}
 	}()
-}
-
-func disableCors(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, Content-Length, Accept-Encoding")
-		if r.Method == "OPTIONS" {
-			w.Header().Set("Access-Control-Max-Age", "86400")
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		h.ServeHTTP(w, r)
-	})
+	return s
 }
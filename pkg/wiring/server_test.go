@@ -0,0 +1,77 @@
+package wiring
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWaitForShutdown(t *testing.T) {
+	type args struct {
+		Handler time.Duration
+		Timeout time.Duration
+	}
+	type want struct {
+		Err    string
+		Status int
+	}
+	testTable := []struct {
+		Name string
+		Args args
+		Want want
+	}{
+		{
+			Name: "Success : in-flight request drained",
+			Args: args{Handler: 100 * time.Millisecond, Timeout: time.Second},
+			Want: want{Status: http.StatusOK},
+		},
+		{
+			Name: "Failure : deadline exceeded",
+			Args: args{Handler: time.Second, Timeout: 50 * time.Millisecond},
+			Want: want{Err: "failed to drain in-flight requests: context deadline exceeded"},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.Name, func(t *testing.T) {
+			started := make(chan struct{})
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				time.Sleep(tt.Args.Handler)
+				w.WriteHeader(http.StatusOK)
+			})}
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err, "listen")
+			go srv.Serve(ln)
+
+			status := make(chan int, 1)
+			go func() {
+				res, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					status <- 0
+					return
+				}
+				res.Body.Close()
+				status <- res.StatusCode
+			}()
+			<-started
+
+			signals := make(chan os.Signal, 1)
+			signals <- syscall.SIGTERM
+			err = waitForShutdown(zap.NewNop(), srv, make(chan error), signals, tt.Args.Timeout)
+			if tt.Want.Err != "" {
+				assert.EqualError(t, err, tt.Want.Err, "error message")
+				assert.IsType(t, &ShutdownError{}, err, "reported apart from start errors")
+				return
+			}
+			require.NoError(t, err, "error")
+			assert.Equal(t, tt.Want.Status, <-status, "in-flight request status")
+		})
+	}
+}